@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 // GetDBConfig generates a connection string to PostgreSQL
@@ -15,3 +16,50 @@ func GetDBConfig() string {
 		os.Getenv("DB_NAME"),
 	)
 }
+
+// GetLowStockWebhookURL returns the URL low stock alerts are posted to, empty if disabled
+func GetLowStockWebhookURL() string {
+	return os.Getenv("LOW_STOCK_WEBHOOK_URL")
+}
+
+// GetExpiredWriteOffInterval returns how often expired lots are written off automatically,
+// e.g. "1h". Zero if disabled.
+func GetExpiredWriteOffInterval() (time.Duration, error) {
+	value := os.Getenv("EXPIRED_WRITE_OFF_INTERVAL")
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("EXPIRED_WRITE_OFF_INTERVAL must be a positive duration, got %q", value)
+	}
+	return interval, nil
+}
+
+// GetCafeLocation returns the time zone of the cafe pricing rule windows are matched in,
+// e.g. "Asia/Almaty". Defaults to the local time zone of the server.
+func GetCafeLocation() (*time.Location, error) {
+	value := os.Getenv("CAFE_TIMEZONE")
+	if value == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return time.Local, fmt.Errorf("CAFE_TIMEZONE must be an IANA time zone name, got %q", value)
+	}
+	return location, nil
+}
+
+// GetPriceSchedulerInterval returns how often due scheduled prices are applied, e.g. "30s".
+// Defaults to one minute, "0" disables the scheduler.
+func GetPriceSchedulerInterval() (time.Duration, error) {
+	value := os.Getenv("PRICE_SCHEDULER_INTERVAL")
+	if value == "" {
+		return time.Minute, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("PRICE_SCHEDULER_INTERVAL must be a positive duration or 0, got %q", value)
+	}
+	return interval, nil
+}
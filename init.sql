@@ -6,36 +6,70 @@ BEGIN
 END
 $$;
 
-CREATE TYPE order_status AS ENUM ('open', 'closed');
-CREATE TYPE unit_types AS ENUM ('ml', 'shots', 'g');
+CREATE TYPE order_status AS ENUM ('pending', 'accepted', 'preparing', 'ready', 'picked_up', 'cancelled');
+CREATE TYPE unit_types AS ENUM ('ml', 'shots', 'g', 'pcs');
+CREATE TYPE purchase_order_status AS ENUM ('draft', 'sent', 'partially_received', 'received');
+CREATE TYPE waste_reason AS ENUM ('spoiled', 'expired', 'spilled', 'damaged', 'other');
+CREATE TYPE stocktake_status AS ENUM ('open', 'committed');
+CREATE TYPE pricing_adjustment AS ENUM ('percentage', 'fixed');
+CREATE TYPE promo_discount_type AS ENUM ('percentage', 'fixed', 'buy_x_get_y');
+CREATE TYPE inventory_reason AS ENUM ('initial_stock', 'adjustment', 'sale', 'cancellation', 'waste', 'purchase_receipt', 'count_correction', 'production');
+
+-- Категории меню. Иерархия через ParentID, порядок показа через DisplayOrder
+CREATE TABLE categories (
+    ID SERIAL PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL,
+    ParentID INT,
+    DisplayOrder INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (ParentID) REFERENCES categories(ID) ON DELETE SET NULL
+);
 
 CREATE TABLE menu_items (
     ID SERIAL PRIMARY KEY,
     Name VARCHAR(50) NOT NULL,
     Description TEXT NOT NULL,
-    Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0)
+    Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0),
+    CategoryID INT,
+    FOREIGN KEY (CategoryID) REFERENCES categories(ID) ON DELETE SET NULL
 );
 
 CREATE TABLE inventory (
     IngredientID SERIAL PRIMARY KEY,
     Name VARCHAR(50) NOT NULL,
     Quantity INT NOT NULL CHECK(Quantity >= 0),
-    Unit unit_types NOT NULL
+    Unit unit_types NOT NULL,
+    ReorderLevel INT NOT NULL DEFAULT 0 CHECK(ReorderLevel >= 0), -- при остатке не выше этого уровня нужно заказывать
+    ParLevel INT NOT NULL DEFAULT 0 CHECK(ParLevel >= 0), -- желаемый остаток после пополнения
+    UnitCost NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(UnitCost >= 0), -- средневзвешенная себестоимость единицы, пересчитывается при приемке
+    Allergens TEXT[] NOT NULL DEFAULT '{}', -- аллергены (dairy, gluten, nuts ...), наследуются позициями меню и заготовками
+    Dietary TEXT[] NOT NULL DEFAULT '{}', -- диетические признаки (vegan, vegetarian, halal), у блюда есть, только если есть у всех ингредиентов
+    -- пищевая ценность на единицу хранения (1 g, 1 ml, 1 shot ...), у заготовок считается по рецепту
+    Calories NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(Calories >= 0),
+    Protein NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(Protein >= 0),
+    Fat NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(Fat >= 0),
+    Carbohydrates NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(Carbohydrates >= 0),
+    Sugar NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(Sugar >= 0)
 );
 
 CREATE TABLE orders (
     ID SERIAL PRIMARY KEY,
     CustomerName VARCHAR(50) NOT NULL,
-    Status order_status DEFAULT 'open',
+    Status order_status DEFAULT 'pending',
     Notes JSONB, -- 
+    DiscountTotal NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK(DiscountTotal >= 0), -- скидка по промокодам, строки в order_discounts
+    Total NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK(Total >= 0), -- сумма заказа на момент оформления за вычетом скидки
     CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE order_items (
-    OrderID INT,
+    ID SERIAL PRIMARY KEY,
+    OrderID INT NOT NULL,
     ProductID INT NOT NULL,
     Quantity INT NOT NULL CHECK(Quantity > 0),
-    PRIMARY KEY (OrderID, ProductID),
+    UnitPrice NUMERIC(10, 2) NOT NULL CHECK(UnitPrice >= 0), -- цена позиции с модификаторами на момент оформления
+    LineTotal NUMERIC(10, 2) NOT NULL CHECK(LineTotal >= 0),
+    VariantID INT,
+    VariantName VARCHAR(20), -- название варианта на момент оформления
     FOREIGN KEY (OrderID) REFERENCES orders(ID),
     FOREIGN KEY (ProductID) REFERENCES menu_items(ID)
 );
@@ -49,6 +83,37 @@ CREATE TABLE price_history (
     FOREIGN KEY (Menu_ItemID) REFERENCES menu_items(ID)
 );
 
+-- Запланированные цены: фоновый планировщик выставляет цену позиции в EffectiveAt,
+-- изменение попадает в price_history через триггер. Отменить можно только еще не примененную цену
+CREATE TABLE scheduled_prices (
+    ID SERIAL PRIMARY KEY,
+    MenuItemID INT NOT NULL,
+    Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0),
+    EffectiveAt TIMESTAMPTZ NOT NULL,
+    CreatedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    AppliedAt TIMESTAMPTZ,
+    FOREIGN KEY (MenuItemID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_scheduled_prices_pending ON scheduled_prices (EffectiveAt) WHERE AppliedAt IS NULL;
+
+-- Ценовые правила (happy hour, меню по времени суток): корректируют цену позиций категории
+-- (вместе с подкатегориями, NULL - все меню) в окне StartTime-EndTime по местному времени.
+-- Окно с EndTime раньше StartTime переходит через полночь. Из подходящих правил действует одно - с наибольшим Priority
+CREATE TABLE pricing_rules (
+    ID SERIAL PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL,
+    CategoryID INT,
+    Weekdays INT[] NOT NULL DEFAULT '{}', -- 1 - понедельник ... 7 - воскресенье, пусто - каждый день
+    StartTime TIME NOT NULL,
+    EndTime TIME NOT NULL,
+    AdjustmentType pricing_adjustment NOT NULL,
+    Value NUMERIC(10, 2) NOT NULL, -- процент (-20 = скидка 20%) или сумма (-0.50), прибавляемая к цене
+    Priority INT NOT NULL DEFAULT 0,
+    Active BOOLEAN NOT NULL DEFAULT TRUE,
+    FOREIGN KEY (CategoryID) REFERENCES categories(ID) ON DELETE CASCADE
+);
+
 CREATE TABLE menu_item_ingredients (
     MenuID INT,
     IngredientID INT NOT NULL,
@@ -58,11 +123,218 @@ CREATE TABLE menu_item_ingredients (
     FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
 );
 
+-- Заготовки (сироп, колд брю) - ингредиенты со своим рецептом. Одна партия расходует компоненты
+-- и дает Yield единиц заготовки. Компонент сам может быть заготовкой, циклы проверяет приложение
+CREATE TABLE prepared_ingredients (
+    IngredientID INT PRIMARY KEY,
+    Yield INT NOT NULL CHECK(Yield > 0),
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+CREATE TABLE prepared_ingredient_components (
+    PreparedID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (PreparedID, IngredientID),
+    CHECK (PreparedID <> IngredientID),
+    FOREIGN KEY (PreparedID) REFERENCES prepared_ingredients(IngredientID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
+);
+
+-- Производство заготовок. Cost - фактическая себестоимость израсходованных компонентов
+CREATE TABLE production_batches (
+    ID SERIAL PRIMARY KEY,
+    IngredientID INT NOT NULL,
+    Batches INT NOT NULL CHECK(Batches > 0),
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Cost NUMERIC(10, 4) NOT NULL DEFAULT 0,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+CREATE TABLE production_batch_items (
+    BatchID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Cost NUMERIC(10, 4) NOT NULL DEFAULT 0,
+    PRIMARY KEY (BatchID, IngredientID),
+    FOREIGN KEY (BatchID) REFERENCES production_batches(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Партии ингредиентов. Quantity - остаток партии, расходуется по сроку годности (FEFO), затем по дате приемки.
+-- Сумма остатков партий не превышает остаток в inventory, разница - остаток без партии
+CREATE TABLE inventory_lots (
+    ID SERIAL PRIMARY KEY,
+    IngredientID INT NOT NULL,
+    PurchaseOrderID INT,
+    ReceivedQuantity INT NOT NULL CHECK(ReceivedQuantity > 0),
+    Quantity INT NOT NULL CHECK(Quantity >= 0 AND Quantity <= ReceivedQuantity),
+    ReceivedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ExpiresAt TIMESTAMPTZ, -- NULL для ингредиентов без срока годности
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Варианты позиции меню (размеры S/M/L) со своей ценой.
+-- Рецепт варианта - базовый рецепт, умноженный на RecipeMultiplier, либо собственный рецепт из menu_item_variant_ingredients
+CREATE TABLE menu_item_variants (
+    ID SERIAL PRIMARY KEY,
+    MenuID INT NOT NULL,
+    Name VARCHAR(20) NOT NULL,
+    Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0),
+    RecipeMultiplier NUMERIC(5, 2) NOT NULL DEFAULT 1 CHECK(RecipeMultiplier > 0),
+    UNIQUE (MenuID, Name) DEFERRABLE INITIALLY DEFERRED, -- проверяется при фиксации, варианты можно переименовывать друг в друга
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE menu_item_variant_ingredients (
+    VariantID INT,
+    IngredientID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (VariantID, IngredientID),
+    FOREIGN KEY (VariantID) REFERENCES menu_item_variants(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
+);
+
+ALTER TABLE order_items
+ADD FOREIGN KEY (VariantID) REFERENCES menu_item_variants(ID) ON DELETE SET NULL;
+
+-- Группы модификаторов позиции меню ("Молоко", "Добавки") с ограничением количества выбора
+CREATE TABLE modifier_groups (
+    ID SERIAL PRIMARY KEY,
+    MenuID INT NOT NULL,
+    Name VARCHAR(50) NOT NULL,
+    MinSelect INT NOT NULL DEFAULT 0 CHECK(MinSelect >= 0),
+    MaxSelect INT NOT NULL DEFAULT 1 CHECK(MaxSelect >= 1 AND MaxSelect >= MinSelect),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE modifiers (
+    ID SERIAL PRIMARY KEY,
+    GroupID INT NOT NULL,
+    Name VARCHAR(50) NOT NULL,
+    PriceDelta NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (GroupID) REFERENCES modifier_groups(ID) ON DELETE CASCADE
+);
+
+-- Изменение рецепта модификатором: положительное значение добавляет ингредиент, отрицательное убирает
+CREATE TABLE modifier_ingredients (
+    ModifierID INT,
+    IngredientID INT NOT NULL,
+    QuantityDelta INT NOT NULL CHECK(QuantityDelta <> 0),
+    PRIMARY KEY (ModifierID, IngredientID),
+    FOREIGN KEY (ModifierID) REFERENCES modifiers(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
+);
+
+-- Модификаторы, выбранные в позиции заказа, с ценой на момент оформления
+CREATE TABLE order_item_modifiers (
+    ID SERIAL PRIMARY KEY,
+    OrderItemID INT NOT NULL,
+    ModifierID INT,
+    Name VARCHAR(50) NOT NULL,
+    PriceDelta NUMERIC(10, 2) NOT NULL,
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ModifierID) REFERENCES modifiers(ID) ON DELETE SET NULL
+);
+
+-- Слоты комбо-наборов: набор - позиция меню без своего рецепта, состоящая из других позиций.
+-- Слот с одним вариантом - постоянный компонент, с несколькими - выбор покупателя
+CREATE TABLE bundle_slots (
+    ID SERIAL PRIMARY KEY,
+    BundleID INT NOT NULL,
+    Name VARCHAR(50) NOT NULL,
+    Quantity INT NOT NULL DEFAULT 1 CHECK(Quantity > 0),
+    FOREIGN KEY (BundleID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+-- Позиции меню, которыми можно заполнить слот, с доплатой к цене набора
+CREATE TABLE bundle_slot_options (
+    SlotID INT,
+    MenuItemID INT NOT NULL,
+    PriceDelta NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK(PriceDelta >= 0),
+    PRIMARY KEY (SlotID, MenuItemID),
+    FOREIGN KEY (SlotID) REFERENCES bundle_slots(ID) ON DELETE CASCADE,
+    FOREIGN KEY (MenuItemID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+-- Компоненты набора в позиции заказа, количество на один набор. Ингредиенты компонентов
+-- списываются в order_item_ingredients позиции набора
+CREATE TABLE order_item_components (
+    ID SERIAL PRIMARY KEY,
+    OrderItemID INT NOT NULL,
+    SlotName VARCHAR(50) NOT NULL,
+    ProductID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    PriceDelta NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID)
+);
+
+-- Промокоды. Value - процент, сумма или для buy_x_get_y процент скидки на Y самых дешевых позиций
+-- из каждых X+Y подходящих (100 - бесплатно). Область действия - позиция меню или категория с подкатегориями,
+-- без них весь заказ. Нулевые лимиты - без ограничений, отмененные заказы не считаются использованием
+CREATE TABLE promo_codes (
+    ID SERIAL PRIMARY KEY,
+    Code VARCHAR(30) NOT NULL UNIQUE, -- в верхнем регистре
+    Description TEXT,
+    DiscountType promo_discount_type NOT NULL,
+    Value NUMERIC(10, 2) NOT NULL CHECK(Value > 0),
+    BuyQuantity INT NOT NULL DEFAULT 0 CHECK(BuyQuantity >= 0),
+    GetQuantity INT NOT NULL DEFAULT 0 CHECK(GetQuantity >= 0),
+    MinSpend NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK(MinSpend >= 0),
+    ProductID INT,
+    CategoryID INT,
+    ValidFrom TIMESTAMPTZ,
+    ValidTo TIMESTAMPTZ,
+    MaxUses INT NOT NULL DEFAULT 0 CHECK(MaxUses >= 0),
+    MaxUsesPerCustomer INT NOT NULL DEFAULT 0 CHECK(MaxUsesPerCustomer >= 0),
+    Active BOOLEAN NOT NULL DEFAULT TRUE,
+    CreatedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (CategoryID) REFERENCES categories(ID) ON DELETE CASCADE
+);
+
+-- Скидки заказа, по строке на примененный промокод. Код сохраняется и после удаления промокода
+CREATE TABLE order_discounts (
+    ID SERIAL PRIMARY KEY,
+    OrderID INT NOT NULL,
+    PromoCodeID INT,
+    Code VARCHAR(30) NOT NULL,
+    Description TEXT,
+    Amount NUMERIC(10, 2) NOT NULL CHECK(Amount >= 0),
+    FOREIGN KEY (OrderID) REFERENCES orders(ID) ON DELETE CASCADE,
+    FOREIGN KEY (PromoCodeID) REFERENCES promo_codes(ID) ON DELETE SET NULL
+);
+
+-- Фактически списанные ингредиенты по каждой позиции заказа
+CREATE TABLE order_item_ingredients (
+    OrderItemID INT,
+    IngredientID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity >= 0),
+    UnitCost NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(UnitCost >= 0), -- себестоимость единицы на момент продажи
+    PRIMARY KEY (OrderItemID, IngredientID),
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
+);
+
+-- Партии, из которых продана позиция заказа; при отмене остаток возвращается в те же партии
+CREATE TABLE order_item_lots (
+    OrderItemID INT NOT NULL,
+    LotID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (OrderItemID, LotID),
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (LotID) REFERENCES inventory_lots(ID) ON DELETE CASCADE
+);
+
 CREATE TABLE order_status_history (
     ID SERIAL PRIMARY KEY,
     OrderID INT NOT NULL,
-    OpenedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    ClosedAt TIMESTAMP,
+    FromStatus order_status,
+    ToStatus order_status NOT NULL,
+    Actor VARCHAR(50) NOT NULL DEFAULT 'system',
+    ChangedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (OrderID) REFERENCES orders(ID)
 );
 
@@ -70,12 +342,101 @@ CREATE TABLE inventory_transactions (
     transactionId SERIAL PRIMARY KEY,
     IngredientID INT REFERENCES inventory(IngredientID) ON DELETE CASCADE,
     quantity_change FLOAT NOT NULL,
-    reason TEXT,
+    reason inventory_reason NOT NULL DEFAULT 'adjustment',
+    reference TEXT, -- документ, вызвавший изменение, например 'order:12' или 'purchase_order:3'
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Списания. Позиции меню раскладываются на ингредиенты по рецепту, каждая строка хранит себестоимость на момент списания
+CREATE TABLE waste_logs (
+    ID SERIAL PRIMARY KEY,
+    Reason waste_reason NOT NULL,
+    Notes TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE waste_log_items (
+    ID SERIAL PRIMARY KEY,
+    WasteID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    MenuItemID INT, -- позиция меню, из которой получено списание ингредиента
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Cost NUMERIC(10, 4) NOT NULL DEFAULT 0,
+    FOREIGN KEY (WasteID) REFERENCES waste_logs(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE,
+    FOREIGN KEY (MenuItemID) REFERENCES menu_items(ID) ON DELETE SET NULL
+);
+
+-- Инвентаризации. Пока сессия открыта, подсчеты можно перезаписывать; при проведении
+-- остатки заменяются подсчитанными, а системное количество сохраняется для отчета о расхождениях
+CREATE TABLE stocktakes (
+    ID SERIAL PRIMARY KEY,
+    Status stocktake_status NOT NULL DEFAULT 'open',
+    Notes TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CommittedAt TIMESTAMP
+);
+
+CREATE TABLE stocktake_counts (
+    StocktakeID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    CountedQuantity INT NOT NULL CHECK(CountedQuantity >= 0),
+    SystemQuantity INT, -- остаток в системе на момент проведения
+    PRIMARY KEY (StocktakeID, IngredientID),
+    FOREIGN KEY (StocktakeID) REFERENCES stocktakes(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Единицы конкретного ингредиента, например 1 bag = 1000 g. ToUnit - стандартная единица той же размерности, что и складская
+CREATE TABLE ingredient_unit_conversions (
+    IngredientID INT NOT NULL,
+    Unit VARCHAR(20) NOT NULL,
+    Quantity NUMERIC(12, 4) NOT NULL CHECK(Quantity > 0),
+    ToUnit VARCHAR(10) NOT NULL,
+    PRIMARY KEY (IngredientID, Unit),
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+CREATE TABLE suppliers (
+    ID SERIAL PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL,
+    ContactName VARCHAR(50),
+    Phone VARCHAR(20),
+    Email VARCHAR(100)
+);
+
+-- Заказы поставщикам. Строки принимаются частями, статус считается по принятому количеству
+CREATE TABLE purchase_orders (
+    ID SERIAL PRIMARY KEY,
+    SupplierID INT NOT NULL,
+    Status purchase_order_status NOT NULL DEFAULT 'draft',
+    Notes TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UpdatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (SupplierID) REFERENCES suppliers(ID)
+);
+
+CREATE TABLE purchase_order_items (
+    ID SERIAL PRIMARY KEY,
+    PurchaseOrderID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    ReceivedQuantity INT NOT NULL DEFAULT 0 CHECK(ReceivedQuantity >= 0 AND ReceivedQuantity <= Quantity),
+    UnitCost NUMERIC(10, 4) NOT NULL DEFAULT 0 CHECK(UnitCost >= 0),
+    UNIQUE (PurchaseOrderID, IngredientID),
+    FOREIGN KEY (PurchaseOrderID) REFERENCES purchase_orders(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID)
+);
+
+ALTER TABLE inventory_lots
+ADD FOREIGN KEY (PurchaseOrderID) REFERENCES purchase_orders(ID) ON DELETE SET NULL;
+
 -- menu_items
 CREATE INDEX idx_menu_items_name ON menu_items (Name);
+CREATE INDEX idx_menu_items_category_id ON menu_items (CategoryID);
+
+-- categories
+CREATE INDEX idx_categories_parent_id ON categories (ParentID);
 
 -- inventory
 CREATE INDEX idx_inventory_name ON inventory (Name);
@@ -89,6 +450,51 @@ CREATE INDEX idx_orders_created_at ON orders (CreatedAt);
 CREATE INDEX idx_order_items_order_id ON order_items (OrderID);
 CREATE INDEX idx_order_items_product_id ON order_items (ProductID);
 
+-- menu_item_variants
+CREATE INDEX idx_menu_item_variants_menu_id ON menu_item_variants (MenuID);
+
+-- modifiers
+CREATE INDEX idx_modifier_groups_menu_id ON modifier_groups (MenuID);
+CREATE INDEX idx_modifiers_group_id ON modifiers (GroupID);
+CREATE INDEX idx_order_item_modifiers_order_item_id ON order_item_modifiers (OrderItemID);
+
+-- bundles
+CREATE INDEX idx_bundle_slots_bundle_id ON bundle_slots (BundleID);
+CREATE INDEX idx_bundle_slot_options_menu_item_id ON bundle_slot_options (MenuItemID);
+CREATE INDEX idx_order_item_components_order_item_id ON order_item_components (OrderItemID);
+CREATE INDEX idx_order_item_components_product_id ON order_item_components (ProductID);
+
+-- order_status_history
+CREATE INDEX idx_order_status_history_order_id ON order_status_history (OrderID);
+
+-- inventory_transactions
+CREATE INDEX idx_inventory_transactions_ingredient_id ON inventory_transactions (IngredientID, created_at);
+CREATE INDEX idx_inventory_transactions_created_at ON inventory_transactions (created_at);
+
+-- waste_logs
+CREATE INDEX idx_waste_logs_created_at ON waste_logs (CreatedAt);
+CREATE INDEX idx_waste_log_items_waste_id ON waste_log_items (WasteID);
+
+-- purchase_orders
+CREATE INDEX idx_purchase_orders_supplier_id ON purchase_orders (SupplierID);
+CREATE INDEX idx_purchase_orders_status ON purchase_orders (Status);
+CREATE INDEX idx_purchase_order_items_purchase_order_id ON purchase_order_items (PurchaseOrderID);
+
+-- prepared_ingredient_components
+CREATE INDEX idx_prepared_ingredient_components_ingredient_id ON prepared_ingredient_components (IngredientID);
+
+-- production_batches
+CREATE INDEX idx_production_batches_ingredient_id ON production_batches (IngredientID, CreatedAt);
+
+-- order_discounts
+CREATE INDEX idx_order_discounts_order_id ON order_discounts (OrderID);
+CREATE INDEX idx_order_discounts_promo_code_id ON order_discounts (PromoCodeID);
+
+-- inventory_lots
+CREATE INDEX idx_inventory_lots_ingredient_id ON inventory_lots (IngredientID, ExpiresAt, ReceivedAt) WHERE Quantity > 0;
+CREATE INDEX idx_inventory_lots_expires_at ON inventory_lots (ExpiresAt) WHERE Quantity > 0;
+CREATE INDEX idx_order_item_lots_lot_id ON order_item_lots (LotID);
+
 -- menu_item_ingredients
 CREATE INDEX idx_menu_item_ingredients_menu_id ON menu_item_ingredients (MenuID);
 CREATE INDEX idx_menu_item_ingredients_ingredient_id ON menu_item_ingredients (IngredientID);
@@ -115,12 +521,13 @@ FOR EACH ROW
 EXECUTE FUNCTION log_price_change();
 
 -- Функция для логирования изменения статуса заказа
--- Создание функции для триггера при вставке в orders
+-- Создание функции для триггера при вставке в orders.
+-- Последующие переходы статусов записываются приложением вместе с исполнителем.
 CREATE OR REPLACE FUNCTION insert_order_status_history()
 RETURNS TRIGGER AS $$
 BEGIN
-    INSERT INTO order_status_history (OrderID, OpenedAt)
-    VALUES (NEW.ID, CURRENT_TIMESTAMP);
+    INSERT INTO order_status_history (OrderID, ToStatus, ChangedAt)
+    VALUES (NEW.ID, NEW.Status, NEW.CreatedAt);
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
@@ -130,36 +537,23 @@ AFTER INSERT ON orders
 FOR EACH ROW
 EXECUTE FUNCTION insert_order_status_history();
 
--- Функция для логирования изменения статуса заказа
--- Создание функции для триггера при обновлении в orders
-CREATE OR REPLACE FUNCTION update_order_status_history()
-RETURNS TRIGGER AS $$
-BEGIN
-    UPDATE order_status_history
-    SET ClosedAt = CURRENT_TIMESTAMP
-    WHERE OrderID = NEW.ID AND ClosedAt IS NULL;
-    RETURN NEW;
-END;
-$$ LANGUAGE plpgsql;
-
-CREATE TRIGGER after_update_orders
-AFTER UPDATE ON orders
-FOR EACH ROW
-EXECUTE FUNCTION update_order_status_history();
-
 
 --Автоматическое логирование в inventory_transactions.
+-- Приложение может указать причину и документ изменения в рамках транзакции через
+-- set_config('frappuccino.inventory_reason', ..., true) и set_config('frappuccino.inventory_reference', ..., true),
+-- иначе пишется 'adjustment' (ручное изменение).
 CREATE OR REPLACE FUNCTION log_inventory_transaction()
 RETURNS TRIGGER AS $$
 BEGIN
 
     IF TG_OP = 'UPDATE' THEN
         IF NEW.quantity <> OLD.quantity THEN
-            INSERT INTO inventory_transactions(IngredientID, quantity_change, reason, created_at)
+            INSERT INTO inventory_transactions(IngredientID, quantity_change, reason, reference, created_at)
             VALUES (
                 OLD.IngredientID,
                 NEW.quantity - OLD.quantity,
-                'Inventory adjustment',
+                COALESCE(NULLIF(current_setting('frappuccino.inventory_reason', true), ''), 'adjustment')::inventory_reason,
+                NULLIF(current_setting('frappuccino.inventory_reference', true), ''),
                 CURRENT_TIMESTAMP
             );
         END IF;
@@ -169,7 +563,7 @@ BEGIN
         VALUES (
             NEW.IngredientID,
             NEW.quantity,
-            'Initial stock',
+            'initial_stock',
             CURRENT_TIMESTAMP
         );
     END IF;
@@ -187,32 +581,96 @@ EXECUTE FUNCTION log_inventory_transaction();
 
 
 
+-- Mock data for categories
+INSERT INTO categories (Name, ParentID, DisplayOrder) VALUES
+('Drinks', NULL, 1),
+('Coffee', 1, 1),
+('Food', NULL, 2),
+('Pastries', 3, 1),
+('Cakes', 3, 2);
+
+
 -- Mock data for menu_items
-INSERT INTO menu_items (Name, Description, Price) VALUES
-('Caffe Latte', 'Espresso with steamed milk', 3.50),
-('Blueberry Muffin', 'Freshly baked muffin with blueberries', 2.00),
-('Espresso', 'Strong and bold coffee', 2.50),
-('Cappuccino', 'Espresso with steamed milk and foam', 3.00),
-('Mocha', 'Espresso with steamed milk and chocolate', 3.75),
-('Iced Latte', 'Iced espresso with milk', 3.80),
-('Americano', 'Espresso diluted with hot water', 2.80),
-('Carrot Cake', 'Delicious spiced cake with cream cheese frosting', 2.50),
-('Vanilla Latte', 'Espresso with steamed milk and vanilla syrup', 3.60),
-('Chocolate Croissant', 'Flaky croissant with chocolate filling', 2.80);
+INSERT INTO menu_items (Name, Description, Price, CategoryID) VALUES
+('Caffe Latte', 'Espresso with steamed milk', 3.50, 2),
+('Blueberry Muffin', 'Freshly baked muffin with blueberries', 2.00, 4),
+('Espresso', 'Strong and bold coffee', 2.50, 2),
+('Cappuccino', 'Espresso with steamed milk and foam', 3.00, 2),
+('Mocha', 'Espresso with steamed milk and chocolate', 3.75, 2),
+('Iced Latte', 'Iced espresso with milk', 3.80, 2),
+('Americano', 'Espresso diluted with hot water', 2.80, 2),
+('Carrot Cake', 'Delicious spiced cake with cream cheese frosting', 2.50, 5),
+('Vanilla Latte', 'Espresso with steamed milk and vanilla syrup', 3.60, 2),
+('Chocolate Croissant', 'Flaky croissant with chocolate filling', 2.80, 4),
+('Latte & Croissant', 'A coffee of your choice with a chocolate croissant', 5.50, NULL);
 
 
 -- Mock data for inventory
-INSERT INTO inventory (Name, Quantity, Unit) VALUES
-('Espresso Shot', 500, 'shots'),
-('Milk', 5000, 'ml'),
-('Flour', 10000, 'g'),
-('Blueberries', 2000, 'g'),
-('Sugar', 5000, 'g'),
-('Butter', 3000, 'g'),
-('Chocolate', 1500, 'g'),
-('Coffee Beans', 2000, 'g'),
-('Cocoa Powder', 1000, 'g'),
-('Vanilla Syrup', 800, 'ml');
+INSERT INTO inventory (Name, Quantity, Unit, ReorderLevel, ParLevel, UnitCost, Allergens, Dietary, Calories, Protein, Fat, Carbohydrates, Sugar) VALUES
+('Espresso Shot', 500, 'shots', 100, 600, 0.1500, '{}', '{vegan,vegetarian,halal}', 1.0000, 0.1000, 0.0000, 0.0000, 0.0000),
+('Milk', 5000, 'ml', 1000, 6000, 0.0010, '{dairy}', '{vegetarian,halal}', 0.6400, 0.0340, 0.0360, 0.0480, 0.0480),
+('Flour', 10000, 'g', 2000, 10000, 0.0008, '{gluten}', '{vegan,vegetarian,halal}', 3.6400, 0.1030, 0.0100, 0.7630, 0.0030),
+('Blueberries', 2000, 'g', 400, 2000, 0.0120, '{}', '{vegan,vegetarian,halal}', 0.5700, 0.0074, 0.0033, 0.1450, 0.1000),
+('Sugar', 5000, 'g', 1000, 5000, 0.0015, '{}', '{vegan,vegetarian,halal}', 3.8700, 0.0000, 0.0000, 1.0000, 1.0000),
+('Butter', 3000, 'g', 500, 3000, 0.0090, '{dairy}', '{vegetarian,halal}', 7.1700, 0.0085, 0.8100, 0.0006, 0.0006),
+('Chocolate', 1500, 'g', 300, 1500, 0.0100, '{dairy,soy,nuts}', '{vegetarian,halal}', 5.4600, 0.0490, 0.3100, 0.6100, 0.4800),
+('Coffee Beans', 2000, 'g', 500, 2500, 0.0200, '{}', '{vegan,vegetarian,halal}', 0.0200, 0.0010, 0.0000, 0.0000, 0.0000),
+('Cocoa Powder', 1000, 'g', 200, 1000, 0.0110, '{}', '{vegan,vegetarian,halal}', 2.2800, 0.1960, 0.1370, 0.5790, 0.0175),
+('Vanilla Syrup', 800, 'ml', 200, 1000, 0.0060, '{}', '{vegan,vegetarian,halal}', 0, 0, 0, 0, 0),
+('Oat Milk', 3000, 'ml', 600, 3000, 0.0025, '{gluten}', '{vegan,vegetarian,halal}', 0.4600, 0.0100, 0.0150, 0.0660, 0.0400),
+('Cold Brew', 2000, 'ml', 500, 3000, 0.0022, '{}', '{vegan,vegetarian,halal}', 0, 0, 0, 0, 0);
+
+
+-- Mock data for ingredient_unit_conversions
+INSERT INTO ingredient_unit_conversions (IngredientID, Unit, Quantity, ToUnit) VALUES
+(3, 'bag', 25, 'kg'),  -- Flour
+(5, 'bag', 1, 'kg'),  -- Sugar
+(8, 'bag', 1, 'kg'),  -- Coffee Beans
+(2, 'carton', 1, 'l'),  -- Milk
+(11, 'carton', 1, 'l');  -- Oat Milk
+
+
+-- Mock data for prepared_ingredients
+INSERT INTO prepared_ingredients (IngredientID, Yield) VALUES
+(10, 1000),  -- Vanilla Syrup
+(12, 1000);  -- Cold Brew
+
+INSERT INTO prepared_ingredient_components (PreparedID, IngredientID, Quantity) VALUES
+(10, 5, 600),  -- Vanilla Syrup: Sugar
+(12, 8, 110);  -- Cold Brew: Coffee Beans
+
+
+-- Mock data for suppliers
+INSERT INTO suppliers (Name, ContactName, Phone, Email) VALUES
+('Bean Brothers', 'Arman', '+77010000001', 'orders@beanbrothers.kz'),
+('Dairy Farm', 'Aigerim', '+77010000002', 'sales@dairyfarm.kz'),
+('Bakery Supply', 'Dias', '+77010000003', 'info@bakerysupply.kz');
+
+
+-- Mock data for purchase_orders
+INSERT INTO purchase_orders (SupplierID, Status, Notes, CreatedAt, UpdatedAt) VALUES
+(1, 'received', 'Monthly beans', '2025-01-03 10:00:00', '2025-01-05 09:00:00'),
+(2, 'sent', 'Weekly milk', '2025-01-10 10:00:00', '2025-01-10 12:00:00'),
+(3, 'draft', NULL, '2025-01-12 10:00:00', '2025-01-12 10:00:00');
+
+INSERT INTO purchase_order_items (PurchaseOrderID, IngredientID, Quantity, ReceivedQuantity, UnitCost) VALUES
+(1, 8, 2000, 2000, 0.0200),  -- Coffee Beans
+(1, 1, 300, 300, 0.1500),  -- Espresso Shot
+(2, 2, 6000, 0, 0.0010),  -- Milk
+(2, 11, 3000, 0, 0.0025),  -- Oat Milk
+(3, 3, 10000, 0, 0.0008),  -- Flour
+(3, 6, 3000, 0, 0.0090);  -- Butter
+
+
+-- Mock data for inventory_lots
+INSERT INTO inventory_lots (IngredientID, PurchaseOrderID, ReceivedQuantity, Quantity, ReceivedAt, ExpiresAt) VALUES
+(8, 1, 2000, 1500, '2025-01-05 09:00:00', NULL),  -- Coffee Beans
+(2, NULL, 3000, 2000, NOW() - INTERVAL '5 days', NOW() + INTERVAL '1 day'),  -- Milk
+(2, NULL, 3000, 3000, NOW() - INTERVAL '1 day', NOW() + INTERVAL '6 days'),  -- Milk
+(11, NULL, 3000, 3000, NOW() - INTERVAL '3 days', NOW() + INTERVAL '30 hours'),  -- Oat Milk
+(4, NULL, 1000, 500, NOW() - INTERVAL '4 days', NOW() - INTERVAL '6 hours'),  -- Blueberries
+(4, NULL, 1500, 1500, NOW() - INTERVAL '1 day', NOW() + INTERVAL '3 days'),  -- Blueberries
+(6, NULL, 3000, 3000, NOW() - INTERVAL '2 days', NOW() + INTERVAL '20 days');  -- Butter
 
 
 -- Mock data for menu_item_ingredients
@@ -238,37 +696,91 @@ INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity) VALUES
 (10, 7, 50);  -- Chocolate Croissant: 50 g Chocolate
 
 
+-- Mock data for menu_item_variants
+INSERT INTO menu_item_variants (MenuID, Name, Price, RecipeMultiplier) VALUES
+(1, 'S', 3.00, 0.75),  -- Caffe Latte
+(1, 'M', 3.50, 1),
+(1, 'L', 4.20, 1.5),
+(4, 'S', 2.60, 0.75),  -- Cappuccino
+(4, 'M', 3.00, 1),
+(4, 'L', 3.60, 1.5);
+
+-- Mock data for modifiers
+INSERT INTO modifier_groups (MenuID, Name, MinSelect, MaxSelect) VALUES
+(1, 'Milk', 0, 1),  -- Caffe Latte
+(1, 'Extras', 0, 2),  -- Caffe Latte
+(4, 'Milk', 0, 1);  -- Cappuccino
+
+INSERT INTO modifiers (GroupID, Name, PriceDelta) VALUES
+(1, 'Oat milk', 0.50),
+(2, 'Extra shot', 0.70),
+(2, 'Vanilla syrup', 0.40),
+(3, 'Oat milk', 0.50);
+
+INSERT INTO modifier_ingredients (ModifierID, IngredientID, QuantityDelta) VALUES
+(1, 2, -200),  -- Oat milk: no Milk
+(1, 11, 200),  -- Oat milk: 200 ml Oat Milk
+(2, 1, 1),  -- Extra shot: 1 Espresso Shot
+(3, 10, 20),  -- Vanilla syrup: 20 ml Vanilla Syrup
+(4, 2, -200),  -- Oat milk: no Milk
+(4, 11, 200);  -- Oat milk: 200 ml Oat Milk
+
+-- Mock data for bundle_slots
+INSERT INTO bundle_slots (BundleID, Name, Quantity) VALUES
+(11, 'Drink', 1),  -- Latte & Croissant
+(11, 'Pastry', 1);
+
+INSERT INTO bundle_slot_options (SlotID, MenuItemID, PriceDelta) VALUES
+(1, 1, 0),  -- Drink: Caffe Latte
+(1, 4, 0),  -- Drink: Cappuccino
+(1, 9, 0.30),  -- Drink: Vanilla Latte
+(2, 10, 0);  -- Pastry: Chocolate Croissant
+
+-- Mock data for promo_codes
+INSERT INTO promo_codes (Code, Description, DiscountType, Value, BuyQuantity, GetQuantity, MinSpend, CategoryID, MaxUses, MaxUsesPerCustomer) VALUES
+('WELCOME10', '10% off the first order', 'percentage', 10, 0, 0, 0, NULL, 0, 1),
+('COFFEE2FOR1', 'Buy one coffee, get one free', 'buy_x_get_y', 100, 1, 1, 0, 2, 0, 0),  -- Coffee
+('TREAT2', '2.00 off orders from 10.00', 'fixed', 2, 0, 0, 10, NULL, 100, 0);
+
+-- Mock data for pricing_rules
+INSERT INTO pricing_rules (Name, CategoryID, Weekdays, StartTime, EndTime, AdjustmentType, Value, Priority) VALUES
+('Happy hour', 1, '{1,2,3,4,5}', '15:00', '17:00', 'percentage', -20, 10),  -- Drinks
+('Evening pastries', 4, '{}', '19:00', '21:00', 'fixed', -0.50, 0);  -- Pastries
+
+
 -- Mock data for orders 
 --2024
 INSERT INTO orders (CustomerName, Status, Notes, CreatedAt) VALUES
-('sanzhar', 'open', '{"notes": "No sugar, extra hot"}', '2024-12-01 08:45:00'),
-('aqqnoor', 'open', '{"notes": "Double espresso"}', '2024-12-02 09:30:00'),
-('brucewayne', 'open', '{"notes": "Extra chocolate syrup"}', '2024-12-03 10:00:00'),
-('johndoe', 'open', '{"notes": "No foam, extra strong"}', '2024-12-05 11:00:00'),
-('janedoe', 'open', '{"notes": "Add whipped cream"}', '2024-12-06 12:00:00'),
-('pparker', 'open', '{"notes": "Light milk foam"}', '2024-12-07 13:30:00'),
-('supercustomer', 'open', '{"notes": "Less sugar, extra vanilla syrup"}', '2024-12-10 14:45:00'),
-('mmoldabe', 'open', '{"notes": "More coffee, less ice"}', '2024-12-12 16:00:00'),
-('tzhakupo', 'open', '{"notes": "Cinnamon topping"}', '2024-12-15 17:30:00'),
-('akakimbe', 'open', '{"notes": "Extra traktor"}', '2024-12-17 18:00:00');
+('sanzhar', 'pending', '{"notes": "No sugar, extra hot"}', '2024-12-01 08:45:00'),
+('aqqnoor', 'pending', '{"notes": "Double espresso"}', '2024-12-02 09:30:00'),
+('brucewayne', 'pending', '{"notes": "Extra chocolate syrup"}', '2024-12-03 10:00:00'),
+('johndoe', 'pending', '{"notes": "No foam, extra strong"}', '2024-12-05 11:00:00'),
+('janedoe', 'pending', '{"notes": "Add whipped cream"}', '2024-12-06 12:00:00'),
+('pparker', 'pending', '{"notes": "Light milk foam"}', '2024-12-07 13:30:00'),
+('supercustomer', 'pending', '{"notes": "Less sugar, extra vanilla syrup"}', '2024-12-10 14:45:00'),
+('mmoldabe', 'pending', '{"notes": "More coffee, less ice"}', '2024-12-12 16:00:00'),
+('tzhakupo', 'pending', '{"notes": "Cinnamon topping"}', '2024-12-15 17:30:00'),
+('akakimbe', 'pending', '{"notes": "Extra traktor"}', '2024-12-17 18:00:00');
 
 -- 2025
 INSERT INTO orders (CustomerName, Status, Notes, CreatedAt) VALUES
-('Kimberly Blue', 'closed', '{"notes": "Hot and strong"}', '2025-01-02 09:00:00'),
-('Liam Green', 'closed', '{"notes": "Cold milk, no sugar"}', '2025-01-04 09:30:00'),
-('Megan Black', 'closed', '{"notes": "Extra foam and cinnamon"}', '2025-01-05 10:15:00'),
-('Nina Yellow', 'closed', '{"notes": "Extra hot and vanilla syrup"}', '2025-01-06 11:45:00'),
-('Oliver White', 'closed', '{"notes": "Less milk, extra coffee"}', '2025-01-07 12:00:00'),
-('Peter Red', 'closed', '{"notes": "No whipped cream, add syrup"}', '2025-01-08 13:00:00'),
-('Quincy Purple', 'closed', '{"notes": "Iced coffee, extra shot"}', '2025-01-10 14:00:00'),
-('Rebecca Grey', 'closed', '{"notes": "Add caramel"}', '2025-01-11 15:30:00'),
-('Steve Brown', 'closed', '{"notes": "Add extra ice"}', '2025-01-12 16:45:00'),
-('Tina Pink', 'closed', '{"notes": "No milk, extra strong"}', '2025-01-13 17:00:00');
+('Kimberly Blue', 'picked_up', '{"notes": "Hot and strong"}', '2025-01-02 09:00:00'),
+('Liam Green', 'picked_up', '{"notes": "Cold milk, no sugar"}', '2025-01-04 09:30:00'),
+('Megan Black', 'picked_up', '{"notes": "Extra foam and cinnamon"}', '2025-01-05 10:15:00'),
+('Nina Yellow', 'picked_up', '{"notes": "Extra hot and vanilla syrup"}', '2025-01-06 11:45:00'),
+('Oliver White', 'picked_up', '{"notes": "Less milk, extra coffee"}', '2025-01-07 12:00:00'),
+('Peter Red', 'picked_up', '{"notes": "No whipped cream, add syrup"}', '2025-01-08 13:00:00'),
+('Quincy Purple', 'picked_up', '{"notes": "Iced coffee, extra shot"}', '2025-01-10 14:00:00'),
+('Rebecca Grey', 'picked_up', '{"notes": "Add caramel"}', '2025-01-11 15:30:00'),
+('Steve Brown', 'picked_up', '{"notes": "Add extra ice"}', '2025-01-12 16:45:00'),
+('Tina Pink', 'picked_up', '{"notes": "No milk, extra strong"}', '2025-01-13 17:00:00');
 
 
 
 -- 2024
-INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
+INSERT INTO order_items (OrderID, ProductID, Quantity, UnitPrice, LineTotal)
+SELECT v.OrderID, v.ProductID, v.Quantity, mi.Price, mi.Price * v.Quantity
+FROM (VALUES
 (1, 1, 1),  -- sanzhar: 1 Caffe Latte
 (1, 2, 1),  -- sanzhar: 1 Blueberry Muffin
 (2, 1, 2),  -- aqqnoor: 2 Espresso
@@ -280,10 +792,14 @@ INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
 (7, 9, 1),  -- supercustomer: 1 Vanilla Latte
 (8, 10, 2), -- mmoldabe: 2 Chocolate Croissants
 (9, 4, 1),  -- tzhakupo: 1 Cappuccino
-(10, 1, 2); -- akakimbe: 2 Espresso
+(10, 1, 2)  -- akakimbe: 2 Espresso
+) AS v(OrderID, ProductID, Quantity)
+JOIN menu_items mi ON mi.ID = v.ProductID;
 
 -- 2025
-INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
+INSERT INTO order_items (OrderID, ProductID, Quantity, UnitPrice, LineTotal)
+SELECT v.OrderID, v.ProductID, v.Quantity, mi.Price, mi.Price * v.Quantity
+FROM (VALUES
 (11, 2, 1),  -- Kimberly: 1 Blueberry Muffin
 (12, 1, 2),  -- Liam: 2 Caffe Latte
 (13, 5, 1),  -- Megan: 1 Mocha
@@ -294,4 +810,22 @@ INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
 (17, 10, 2),  -- Quincy: 2 Chocolate Croissants
 (18, 2, 1),  -- Rebecca: 1 Blueberry Muffin
 (19, 3, 1),  -- Steve: 1 Espresso
-(20, 9, 1);  -- Tina: 1 Vanilla Latte
+(20, 9, 1)  -- Tina: 1 Vanilla Latte
+) AS v(OrderID, ProductID, Quantity)
+JOIN menu_items mi ON mi.ID = v.ProductID;
+
+INSERT INTO order_item_ingredients (OrderItemID, IngredientID, Quantity, UnitCost)
+SELECT oi.ID, mii.IngredientID, mii.Quantity * oi.Quantity, i.UnitCost
+FROM order_items oi
+JOIN menu_item_ingredients mii ON mii.MenuID = oi.ProductID
+JOIN inventory i ON i.IngredientID = mii.IngredientID;
+
+UPDATE orders o
+SET Total = items.total
+FROM (
+    SELECT OrderID, SUM(LineTotal) AS total
+    FROM order_items
+    GROUP BY OrderID
+) items
+WHERE o.ID = items.OrderID;
+
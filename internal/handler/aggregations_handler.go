@@ -2,11 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/sunzhqr/frappuccino/internal/models"
 	"github.com/sunzhqr/frappuccino/internal/service"
 	"github.com/sunzhqr/frappuccino/pkg/response"
 )
@@ -21,15 +23,24 @@ func NewAggregationHandler(orderService service.OrderServiceInterface, aggregati
 	return &AggregationHandler{orderService: orderService, aggregationService: aggregationService, logger: logger}
 }
 
-// Return all saled items as key and quantity as value in JSON
+/*
+GET /reports/total-sales?from=2025-01-01&to=2025-01-31&status=picked_up&groupBy=day:
+Returns gross sales, number of orders, average order value and units sold.
+All parameters are optional. Cancelled orders are skipped unless status asks for them.
+*/
 func (h *AggregationHandler) TotalSalesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		response.SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	totalSales, err := h.orderService.GetTotalSales()
+	query := r.URL.Query()
+	totalSales, err := h.aggregationService.GetTotalSales(query.Get("from"), query.Get("to"), query.Get("status"), query.Get("groupBy"))
 	if err != nil {
 		h.logger.Error("Error getting data", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange || err == service.ErrInvalidGroupBy || err == service.ErrUnknownOrderStatus {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		response.SendError(w, "Error getting data", http.StatusInternalServerError)
 		return
 	}
@@ -55,6 +66,90 @@ func (h *AggregationHandler) PopularItemsHandler(w http.ResponseWriter, r *http.
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+// GET /reports/sales-by-category?from=2025-01-01&to=2025-01-31: units sold and revenue per category
+func (h *AggregationHandler) SalesByCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	sales, err := h.aggregationService.GetSalesByCategory(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		h.logger.Error("Error getting sales by category", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.SendError(w, "Error getting sales by category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GET /reports/margins?from=2025-01-01&to=2025-01-31: menu items ranked by contribution margin
+func (h *AggregationHandler) MarginsHandler(w http.ResponseWriter, r *http.Request) {
+	margins, err := h.aggregationService.GetMargins(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		h.logger.Error("Error getting margins", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.SendError(w, "Error getting margins", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(margins)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+/*
+GET /reports/usage-variance?from=2025-01-01&to=2025-01-31:
+Per ingredient usage expected from sold recipes against the stock that actually left,
+with the variance valued at the current unit cost. Largest variance cost first.
+*/
+func (h *AggregationHandler) UsageVarianceHandler(w http.ResponseWriter, r *http.Request) {
+	variance, err := h.aggregationService.GetUsageVariance(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		h.logger.Error("Error getting usage variance", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.SendError(w, "Error getting usage variance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(variance)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+/*
+GET /reports/discounts?from=2025-01-01&to=2025-01-31:
+Number of discounted orders and the total discount, then per promo code the orders and customers
+that used it with the discount given, largest discount first.
+*/
+func (h *AggregationHandler) DiscountsHandler(w http.ResponseWriter, r *http.Request) {
+	discounts, err := h.aggregationService.GetDiscounts(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		h.logger.Error("Error getting discounts", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.SendError(w, "Error getting discounts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discounts)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
 func (h *AggregationHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	searchQuery := r.URL.Query().Get("q")
 	filter := r.URL.Query().Get("filter")
@@ -97,10 +192,10 @@ func (h *AggregationHandler) SearchHandler(w http.ResponseWriter, r *http.Reques
 		MaxPrice = -1
 	}
 
-	searchResult, err := h.aggregationService.Search(searchQuery, MinPrice, MaxPrice, filter)
+	searchResult, err := h.aggregationService.Search(searchQuery, MinPrice, MaxPrice, filter, r.URL.Query().Get("exclude_allergens"))
 	if err != nil {
 		h.logger.Error("Error searching", "method", r.Method, "url", r.URL, "err", err.Error())
-		if err == service.ErrSearchRequired || err == service.ErrWrongFilterOptions || err == service.ErrPriceNotPositive {
+		if err == service.ErrSearchRequired || err == service.ErrWrongFilterOptions || err == service.ErrPriceNotPositive || errors.Is(err, models.ErrUnknownAllergen) {
 			response.SendError(w, err.Error(), http.StatusBadRequest)
 		} else {
 			response.SendError(w, fmt.Sprintf("Error searching %v string", searchQuery), http.StatusInternalServerError)
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type CategoryHandler struct {
+	categoryService service.CategoryServiceInterface
+	logger          *slog.Logger
+}
+
+func NewCategoryHandler(categoryService service.CategoryServiceInterface, logger *slog.Logger) *CategoryHandler {
+	return &CategoryHandler{categoryService: categoryService, logger: logger}
+}
+
+func (h *CategoryHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrCategoryNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrCategoryNameRequired), errors.Is(err, service.ErrCategoryCycle):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *CategoryHandler) PostCategory(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+	if err := decodeJSON(w, r, &category); err != nil {
+		return
+	}
+
+	created, err := h.categoryService.AddCategory(category)
+	if err != nil {
+		h.handleError(w, r, err, "Could not add category")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, created, "Category created successfully", http.StatusCreated)
+}
+
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryService.GetCategories()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get categories")
+		return
+	}
+
+	response.SendSuccess(w, categories, "Categories fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Category id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.categoryService.GetCategory(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get category")
+		return
+	}
+
+	response.SendSuccess(w, category, "Category fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *CategoryHandler) PutCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Category id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var category models.Category
+	if err := decodeJSON(w, r, &category); err != nil {
+		return
+	}
+	category.ID = id
+
+	if err = h.categoryService.UpdateCategory(category); err != nil {
+		h.handleError(w, r, err, "Could not update category")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, nil, "Category updated successfully", http.StatusOK)
+}
+
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Category id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err = h.categoryService.DeleteCategory(id); err != nil {
+		h.handleError(w, r, err, "Could not delete category")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -35,6 +36,28 @@ func validateInventoryItem(item models.InventoryItem) error {
 	if item.Name == "" || item.Unit == "" || item.Quantity <= 0 {
 		return fmt.Errorf("some fields are empty or invalid")
 	}
+	if !models.IsStockUnit(item.Unit) {
+		return fmt.Errorf("unit must be one of g, ml, shots, pcs")
+	}
+	if item.ReorderLevel < 0 || item.ParLevel < 0 || (item.ParLevel > 0 && item.ParLevel < item.ReorderLevel) {
+		return fmt.Errorf("reorder and par levels must be positive, par level not less than reorder level")
+	}
+	if item.UnitCost < 0 {
+		return fmt.Errorf("unit cost must not be negative")
+	}
+	if !item.Nutrition.IsValid() {
+		return fmt.Errorf("nutrition values must not be negative")
+	}
+	for _, allergen := range item.Allergens {
+		if !models.IsAllergen(allergen) {
+			return fmt.Errorf("%w: %q", models.ErrUnknownAllergen, allergen)
+		}
+	}
+	for _, tag := range item.Dietary {
+		if !models.IsDietaryTag(tag) {
+			return fmt.Errorf("unknown dietary tag %q, must be one of vegan, vegetarian, halal", tag)
+		}
+	}
 	return nil
 }
 
@@ -64,9 +87,14 @@ func (h *InventoryHandler) PostInventoryItem(w http.ResponseWriter, r *http.Requ
 	response.SendSuccess(w, nil, "Inventory item created successfully", http.StatusCreated)
 }
 
+// GetInventoryItems returns the inventory. Optional ?unit= shows compatible items in that unit.
 func (h *InventoryHandler) GetInventoryItems(w http.ResponseWriter, r *http.Request) {
-	inventoryItems, err := h.inventoryService.GetAllInventoryItems()
+	inventoryItems, err := h.inventoryService.GetAllInventoryItems(r.URL.Query().Get("unit"))
 	if err != nil {
+		if service.IsUnitError(err) {
+			h.handleError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
 		h.handleError(w, err, "Could not get inventory items", http.StatusInternalServerError)
 		return
 	}
@@ -88,8 +116,12 @@ func (h *InventoryHandler) GetInventoryItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	inventoryItem, err := h.inventoryService.GetItem(id)
+	inventoryItem, err := h.inventoryService.GetItem(id, r.URL.Query().Get("unit"))
 	if err != nil {
+		if service.IsUnitError(err) {
+			h.handleError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
 		h.handleError(w, err, "Could not get inventory item", http.StatusInternalServerError)
 		return
 	}
@@ -167,3 +199,184 @@ func (h *InventoryHandler) GetLeftOvers(w http.ResponseWriter, r *http.Request)
 
 	response.SendSuccess(w, resp, "Leftovers fetched successfully", http.StatusOK)
 }
+
+// GetLowStock returns ingredients at or below their reorder level with the quantity to order up to par level
+func (h *InventoryHandler) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.inventoryService.GetLowStock()
+	if err != nil {
+		h.handleError(w, err, "Could not get low stock items", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, alerts, "Low stock items fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetStockValuation returns the value of the stock on hand at weighted average unit cost
+func (h *InventoryHandler) GetStockValuation(w http.ResponseWriter, r *http.Request) {
+	valuation, err := h.inventoryService.GetStockValuation()
+	if err != nil {
+		h.handleError(w, err, "Could not get stock valuation", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, valuation, "Stock valuation fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetTransactions returns the inventory ledger of all ingredients.
+// Query parameters: from, to (YYYY-MM-DD), reason (comma separated), page, pageSize.
+func (h *InventoryHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	h.sendTransactions(w, r, 0)
+}
+
+// GetIngredientTransactions returns the inventory ledger of a single ingredient, filtered like GetTransactions
+func (h *InventoryHandler) GetIngredientTransactions(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.handleError(w, err, fmt.Sprint("Inventory id must be integer "+idStr), http.StatusBadRequest)
+		return
+	}
+
+	if !h.inventoryService.Exists(id) {
+		h.handleError(w, fmt.Errorf("inventory item does not exist"), "Inventory item does not exist", http.StatusNotFound)
+		return
+	}
+	h.sendTransactions(w, r, id)
+}
+
+func (h *InventoryHandler) sendTransactions(w http.ResponseWriter, r *http.Request, ingredientID int) {
+	query := r.URL.Query()
+	transactions, err := h.inventoryService.GetTransactions(ingredientID, query.Get("from"), query.Get("to"), query.Get("reason"), query.Get("page"), query.Get("pageSize"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDateRange) || errors.Is(err, service.ErrUnknownInventoryReason) || errors.Is(err, service.ErrInvalidPagination) {
+			h.handleError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.handleError(w, err, "Could not get inventory transactions", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, transactions, "Inventory transactions fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetConversions returns the custom units defined for the ingredient
+func (h *InventoryHandler) GetConversions(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.handleError(w, err, fmt.Sprint("Inventory id must be integer "+idStr), http.StatusBadRequest)
+		return
+	}
+
+	if !h.inventoryService.Exists(id) {
+		h.handleError(w, fmt.Errorf("inventory item does not exist"), "Inventory item does not exist", http.StatusNotFound)
+		return
+	}
+
+	conversions, err := h.inventoryService.GetConversions(id)
+	if err != nil {
+		h.handleError(w, err, "Could not get unit conversions", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, conversions, "Unit conversions fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostConversion creates or replaces a custom unit of the ingredient, e.g. {"unit": "bag", "quantity": 25, "toUnit": "kg"}
+func (h *InventoryHandler) PostConversion(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.handleError(w, err, fmt.Sprint("Inventory id must be integer "+idStr), http.StatusBadRequest)
+		return
+	}
+
+	if !h.inventoryService.Exists(id) {
+		h.handleError(w, fmt.Errorf("inventory item does not exist"), "Inventory item does not exist", http.StatusNotFound)
+		return
+	}
+
+	var conversion models.UnitConversion
+	if err := decodeJSON(w, r, &conversion); err != nil {
+		return
+	}
+	conversion.IngredientID = id
+
+	if err := h.inventoryService.SaveConversion(conversion); err != nil {
+		if service.IsUnitError(err) {
+			h.handleError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.handleError(w, err, "Could not save unit conversion", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, nil, "Unit conversion saved successfully", http.StatusCreated)
+}
+
+// DeleteConversion removes a custom unit of the ingredient
+func (h *InventoryHandler) DeleteConversion(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.handleError(w, err, fmt.Sprint("Inventory id must be integer "+idStr), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.DeleteConversion(id, r.PathValue("unit")); err != nil {
+		if errors.Is(err, service.ErrConversionNotFound) {
+			h.handleError(w, err, "Unit conversion not found", http.StatusNotFound)
+			return
+		}
+		h.handleError(w, err, "Could not delete unit conversion", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetLots returns the lots of the ingredient with stock left, in the order they are consumed
+func (h *InventoryHandler) GetLots(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.handleError(w, err, fmt.Sprint("Inventory id must be integer "+idStr), http.StatusBadRequest)
+		return
+	}
+
+	if !h.inventoryService.Exists(id) {
+		h.handleError(w, fmt.Errorf("inventory item does not exist"), "Inventory item does not exist", http.StatusNotFound)
+		return
+	}
+
+	lots, err := h.inventoryService.GetLots(id)
+	if err != nil {
+		h.handleError(w, err, "Could not get inventory lots", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, lots, "Inventory lots fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetExpiringLots returns the lots expiring soon and the expired ones. Optional ?within=48h sets the horizon.
+func (h *InventoryHandler) GetExpiringLots(w http.ResponseWriter, r *http.Request) {
+	lots, err := h.inventoryService.GetExpiringLots(r.URL.Query().Get("within"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWithin) {
+			h.handleError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.handleError(w, err, "Could not get expiring lots", http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w, lots, "Expiring lots fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -2,9 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sunzhqr/frappuccino/internal/models"
 	"github.com/sunzhqr/frappuccino/internal/service"
@@ -28,6 +31,11 @@ func (h *MenuHandler) PostMenuItem(w http.ResponseWriter, r *http.Request) {
 		response.SendError(w, "Could not decode request json data", http.StatusBadRequest)
 		return
 	}
+	if newItem, err = h.menuService.ConvertRecipeUnits(newItem); err != nil {
+		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err = h.menuService.CheckNewMenu(newItem); err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 		response.SendError(w, err.Error(), http.StatusBadRequest)
@@ -49,15 +57,54 @@ func (h *MenuHandler) PostMenuItem(w http.ResponseWriter, r *http.Request) {
 	// Add the new menu item using the service
 	if err = h.menuService.AddMenuItem(newItem); err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		if errors.Is(err, models.ErrInvalidMenuItem) {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		response.SendError(w, "Could not add menu item", http.StatusInternalServerError)
 		return
 	}
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+// GetMenuItems returns the menu. Optional ?category={id} keeps items of the category and its subcategories,
+// ?exclude_allergens=dairy,gluten drops items containing any of the allergens.
 func (h *MenuHandler) GetMenuItems(w http.ResponseWriter, r *http.Request) {
-	MenuItems, err := h.menuService.GetMenuItems()
+	var filter models.MenuFilter
+	if category := r.URL.Query().Get("category"); category != "" {
+		categoryID, err := strconv.Atoi(category)
+		if err != nil || categoryID <= 0 {
+			h.logger.Error("Category id must be positive integer", "method", r.Method, "url", r.URL)
+			response.SendError(w, "Category id must be positive integer", http.StatusBadRequest)
+			return
+		}
+		filter.CategoryID = categoryID
+	}
+	if exclude := r.URL.Query().Get("exclude_allergens"); exclude != "" {
+		filter.ExcludeAllergens = strings.Split(exclude, ",")
+	}
+	if at := r.URL.Query().Get("at"); at != "" {
+		atTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			h.logger.Error("at must be an RFC 3339 timestamp", "method", r.Method, "url", r.URL)
+			response.SendError(w, "at must be an RFC 3339 timestamp, e.g. 2025-01-01T15:30:00Z", http.StatusBadRequest)
+			return
+		}
+		filter.At = &atTime
+	}
+
+	MenuItems, err := h.menuService.GetMenuItems(filter)
 	if err != nil {
+		if errors.Is(err, models.ErrCategoryNotFound) {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			response.SendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrUnknownAllergen) {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		h.logger.Error("Could not read menu database", "error", err, "method", r.Method, "url", r.URL)
 		response.SendError(w, "Could not read menu database", http.StatusInternalServerError)
 		return
@@ -73,6 +120,25 @@ func (h *MenuHandler) GetMenuItems(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonData)
 }
 
+// GetGroupedMenu returns the menu grouped by categories in display order, for the kiosk
+func (h *MenuHandler) GetGroupedMenu(w http.ResponseWriter, r *http.Request) {
+	grouped, err := h.menuService.GetGroupedMenu()
+	if err != nil {
+		h.logger.Error("Could not read menu database", "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, "Could not read menu database", http.StatusInternalServerError)
+		return
+	}
+	jsonData, err := json.MarshalIndent(grouped, "", "    ")
+	if err != nil {
+		h.logger.Error("Could not convert menu to json", "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, "Could not read menu items", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
+
 func (h *MenuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
@@ -106,6 +172,56 @@ func (h *MenuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+// GetMenuItemCost returns food cost and margin of the menu item with a per ingredient breakdown
+func (h *MenuHandler) GetMenuItemCost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	cost, err := h.menuService.GetMenuItemCost(id)
+	if err != nil {
+		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		if err.Error() == "could not find menu item by the given id" {
+			response.SendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		response.SendError(w, "Could not read menu database", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cost)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetMenuItemNutrition returns the per serving nutrition of the menu item, its variants and modifiers
+func (h *MenuHandler) GetMenuItemNutrition(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	nutrition, err := h.menuService.GetMenuItemNutrition(id)
+	if err != nil {
+		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		if err.Error() == "could not find menu item by the given id" {
+			response.SendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		response.SendError(w, "Could not read menu database", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nutrition)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
 func (h *MenuHandler) PutMenuItem(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -130,6 +246,12 @@ func (h *MenuHandler) PutMenuItem(w http.ResponseWriter, r *http.Request) {
 	}
 	RequestedMenuItem.ID = id
 
+	if RequestedMenuItem, err = h.menuService.ConvertRecipeUnits(RequestedMenuItem); err != nil {
+		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	err = h.menuService.CheckNewMenu(RequestedMenuItem)
 	if err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
@@ -146,6 +268,10 @@ func (h *MenuHandler) PutMenuItem(w http.ResponseWriter, r *http.Request) {
 	err = h.menuService.UpdateMenuItem(RequestedMenuItem)
 	if err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		if errors.Is(err, models.ErrInvalidMenuItem) {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		response.SendError(w, "Could not update menu database", http.StatusInternalServerError)
 		return
 	}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -34,22 +35,19 @@ func (h *OrderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Stock is checked when the order is placed, against the recipe of the chosen variant and modifiers
 	for _, OrderItem := range NewOrder.Items {
 		if err = h.menuService.MenuCheckByID(OrderItem.ProductID, true); err != nil {
 			h.logger.Error("Requested order item does not exist in menu", "error", err, "method", r.Method, "url", r.URL)
 			response.SendError(w, "Requested order item does not exist in menu", http.StatusBadRequest)
 			return
 		}
-		if err = h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity); err != nil {
-			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
-			response.SendError(w, err.Error(), http.StatusBadRequest)
-			return
-		}
 	}
 
 	_, _, err = h.orderService.AddOrder(NewOrder)
 	if err != nil {
-		if err.Error() == "something wrong with your requested order" {
+		if err.Error() == "something wrong with your requested order" || errors.Is(err, models.ErrInvalidModifiers) || errors.Is(err, models.ErrInvalidVariant) || errors.Is(err, models.ErrInvalidBundle) ||
+			errors.Is(err, models.ErrPromoCodeNotFound) || errors.Is(err, models.ErrPromoCodeNotApplicable) || errors.Is(err, models.ErrInsufficientInventory) {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			response.SendError(w, err.Error(), http.StatusBadRequest)
 			return
@@ -121,21 +119,19 @@ func (h *OrderHandler) PutOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Stock is checked when the order is placed, against the recipe of the chosen variant and modifiers
 	for _, OrderItem := range RequestedOrder.Items {
 		if err = h.menuService.MenuCheckByID(OrderItem.ProductID, true); err != nil {
 			h.logger.Error("Updated order item does not exist in menu", "error", err, "method", r.Method, "url", r.URL)
 			response.SendError(w, "Updated order item does not exist in menu", http.StatusBadRequest)
 			return
 		}
-		if err = h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity); err != nil {
-			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
-			response.SendError(w, err.Error(), http.StatusBadRequest)
-			return
-		}
 	}
 	err = h.orderService.UpdateOrder(RequestedOrder, r.PathValue("id"))
 	if err != nil {
-		if err.Error() == "could not update the order because it is already closed" || err.Error() == "something wrong with your updated order" || err.Error() == "the requested order does not exist" {
+		if err.Error() == "something wrong with your updated order" || errors.Is(err, models.ErrOrderClosed) || errors.Is(err, models.ErrOrderNotFound) ||
+			errors.Is(err, models.ErrInvalidModifiers) || errors.Is(err, models.ErrInvalidVariant) || errors.Is(err, models.ErrInvalidBundle) ||
+			errors.Is(err, models.ErrPromoCodeNotFound) || errors.Is(err, models.ErrPromoCodeNotApplicable) || errors.Is(err, models.ErrInsufficientInventory) {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			response.SendError(w, err.Error(), http.StatusBadRequest)
 			return
@@ -184,12 +180,132 @@ func (h *OrderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
 	err = h.orderService.CloseOrder(ID)
 	if err != nil {
 		h.logger.Error("Error closing order", "error", err, "method", r.Method, "url", r.URL)
-		response.SendError(w, err.Error(), http.StatusBadRequest)
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			response.SendError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, models.ErrIllegalTransition):
+			response.SendError(w, err.Error(), http.StatusConflict)
+		default:
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+/*
+POST /orders/{id}/transition:
+Moves the order to the next lifecycle status. Body: {"status": "accepted", "actor": "barista"}.
+Allowed path: pending -> accepted -> preparing -> ready -> picked_up, cancelled from any non-final status.
+*/
+func (h *OrderHandler) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var request models.OrderTransitionRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Could not decode request json data", "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.orderService.TransitionOrder(ID, request)
+	if err != nil {
+		h.logger.Error("Error changing order status", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			response.SendError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, models.ErrIllegalTransition):
+			response.SendError(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrUnknownOrderStatus), errors.Is(err, service.ErrActorRequired):
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+		default:
+			response.SendError(w, "Error changing order status", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(change); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+	}
+}
+
+/*
+POST /orders/{id}/cancel:
+Cancels the order and puts the consumed ingredients back to the inventory, and to the lots they were sold from, in one transaction.
+Body: {"actor": "barista"}.
+*/
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var request models.OrderTransitionRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Could not decode request json data", "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.orderService.CancelOrder(ID, request.Actor)
+	if err != nil {
+		h.logger.Error("Error cancelling order", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			response.SendError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, models.ErrIllegalTransition):
+			response.SendError(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrActorRequired):
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+		default:
+			response.SendError(w, "Error cancelling order", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(change); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+	}
+}
+
+// GetOrderStatusHistory returns every status step of the order with its actor and time
+func (h *OrderHandler) GetOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.orderService.GetOrderStatusHistory(ID)
+	if err != nil {
+		h.logger.Error("Error getting order status history", "error", err, "method", r.Method, "url", r.URL)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			response.SendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		response.SendError(w, "Error getting order status history", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+	}
+}
+
 func (h *OrderHandler) GetNumberOfOrdered(w http.ResponseWriter, r *http.Request) {
 	startDate := r.URL.Query().Get("startDate")
 	endDate := r.URL.Query().Get("endDate")
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type PricingHandler struct {
+	pricingService service.PricingServiceInterface
+	logger         *slog.Logger
+}
+
+func NewPricingHandler(pricingService service.PricingServiceInterface, logger *slog.Logger) *PricingHandler {
+	return &PricingHandler{pricingService: pricingService, logger: logger}
+}
+
+func (h *PricingHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrMenuItemNotFound),
+		errors.Is(err, models.ErrScheduledPriceNotFound),
+		errors.Is(err, models.ErrPricingRuleNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidScheduledPrice),
+		errors.Is(err, service.ErrInvalidPricingRule),
+		errors.Is(err, models.ErrCategoryNotFound):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrScheduledPriceApplied):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *PricingHandler) pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error(name+" id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, name+" id must be integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// GetPriceHistory returns the price changes of the menu item, newest first, and its pending scheduled prices
+func (h *PricingHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "Menu")
+	if !ok {
+		return
+	}
+
+	history, err := h.pricingService.GetPriceHistory(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get price history")
+		return
+	}
+
+	response.SendSuccess(w, history, "Price history fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostScheduledPrice schedules a price of the menu item, e.g. {"price": 5.5, "effective_at": "2025-01-01T06:00:00Z"}
+func (h *PricingHandler) PostScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "Menu")
+	if !ok {
+		return
+	}
+
+	var price models.ScheduledPrice
+	if err := decodeJSON(w, r, &price); err != nil {
+		return
+	}
+
+	scheduled, err := h.pricingService.SchedulePrice(id, price)
+	if err != nil {
+		h.handleError(w, r, err, "Could not schedule price")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, scheduled, "Price scheduled successfully", http.StatusCreated)
+}
+
+// GetScheduledPrices returns the pending scheduled prices of the whole menu in the order they take effect
+func (h *PricingHandler) GetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	prices, err := h.pricingService.GetScheduledPrices()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get scheduled prices")
+		return
+	}
+
+	response.SendSuccess(w, prices, "Scheduled prices fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// DeleteScheduledPrice cancels a scheduled price that is not applied yet
+func (h *PricingHandler) DeleteScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "Scheduled price")
+	if !ok {
+		return
+	}
+
+	if err := h.pricingService.CancelScheduledPrice(id); err != nil {
+		h.handleError(w, r, err, "Could not cancel scheduled price")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetRules returns the pricing rules, highest priority first
+func (h *PricingHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.pricingService.GetRules()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get pricing rules")
+		return
+	}
+
+	response.SendSuccess(w, rules, "Pricing rules fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostRule adds a pricing rule, e.g. {"name": "Happy hour", "category_id": 1, "weekdays": [1, 2, 3, 4, 5],
+// "start_time": "15:00", "end_time": "17:00", "adjustment_type": "percentage", "value": -20}
+func (h *PricingHandler) PostRule(w http.ResponseWriter, r *http.Request) {
+	// Rules are active unless the request says otherwise
+	rule := models.PricingRule{Active: true}
+	if err := decodeJSON(w, r, &rule); err != nil {
+		return
+	}
+
+	added, err := h.pricingService.AddRule(rule)
+	if err != nil {
+		h.handleError(w, r, err, "Could not add pricing rule")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, added, "Pricing rule added successfully", http.StatusCreated)
+}
+
+func (h *PricingHandler) PutRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "Pricing rule")
+	if !ok {
+		return
+	}
+
+	// Rules are active unless the request says otherwise
+	rule := models.PricingRule{Active: true}
+	if err := decodeJSON(w, r, &rule); err != nil {
+		return
+	}
+	rule.ID = id
+
+	updated, err := h.pricingService.UpdateRule(rule)
+	if err != nil {
+		h.handleError(w, r, err, "Could not update pricing rule")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, updated, "Pricing rule updated successfully", http.StatusOK)
+}
+
+func (h *PricingHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "Pricing rule")
+	if !ok {
+		return
+	}
+
+	if err := h.pricingService.DeleteRule(id); err != nil {
+		h.handleError(w, r, err, "Could not delete pricing rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type ProductionHandler struct {
+	productionService service.ProductionServiceInterface
+	logger            *slog.Logger
+}
+
+func NewProductionHandler(productionService service.ProductionServiceInterface, logger *slog.Logger) *ProductionHandler {
+	return &ProductionHandler{productionService: productionService, logger: logger}
+}
+
+func (h *ProductionHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrRecipeNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRecipe),
+		errors.Is(err, service.ErrInvalidProduction),
+		service.IsUnitError(err):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrInsufficientInventory):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *ProductionHandler) pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Inventory id must be integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// GetRecipe returns the recipe of a prepared ingredient with costs rolled up through nested recipes
+func (h *ProductionHandler) GetRecipe(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	recipe, err := h.productionService.GetRecipe(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get recipe")
+		return
+	}
+
+	response.SendSuccess(w, recipe, "Recipe fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutRecipe makes the ingredient prepared, e.g. {"yield": 1000, "components": [{"ingredient_id": 5, "quantity": 600}]}
+func (h *ProductionHandler) PutRecipe(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var recipe models.PreparedRecipe
+	if err := decodeJSON(w, r, &recipe); err != nil {
+		return
+	}
+	recipe.IngredientID = id
+
+	saved, err := h.productionService.SaveRecipe(recipe)
+	if err != nil {
+		h.handleError(w, r, err, "Could not save recipe")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, saved, "Recipe saved successfully", http.StatusOK)
+}
+
+// DeleteRecipe turns a prepared ingredient back into a raw one, its stock is kept
+func (h *ProductionHandler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.productionService.DeleteRecipe(id); err != nil {
+		h.handleError(w, r, err, "Could not delete recipe")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostProduce produces batches of a prepared ingredient from stock, e.g. {"batches": 2, "quantity": 1950}
+func (h *ProductionHandler) PostProduce(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.ProductionRequest
+	if err := decodeJSON(w, r, &request); err != nil {
+		return
+	}
+
+	batch, err := h.productionService.Produce(id, request)
+	if err != nil {
+		h.handleError(w, r, err, "Could not produce batch")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, batch, "Batch produced successfully", http.StatusCreated)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type PromoHandler struct {
+	promoService service.PromoServiceInterface
+	logger       *slog.Logger
+}
+
+func NewPromoHandler(promoService service.PromoServiceInterface, logger *slog.Logger) *PromoHandler {
+	return &PromoHandler{promoService: promoService, logger: logger}
+}
+
+func (h *PromoHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrPromoCodeNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidPromoCode),
+		errors.Is(err, models.ErrCategoryNotFound):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrPromoCodeExists):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *PromoHandler) pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Promo code id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Promo code id must be integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *PromoHandler) GetPromoCodes(w http.ResponseWriter, r *http.Request) {
+	promos, err := h.promoService.GetPromoCodes()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get promo codes")
+		return
+	}
+
+	response.SendSuccess(w, promos, "Promo codes fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *PromoHandler) GetPromoCode(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	promo, err := h.promoService.GetPromoCode(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get promo code")
+		return
+	}
+
+	response.SendSuccess(w, promo, "Promo code fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostPromoCode adds a promo code, e.g. {"code": "COFFEE2FOR1", "discount_type": "buy_x_get_y",
+// "buy_quantity": 1, "get_quantity": 1, "value": 100, "category_id": 2, "max_uses_per_customer": 1}
+func (h *PromoHandler) PostPromoCode(w http.ResponseWriter, r *http.Request) {
+	// Codes are active unless the request says otherwise
+	promo := models.PromoCode{Active: true}
+	if err := decodeJSON(w, r, &promo); err != nil {
+		return
+	}
+
+	added, err := h.promoService.AddPromoCode(promo)
+	if err != nil {
+		h.handleError(w, r, err, "Could not add promo code")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, added, "Promo code added successfully", http.StatusCreated)
+}
+
+func (h *PromoHandler) PutPromoCode(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	// Codes are active unless the request says otherwise
+	promo := models.PromoCode{Active: true}
+	if err := decodeJSON(w, r, &promo); err != nil {
+		return
+	}
+	promo.ID = id
+
+	updated, err := h.promoService.UpdatePromoCode(promo)
+	if err != nil {
+		h.handleError(w, r, err, "Could not update promo code")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, updated, "Promo code updated successfully", http.StatusOK)
+}
+
+// DeletePromoCode removes the promo code, orders that used it keep their discount lines
+func (h *PromoHandler) DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.promoService.DeletePromoCode(id); err != nil {
+		h.handleError(w, r, err, "Could not delete promo code")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
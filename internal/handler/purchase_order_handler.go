@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type PurchaseOrderHandler struct {
+	purchaseOrderService service.PurchaseOrderServiceInterface
+	logger               *slog.Logger
+}
+
+func NewPurchaseOrderHandler(purchaseOrderService service.PurchaseOrderServiceInterface, logger *slog.Logger) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{purchaseOrderService: purchaseOrderService, logger: logger}
+}
+
+func (h *PurchaseOrderHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrPurchaseOrderNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrSupplierNotFound),
+		errors.Is(err, models.ErrInvalidReceipt),
+		errors.Is(err, service.ErrInvalidPurchaseOrder),
+		errors.Is(err, service.ErrUnknownPurchaseOrderStatus),
+		service.IsUnitError(err):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrPurchaseOrderStatus):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *PurchaseOrderHandler) pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Purchase order id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Purchase order id must be integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *PurchaseOrderHandler) PostPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var order models.PurchaseOrder
+	if err := decodeJSON(w, r, &order); err != nil {
+		return
+	}
+
+	created, err := h.purchaseOrderService.AddPurchaseOrder(order)
+	if err != nil {
+		h.handleError(w, r, err, "Could not add purchase order")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, created, "Purchase order created successfully", http.StatusCreated)
+}
+
+// GetPurchaseOrders lists purchase orders, optionally filtered with ?status=
+func (h *PurchaseOrderHandler) GetPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.purchaseOrderService.GetPurchaseOrders(r.URL.Query().Get("status"))
+	if err != nil {
+		h.handleError(w, r, err, "Could not get purchase orders")
+		return
+	}
+
+	response.SendSuccess(w, orders, "Purchase orders fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *PurchaseOrderHandler) GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := h.purchaseOrderService.GetPurchaseOrder(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get purchase order")
+		return
+	}
+
+	response.SendSuccess(w, order, "Purchase order fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *PurchaseOrderHandler) PutPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var order models.PurchaseOrder
+	if err := decodeJSON(w, r, &order); err != nil {
+		return
+	}
+	order.ID = id
+
+	if err := h.purchaseOrderService.UpdatePurchaseOrder(order); err != nil {
+		h.handleError(w, r, err, "Could not update purchase order")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, nil, "Purchase order updated successfully", http.StatusOK)
+}
+
+func (h *PurchaseOrderHandler) SendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := h.purchaseOrderService.SendPurchaseOrder(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not send purchase order")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, order, "Purchase order sent successfully", http.StatusOK)
+}
+
+// ReceivePurchaseOrder books delivered quantities into inventory. An empty body receives everything outstanding.
+func (h *PurchaseOrderHandler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var receipt models.PurchaseReceipt
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &receipt); err != nil {
+			return
+		}
+	}
+
+	order, err := h.purchaseOrderService.ReceivePurchaseOrder(id, receipt)
+	if err != nil {
+		h.handleError(w, r, err, "Could not receive purchase order")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, order, "Purchase order received successfully", http.StatusOK)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type StocktakeHandler struct {
+	stocktakeService service.StocktakeServiceInterface
+	logger           *slog.Logger
+}
+
+func NewStocktakeHandler(stocktakeService service.StocktakeServiceInterface, logger *slog.Logger) *StocktakeHandler {
+	return &StocktakeHandler{stocktakeService: stocktakeService, logger: logger}
+}
+
+func (h *StocktakeHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrStocktakeNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidCounts):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrStocktakeCommitted):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *StocktakeHandler) pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Stocktake id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Stocktake id must be integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// PostStocktake opens a new count session. The body with notes is optional.
+func (h *StocktakeHandler) PostStocktake(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Notes string `json:"notes"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &request); err != nil {
+			return
+		}
+	}
+
+	stocktake, err := h.stocktakeService.OpenStocktake(request.Notes)
+	if err != nil {
+		h.handleError(w, r, err, "Could not open stocktake")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, stocktake, "Stocktake opened successfully", http.StatusCreated)
+}
+
+func (h *StocktakeHandler) GetStocktakes(w http.ResponseWriter, r *http.Request) {
+	stocktakes, err := h.stocktakeService.GetStocktakes()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get stocktakes")
+		return
+	}
+
+	response.SendSuccess(w, stocktakes, "Stocktakes fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *StocktakeHandler) GetStocktake(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stocktake, err := h.stocktakeService.GetStocktake(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get stocktake")
+		return
+	}
+
+	response.SendSuccess(w, stocktake, "Stocktake fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutCounts records counted quantities. Counts of already counted ingredients are replaced.
+func (h *StocktakeHandler) PutCounts(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.StocktakeCountsRequest
+	if err := decodeJSON(w, r, &request); err != nil {
+		return
+	}
+
+	stocktake, err := h.stocktakeService.SubmitCounts(id, request.Counts)
+	if err != nil {
+		h.handleError(w, r, err, "Could not save counts")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, stocktake, "Counts saved successfully", http.StatusOK)
+}
+
+func (h *StocktakeHandler) GetVariance(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	variance, err := h.stocktakeService.GetVariance(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get stocktake variance")
+		return
+	}
+
+	response.SendSuccess(w, variance, "Stocktake variance fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// CommitStocktake applies the counts to the inventory
+func (h *StocktakeHandler) CommitStocktake(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r)
+	if !ok {
+		return
+	}
+
+	variance, err := h.stocktakeService.CommitStocktake(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not commit stocktake")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, variance, "Stocktake committed successfully", http.StatusOK)
+}
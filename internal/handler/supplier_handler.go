@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type SupplierHandler struct {
+	supplierService service.SupplierServiceInterface
+	logger          *slog.Logger
+}
+
+func NewSupplierHandler(supplierService service.SupplierServiceInterface, logger *slog.Logger) *SupplierHandler {
+	return &SupplierHandler{supplierService: supplierService, logger: logger}
+}
+
+func (h *SupplierHandler) handleError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.logger.Error(message, "error", err, "method", r.Method, "url", r.URL)
+	switch {
+	case errors.Is(err, models.ErrSupplierNotFound):
+		response.SendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrSupplierNameRequired):
+		response.SendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrSupplierInUse):
+		response.SendError(w, err.Error(), http.StatusConflict)
+	default:
+		response.SendError(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *SupplierHandler) PostSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if err := decodeJSON(w, r, &supplier); err != nil {
+		return
+	}
+
+	created, err := h.supplierService.AddSupplier(supplier)
+	if err != nil {
+		h.handleError(w, r, err, "Could not add supplier")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, created, "Supplier created successfully", http.StatusCreated)
+}
+
+func (h *SupplierHandler) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.supplierService.GetSuppliers()
+	if err != nil {
+		h.handleError(w, r, err, "Could not get suppliers")
+		return
+	}
+
+	response.SendSuccess(w, suppliers, "Suppliers fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *SupplierHandler) GetSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Supplier id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Supplier id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	supplier, err := h.supplierService.GetSupplier(id)
+	if err != nil {
+		h.handleError(w, r, err, "Could not get supplier")
+		return
+	}
+
+	response.SendSuccess(w, supplier, "Supplier fetched successfully", http.StatusOK)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *SupplierHandler) PutSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Supplier id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Supplier id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var supplier models.Supplier
+	if err := decodeJSON(w, r, &supplier); err != nil {
+		return
+	}
+	supplier.ID = id
+
+	if err = h.supplierService.UpdateSupplier(supplier); err != nil {
+		h.handleError(w, r, err, "Could not update supplier")
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, nil, "Supplier updated successfully", http.StatusOK)
+}
+
+func (h *SupplierHandler) DeleteSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Supplier id must be integer", "method", r.Method, "url", r.URL)
+		response.SendError(w, "Supplier id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err = h.supplierService.DeleteSupplier(id); err != nil {
+		h.handleError(w, r, err, "Could not delete supplier")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/service"
+	"github.com/sunzhqr/frappuccino/pkg/response"
+)
+
+type WasteHandler struct {
+	wasteService service.WasteServiceInterface
+	logger       *slog.Logger
+}
+
+func NewWasteHandler(wasteService service.WasteServiceInterface, logger *slog.Logger) *WasteHandler {
+	return &WasteHandler{wasteService: wasteService, logger: logger}
+}
+
+// PostWaste writes off ingredients or menu items with a reason code
+func (h *WasteHandler) PostWaste(w http.ResponseWriter, r *http.Request) {
+	var request models.WasteRequest
+	if err := decodeJSON(w, r, &request); err != nil {
+		return
+	}
+
+	waste, err := h.wasteService.RecordWaste(request)
+	if err != nil {
+		h.logger.Error("Could not record waste", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case errors.Is(err, service.ErrInvalidWaste):
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, models.ErrInsufficientInventory):
+			response.SendError(w, err.Error(), http.StatusConflict)
+		default:
+			response.SendError(w, "Could not record waste", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	response.SendSuccess(w, waste, "Waste recorded successfully", http.StatusCreated)
+}
+
+// PostExpiredWriteOff writes off what is left of all expired lots as expired waste
+func (h *WasteHandler) PostExpiredWriteOff(w http.ResponseWriter, r *http.Request) {
+	waste, err := h.wasteService.WriteOffExpired()
+	if err != nil {
+		h.logger.Error("Could not write off expired lots", "error", err, "method", r.Method, "url", r.URL)
+		response.SendError(w, "Could not write off expired lots", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	if waste.ID == 0 {
+		response.SendSuccess(w, nil, "No expired lots to write off", http.StatusOK)
+		return
+	}
+	response.SendSuccess(w, waste, "Expired lots written off successfully", http.StatusCreated)
+}
+
+// GET /reports/waste?from=2025-01-01&to=2025-01-31&groupBy=week: written off cost by reason, ingredient and period
+func (h *WasteHandler) WasteReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	report, err := h.wasteService.GetWasteReport(query.Get("from"), query.Get("to"), query.Get("groupBy"))
+	if err != nil {
+		h.logger.Error("Error getting waste report", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrInvalidDateRange || err == service.ErrInvalidGroupBy {
+			response.SendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response.SendError(w, "Error getting waste report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -0,0 +1,78 @@
+package models
+
+import "sort"
+
+// Allergen tags of inventory ingredients
+var allergens = map[string]bool{
+	"dairy":     true,
+	"eggs":      true,
+	"gluten":    true,
+	"nuts":      true,
+	"peanuts":   true,
+	"soy":       true,
+	"sesame":    true,
+	"fish":      true,
+	"shellfish": true,
+	"sulphites": true,
+}
+
+// Dietary tags of inventory ingredients
+var dietaryTags = map[string]bool{
+	"vegan":      true,
+	"vegetarian": true,
+	"halal":      true,
+}
+
+func IsAllergen(tag string) bool {
+	return allergens[tag]
+}
+
+func IsDietaryTag(tag string) bool {
+	return dietaryTags[tag]
+}
+
+// DietaryInfo tells what a menu item is made of. An allergen of any ingredient is an allergen
+// of the item, a dietary tag holds only when every ingredient has it.
+type DietaryInfo struct {
+	Allergens []string `json:"allergens"`
+	Dietary   []string `json:"dietary"`
+}
+
+// CombineDietary derives the dietary info of a dish from the info of its ingredients
+func CombineDietary(ingredients []DietaryInfo) DietaryInfo {
+	allergenSet := make(map[string]bool)
+	dietaryCount := make(map[string]int)
+	for _, ingredient := range ingredients {
+		for _, allergen := range ingredient.Allergens {
+			allergenSet[allergen] = true
+		}
+		for _, tag := range ingredient.Dietary {
+			dietaryCount[tag]++
+		}
+	}
+
+	info := DietaryInfo{Allergens: []string{}, Dietary: []string{}}
+	for allergen := range allergenSet {
+		info.Allergens = append(info.Allergens, allergen)
+	}
+	for tag, count := range dietaryCount {
+		if count == len(ingredients) {
+			info.Dietary = append(info.Dietary, tag)
+		}
+	}
+	sort.Strings(info.Allergens)
+	sort.Strings(info.Dietary)
+	return info
+}
+
+// ContainsAny reports whether the item contains any of the given allergens
+func (d DietaryInfo) ContainsAny(allergens []string) bool {
+	for _, allergen := range allergens {
+		for _, contained := range d.Allergens {
+			if allergen == contained {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID           int    `json:"category_id"`
+	Name         string `json:"name"`
+	ParentID     int    `json:"parent_id,omitempty"`
+	DisplayOrder int    `json:"display_order"`
+}
+
+// MenuCategoryGroup is a category with its menu items and subcategories, used by the kiosk menu
+type MenuCategoryGroup struct {
+	Category
+	Items         []MenuItem          `json:"items"`
+	Subcategories []MenuCategoryGroup `json:"subcategories,omitempty"`
+}
+
+type GroupedMenu struct {
+	Categories    []MenuCategoryGroup `json:"categories"`
+	Uncategorized []MenuItem          `json:"uncategorized,omitempty"`
+}
+
+// MenuFilter narrows down the menu. Zero values mean no filtering.
+type MenuFilter struct {
+	// CategoryID keeps items of the category and all of its subcategories
+	CategoryID int
+	// ExcludeAllergens drops items containing any of the allergens
+	ExcludeAllergens []string
+	// At applies the pricing rules active at that moment to the prices
+	At *time.Time
+}
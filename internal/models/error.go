@@ -5,6 +5,40 @@ import "errors"
 var (
 	ErrOrderClosed   = errors.New("the order is already closed")
 	ErrOrderNotFound = errors.New("order not found")
+
+	ErrIllegalTransition = errors.New("illegal order status transition")
+	ErrInvalidModifiers  = errors.New("invalid modifiers for order item")
+	ErrInvalidVariant    = errors.New("invalid variant for order item")
+	ErrInvalidBundle     = errors.New("invalid bundle choices for order item")
+
+	ErrCategoryNotFound = errors.New("category not found")
+
+	ErrMenuItemNotFound       = errors.New("menu item not found")
+	ErrInvalidMenuItem        = errors.New("invalid menu item")
+	ErrScheduledPriceNotFound = errors.New("scheduled price not found")
+	ErrScheduledPriceApplied  = errors.New("scheduled price is already applied")
+	ErrPricingRuleNotFound    = errors.New("pricing rule not found")
+
+	ErrPromoCodeNotFound      = errors.New("promo code not found")
+	ErrPromoCodeNotApplicable = errors.New("promo code can not be applied")
+	ErrPromoCodeExists        = errors.New("promo code already exists")
+
+	ErrSupplierNotFound      = errors.New("supplier not found")
+	ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+	ErrPurchaseOrderStatus   = errors.New("operation is not allowed in the current purchase order status")
+	ErrInvalidReceipt        = errors.New("invalid purchase order receipt")
+
+	ErrInsufficientInventory = errors.New("insufficient inventory")
+
+	ErrUnknownAllergen = errors.New("unknown allergen")
+
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+
+	ErrStocktakeNotFound  = errors.New("stocktake not found")
+	ErrStocktakeCommitted = errors.New("stocktake is already committed")
+
+	ErrRecipeNotFound = errors.New("ingredient is not prepared, it has no recipe")
 )
 
 type Error struct {
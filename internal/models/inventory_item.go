@@ -1,8 +1,136 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
+// Reasons recorded in inventory_transactions, see the inventory_reason type in init.sql
+const (
+	InventoryReasonInitialStock    = "initial_stock"
+	InventoryReasonAdjustment      = "adjustment"
+	InventoryReasonSale            = "sale"
+	InventoryReasonCancellation    = "cancellation"
+	InventoryReasonWaste           = "waste"
+	InventoryReasonPurchaseReceipt = "purchase_receipt"
+	InventoryReasonCountCorrection = "count_correction"
+	InventoryReasonProduction      = "production"
+)
+
+func IsInventoryReason(reason string) bool {
+	switch reason {
+	case InventoryReasonInitialStock, InventoryReasonAdjustment, InventoryReasonSale, InventoryReasonCancellation,
+		InventoryReasonWaste, InventoryReasonPurchaseReceipt, InventoryReasonCountCorrection, InventoryReasonProduction:
+		return true
+	}
+	return false
+}
+
+// OrderReference and the other *Reference helpers build the document reference stored with inventory transactions
+func OrderReference(id int) string {
+	return fmt.Sprintf("order:%d", id)
+}
+
+func PurchaseOrderReference(id int) string {
+	return fmt.Sprintf("purchase_order:%d", id)
+}
+
+func WasteReference(id int) string {
+	return fmt.Sprintf("waste:%d", id)
+}
+
+func StocktakeReference(id int) string {
+	return fmt.Sprintf("stocktake:%d", id)
+}
+
+func ProductionReference(id int) string {
+	return fmt.Sprintf("production:%d", id)
+}
+
 type InventoryItem struct {
 	IngredientID int     `json:"ingredient_id"`
-	Name        string  `json:"name"`
-	Quantity    float64 `json:"quantity"`
-	Unit        string  `json:"unit"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	ReorderLevel float64 `json:"reorder_level"`
+	ParLevel     float64 `json:"par_level"`
+	UnitCost     float64 `json:"unit_cost"`
+	DietaryInfo
+	Nutrition Nutrition `json:"nutrition_per_unit"`
+}
+
+// IsLowStock reports whether the stock is at or below the reorder level
+func (item InventoryItem) IsLowStock() bool {
+	return item.ReorderLevel > 0 && item.Quantity <= item.ReorderLevel
+}
+
+// LowStockAlert describes an ingredient that has to be reordered
+type LowStockAlert struct {
+	IngredientID   int     `json:"ingredient_id"`
+	Name           string  `json:"name"`
+	Quantity       float64 `json:"quantity"`
+	Unit           string  `json:"unit"`
+	ReorderLevel   float64 `json:"reorder_level"`
+	ParLevel       float64 `json:"par_level"`
+	SuggestedOrder float64 `json:"suggested_order"`
+}
+
+// NewLowStockAlert builds an alert suggesting to order up to the par level
+func NewLowStockAlert(item InventoryItem) LowStockAlert {
+	suggested := item.ParLevel - item.Quantity
+	if suggested < 0 {
+		suggested = 0
+	}
+	return LowStockAlert{
+		IngredientID:   item.IngredientID,
+		Name:           item.Name,
+		Quantity:       item.Quantity,
+		Unit:           item.Unit,
+		ReorderLevel:   item.ReorderLevel,
+		ParLevel:       item.ParLevel,
+		SuggestedOrder: suggested,
+	}
+}
+
+// StockValuation is the value of the stock on hand at weighted average cost
+type StockValuation struct {
+	Items      []StockValue `json:"items"`
+	TotalValue float64      `json:"total_value"`
+}
+
+type StockValue struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	UnitCost     float64 `json:"unit_cost"`
+	Value        float64 `json:"value"`
+}
+
+// InventoryTransaction is a ledger row written by the inventory trigger for every stock change
+type InventoryTransaction struct {
+	ID             int       `json:"transaction_id"`
+	IngredientID   int       `json:"ingredient_id"`
+	QuantityChange float64   `json:"quantity_change"`
+	Reason         string    `json:"reason"`
+	Reference      string    `json:"reference,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TransactionFilter narrows down the ledger. Zero values mean no filtering, To is exclusive.
+type TransactionFilter struct {
+	IngredientID int
+	From         time.Time
+	To           time.Time
+	Reasons      []string
+	Page         int
+	PageSize     int
+}
+
+type TransactionPage struct {
+	CurrentPage int                    `json:"currentPage"`
+	HasNextPage bool                   `json:"hasNextPage"`
+	PageSize    int                    `json:"pageSize"`
+	TotalPages  int                    `json:"totalPages"`
+	Data        []InventoryTransaction `json:"data"`
 }
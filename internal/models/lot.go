@@ -0,0 +1,67 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// InventoryLot is a received batch of an ingredient. Quantity is what is left of the batch,
+// lots are consumed earliest expiry first.
+type InventoryLot struct {
+	ID               int        `json:"lot_id"`
+	IngredientID     int        `json:"ingredient_id"`
+	Name             string     `json:"name"`
+	Unit             string     `json:"unit"`
+	PurchaseOrderID  int        `json:"purchase_order_id,omitempty"`
+	ReceivedQuantity float64    `json:"received_quantity"`
+	Quantity         float64    `json:"quantity"`
+	ReceivedAt       time.Time  `json:"received_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	Expired          bool       `json:"expired"`
+}
+
+// LotUsage is the quantity taken from a single lot
+type LotUsage struct {
+	LotID    int
+	Quantity float64
+}
+
+// DepleteLots works out what to take from the lots of an ingredient for quantity: earliest expiry
+// first, lots without expiry last and oldest first within the same expiry. Expired lots are skipped
+// unless includeExpired is set. Returns what is taken from each lot and the part of quantity
+// the lots can not cover.
+func DepleteLots(lots []InventoryLot, quantity float64, includeExpired bool) ([]LotUsage, float64) {
+	ordered := make([]InventoryLot, 0, len(lots))
+	for _, lot := range lots {
+		if lot.Quantity > 0 && (includeExpired || !lot.Expired) {
+			ordered = append(ordered, lot)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		switch {
+		case a.ExpiresAt != nil && b.ExpiresAt == nil:
+			return true
+		case a.ExpiresAt == nil && b.ExpiresAt != nil:
+			return false
+		case a.ExpiresAt != nil && !a.ExpiresAt.Equal(*b.ExpiresAt):
+			return a.ExpiresAt.Before(*b.ExpiresAt)
+		case !a.ReceivedAt.Equal(b.ReceivedAt):
+			return a.ReceivedAt.Before(b.ReceivedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	usages := []LotUsage{}
+	left := quantity
+	for _, lot := range ordered {
+		if left <= 0 {
+			break
+		}
+		taken := math.Min(lot.Quantity, left)
+		usages = append(usages, LotUsage{LotID: lot.ID, Quantity: taken})
+		left -= taken
+	}
+	return usages, math.Max(left, 0)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDepleteLots(t *testing.T) {
+	day := func(d int) *time.Time {
+		at := time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+		return &at
+	}
+	lot := func(id int, quantity float64, received int, expires *time.Time, expired bool) InventoryLot {
+		return InventoryLot{ID: id, Quantity: quantity, ReceivedAt: *day(received), ExpiresAt: expires, Expired: expired}
+	}
+
+	tests := []struct {
+		name           string
+		lots           []InventoryLot
+		quantity       float64
+		includeExpired bool
+		want           []LotUsage
+		wantLeft       float64
+	}{
+		{
+			name:     "no lots",
+			quantity: 5,
+			want:     []LotUsage{},
+			wantLeft: 5,
+		},
+		{
+			name:     "single lot covers the quantity",
+			lots:     []InventoryLot{lot(1, 10, 1, day(20), false)},
+			quantity: 4,
+			want:     []LotUsage{{LotID: 1, Quantity: 4}},
+		},
+		{
+			name:     "earliest expiry first",
+			lots:     []InventoryLot{lot(1, 10, 1, day(25), false), lot(2, 3, 2, day(20), false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 2, Quantity: 3}, {LotID: 1, Quantity: 2}},
+		},
+		{
+			name:     "lots without expiry last",
+			lots:     []InventoryLot{lot(1, 10, 1, nil, false), lot(2, 3, 2, day(30), false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 2, Quantity: 3}, {LotID: 1, Quantity: 2}},
+		},
+		{
+			name:     "oldest first within the same expiry",
+			lots:     []InventoryLot{lot(1, 2, 5, day(20), false), lot(2, 2, 3, day(20), false), lot(3, 2, 3, day(20), false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 2, Quantity: 2}, {LotID: 3, Quantity: 2}, {LotID: 1, Quantity: 1}},
+		},
+		{
+			name:     "expired lots skipped",
+			lots:     []InventoryLot{lot(1, 10, 1, day(2), true), lot(2, 3, 2, day(20), false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 2, Quantity: 3}},
+			wantLeft: 2,
+		},
+		{
+			name:           "expired lots included for write-offs",
+			lots:           []InventoryLot{lot(1, 10, 1, day(2), true), lot(2, 3, 2, day(20), false)},
+			quantity:       5,
+			includeExpired: true,
+			want:           []LotUsage{{LotID: 1, Quantity: 5}},
+		},
+		{
+			name:     "empty lots skipped",
+			lots:     []InventoryLot{lot(1, 0, 1, day(10), false), lot(2, 3, 2, day(20), false)},
+			quantity: 2,
+			want:     []LotUsage{{LotID: 2, Quantity: 2}},
+		},
+		{
+			name:     "lots short of the quantity",
+			lots:     []InventoryLot{lot(1, 2, 1, day(20), false), lot(2, 1, 2, nil, false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 1, Quantity: 2}, {LotID: 2, Quantity: 1}},
+			wantLeft: 2,
+		},
+		{
+			name:     "exact quantity empties the lots",
+			lots:     []InventoryLot{lot(1, 2, 1, day(20), false), lot(2, 3, 2, day(21), false)},
+			quantity: 5,
+			want:     []LotUsage{{LotID: 1, Quantity: 2}, {LotID: 2, Quantity: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, left := DepleteLots(tt.lots, tt.quantity, tt.includeExpired)
+			if !reflect.DeepEqual(got, tt.want) || left != tt.wantLeft {
+				t.Errorf("DepleteLots() = %v, %v, want %v, %v", got, left, tt.want, tt.wantLeft)
+			}
+		})
+	}
+}
@@ -1,14 +1,138 @@
 package models
 
+import "math"
+
 type MenuItem struct {
-	ID          int                  `json:"product_id"`
+	ID             int                  `json:"product_id"`
+	Name           string               `json:"name"`
+	Description    string               `json:"description"`
+	Price          float64              `json:"price"`
+	CategoryID     int                  `json:"category_id,omitempty"`
+	Ingredients    []MenuItemIngredient `json:"ingredients"`
+	Variants       []MenuItemVariant    `json:"variants,omitempty"`
+	ModifierGroups []ModifierGroup      `json:"modifier_groups,omitempty"`
+	BundleSlots    []BundleSlot         `json:"bundle_slots,omitempty"`
+	EffectivePrice
+	Availability
+	Costing
+	DietaryInfo
+	Nutrition Nutrition `json:"nutrition"`
+}
+
+// Availability tells how many servings can be made from the current inventory.
+// AvailableServings is nil when the item has no recipe and is not limited by stock.
+type Availability struct {
+	AvailableServings *int `json:"available_servings"`
+	SoldOut           bool `json:"sold_out"`
+}
+
+// SetServings fills the availability from the number of servings that can be made
+func (a *Availability) SetServings(servings int) {
+	a.AvailableServings = &servings
+	a.SoldOut = servings <= 0
+}
+
+// Costing is the food cost of a serving at current ingredient unit costs and the margin left from the price
+type Costing struct {
+	FoodCost      float64 `json:"food_cost"`
+	Margin        float64 `json:"margin"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
+// SetCost fills the costing from the selling price and the food cost of a serving
+func (c *Costing) SetCost(price, foodCost float64) {
+	c.FoodCost = math.Round(foodCost*100) / 100
+	c.Margin = math.Round((price-foodCost)*100) / 100
+	c.MarginPercent = 0
+	if price > 0 {
+		c.MarginPercent = math.Round((price-foodCost)/price*10000) / 100
+	}
+}
+
+// MenuItemVariant is a size or other flavour of a menu item with its own price.
+// Its recipe is the base recipe scaled by RecipeMultiplier unless Ingredients are given.
+type MenuItemVariant struct {
+	ID               int                  `json:"variant_id"`
+	Name             string               `json:"name"`
+	Price            float64              `json:"price"`
+	RecipeMultiplier float64              `json:"recipe_multiplier"`
+	Ingredients      []MenuItemIngredient `json:"ingredients,omitempty"`
+	EffectivePrice
+	Availability
+	Costing
+	Nutrition Nutrition `json:"nutrition"`
+}
+
+// MenuItemIngredient is a recipe line. Unit may be given on input, the quantity is then
+// converted to the ingredient stock unit before saving.
+type MenuItemIngredient struct {
+	IngredientID int     `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit,omitempty"`
+}
+
+// ModifierGroup bundles the customizations of a menu item, e.g. milk choice or extras.
+// A customer picks from MinSelect to MaxSelect modifiers of the group.
+type ModifierGroup struct {
+	ID        int        `json:"group_id"`
+	Name      string     `json:"name"`
+	MinSelect int        `json:"min_select"`
+	MaxSelect int        `json:"max_select"`
+	Modifiers []Modifier `json:"modifiers"`
+}
+
+// Modifier changes the price and recipe of a menu item. Ingredient quantities are deltas
+// to the base recipe and may be negative, a variant made by scaling the base recipe scales them
+// by its RecipeMultiplier, as it does the nutrition delta. Allergens are those of the ingredients it adds.
+type Modifier struct {
+	ID          int                  `json:"modifier_id"`
+	GroupID     int                  `json:"-"`
 	Name        string               `json:"name"`
-	Description string               `json:"description"`
-	Price       float64              `json:"price"`
+	PriceDelta  float64              `json:"price_delta"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
+	Allergens   []string             `json:"allergens,omitempty"`
+	Nutrition   Nutrition            `json:"nutrition_delta"`
 }
 
-type MenuItemIngredient struct {
+// BundleSlot is a component place of a bundle menu item, e.g. "Drink" filled by a latte or a cappuccino.
+// A slot with a single option is a fixed component. A bundle has no recipe of its own, its stock
+// is taken through the recipes of the chosen components.
+type BundleSlot struct {
+	ID       int            `json:"slot_id"`
+	Name     string         `json:"name"`
+	Quantity int            `json:"quantity"`
+	Options  []BundleOption `json:"options"`
+}
+
+// BundleOption is a menu item that can fill a bundle slot, PriceDelta is added to the bundle price
+type BundleOption struct {
+	ProductID  int     `json:"product_id"`
+	Name       string  `json:"name,omitempty"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+// MenuItemCost breaks the food cost of a menu item down by ingredient
+type MenuItemCost struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Costing
+	Ingredients []IngredientCost `json:"ingredients"`
+	Variants    []VariantCost    `json:"variants,omitempty"`
+}
+
+type IngredientCost struct {
 	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
 	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+}
+
+type VariantCost struct {
+	VariantID int     `json:"variant_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Costing
 }
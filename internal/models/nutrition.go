@@ -0,0 +1,82 @@
+package models
+
+import "math"
+
+// Nutrition holds nutrition values. On inventory items they are per stock unit
+// (1 g, 1 ml, 1 shot, 1 pcs), on menu items per serving.
+type Nutrition struct {
+	Calories      float64 `json:"calories"`
+	Protein       float64 `json:"protein_g"`
+	Fat           float64 `json:"fat_g"`
+	Carbohydrates float64 `json:"carbohydrates_g"`
+	Sugar         float64 `json:"sugar_g"`
+}
+
+// Scale returns the nutrition of quantity units
+func (n Nutrition) Scale(quantity float64) Nutrition {
+	return Nutrition{
+		Calories:      n.Calories * quantity,
+		Protein:       n.Protein * quantity,
+		Fat:           n.Fat * quantity,
+		Carbohydrates: n.Carbohydrates * quantity,
+		Sugar:         n.Sugar * quantity,
+	}
+}
+
+func (n Nutrition) Add(other Nutrition) Nutrition {
+	return Nutrition{
+		Calories:      n.Calories + other.Calories,
+		Protein:       n.Protein + other.Protein,
+		Fat:           n.Fat + other.Fat,
+		Carbohydrates: n.Carbohydrates + other.Carbohydrates,
+		Sugar:         n.Sugar + other.Sugar,
+	}
+}
+
+// Rounded rounds the values to one decimal place for publishing
+func (n Nutrition) Rounded() Nutrition {
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return Nutrition{
+		Calories:      round(n.Calories),
+		Protein:       round(n.Protein),
+		Fat:           round(n.Fat),
+		Carbohydrates: round(n.Carbohydrates),
+		Sugar:         round(n.Sugar),
+	}
+}
+
+// IsValid reports whether no value is negative
+func (n Nutrition) IsValid() bool {
+	return n.Calories >= 0 && n.Protein >= 0 && n.Fat >= 0 && n.Carbohydrates >= 0 && n.Sugar >= 0
+}
+
+// MenuItemNutrition breaks the nutrition of a serving down by ingredient. Variants have their
+// own per serving values, modifiers change the serving by the given delta.
+type MenuItemNutrition struct {
+	ProductID   int                   `json:"product_id"`
+	Name        string                `json:"name"`
+	Nutrition   Nutrition             `json:"nutrition"`
+	Ingredients []IngredientNutrition `json:"ingredients"`
+	Variants    []VariantNutrition    `json:"variants,omitempty"`
+	Modifiers   []ModifierNutrition   `json:"modifiers,omitempty"`
+}
+
+type IngredientNutrition struct {
+	IngredientID int       `json:"ingredient_id"`
+	Name         string    `json:"name"`
+	Quantity     float64   `json:"quantity"`
+	Unit         string    `json:"unit"`
+	Nutrition    Nutrition `json:"nutrition"`
+}
+
+type VariantNutrition struct {
+	VariantID int       `json:"variant_id"`
+	Name      string    `json:"name"`
+	Nutrition Nutrition `json:"nutrition"`
+}
+
+type ModifierNutrition struct {
+	ModifierID int       `json:"modifier_id"`
+	Name       string    `json:"name"`
+	Delta      Nutrition `json:"delta"`
+}
@@ -5,18 +5,126 @@ var (
 	StatusOrderRejected = "rejected"
 )
 
+// Order lifecycle statuses, matching the order_status enum.
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusAccepted  = "accepted"
+	OrderStatusPreparing = "preparing"
+	OrderStatusReady     = "ready"
+	OrderStatusPickedUp  = "picked_up"
+	OrderStatusCancelled = "cancelled"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusAccepted, OrderStatusCancelled},
+	OrderStatusAccepted:  {OrderStatusPreparing, OrderStatusCancelled},
+	OrderStatusPreparing: {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:     {OrderStatusPickedUp, OrderStatusCancelled},
+	OrderStatusPickedUp:  {},
+	OrderStatusCancelled: {},
+}
+
+// IsOrderStatus reports whether status is a known order status.
+func IsOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// IsFinalOrderStatus reports whether no further transitions are possible from status.
+func IsFinalOrderStatus(status string) bool {
+	next, ok := orderTransitions[status]
+	return ok && len(next) == 0
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another.
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOrderStatus returns the status that follows status on the way to picked_up,
+// or an empty string when the order is already final.
+func NextOrderStatus(status string) string {
+	for _, next := range orderTransitions[status] {
+		if next != OrderStatusCancelled {
+			return next
+		}
+	}
+	return ""
+}
+
+// Order is a customer order. PromoCodes are given on creation, the discounts they gave are kept
+// as Discounts and Total is what is left to pay after DiscountTotal.
 type Order struct {
-	ID           int                    `json:"order_id"`
-	CustomerName string                 `json:"customer_name"`
-	Items        []OrderItem            `json:"items"`
-	Status       string                 `json:"status"`
-	Notes        map[string]interface{} `json:"notes"`
-	CreatedAt    string                 `json:"created_at"`
+	ID            int                    `json:"order_id"`
+	CustomerName  string                 `json:"customer_name"`
+	Items         []OrderItem            `json:"items"`
+	Status        string                 `json:"status"`
+	Notes         map[string]interface{} `json:"notes"`
+	PromoCodes    []string               `json:"promo_codes,omitempty"`
+	Discounts     []OrderDiscount        `json:"discounts,omitempty"`
+	DiscountTotal float64                `json:"discount_total"`
+	Total         float64                `json:"total"`
+	CreatedAt     string                 `json:"created_at"`
 }
 
+// OrderItem is a line of an order. UnitPrice and LineTotal are fixed when the order is placed.
+// Modifiers are the chosen modifier IDs, AppliedModifiers the modifiers as they were priced on the order.
+// A bundle line takes BundleChoices for its slots with several options, the components
+// it was made of are returned as Components.
 type OrderItem struct {
+	ProductID        int                  `json:"product_id"`
+	Quantity         int                  `json:"quantity"`
+	VariantID        int                  `json:"variant_id,omitempty"`
+	Variant          string               `json:"variant,omitempty"`
+	Modifiers        []int                `json:"modifiers,omitempty"`
+	AppliedModifiers []OrderItemModifier  `json:"applied_modifiers,omitempty"`
+	BundleChoices    []BundleChoice       `json:"bundle_choices,omitempty"`
+	Components       []OrderItemComponent `json:"components,omitempty"`
+	UnitPrice        float64              `json:"unit_price"`
+	LineTotal        float64              `json:"line_total"`
+}
+
+// OrderItemModifier is a modifier of an order line with its name and price at the time of the order.
+// ModifierID is zero once the modifier is removed from the menu.
+type OrderItemModifier struct {
+	ModifierID int     `json:"modifier_id,omitempty"`
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+// BundleChoice is the menu item chosen for a bundle slot
+type BundleChoice struct {
+	SlotID    int `json:"slot_id"`
 	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+}
+
+// OrderItemComponent is a menu item served as part of a bundle line, Quantity is per bundle
+type OrderItemComponent struct {
+	Slot       string  `json:"slot"`
+	ProductID  int     `json:"product_id"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+type OrderTransitionRequest struct {
+	Status string `json:"status"`
+	Actor  string `json:"actor"`
+}
+
+type OrderStatusChange struct {
+	ID         int    `json:"id"`
+	OrderID    int    `json:"order_id"`
+	FromStatus string `json:"from_status,omitempty"`
+	ToStatus   string `json:"to_status"`
+	Actor      string `json:"actor"`
+	ChangedAt  string `json:"changed_at"`
 }
 
 type BatchOrdersResponce struct {
@@ -25,11 +133,13 @@ type BatchOrdersResponce struct {
 }
 
 type BatchOrderInfo struct {
-	OrderID      int     `json:"order_id"`
-	CustomerName string  `json:"customer_name"`
-	Status       string  `json:"status"`
-	Reason       string  `json:"reason"`
-	Total        float64 `json:"total"`
+	OrderID       int             `json:"order_id"`
+	CustomerName  string          `json:"customer_name"`
+	Status        string          `json:"status"`
+	Reason        string          `json:"reason"`
+	Discounts     []OrderDiscount `json:"discounts,omitempty"`
+	DiscountTotal float64         `json:"discount_total"`
+	Total         float64         `json:"total"`
 }
 
 type BatchOrderSummary struct {
@@ -0,0 +1,80 @@
+package models
+
+import "testing"
+
+func TestCanTransitionOrder(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{OrderStatusPending, OrderStatusAccepted, true},
+		{OrderStatusAccepted, OrderStatusPreparing, true},
+		{OrderStatusPreparing, OrderStatusReady, true},
+		{OrderStatusReady, OrderStatusPickedUp, true},
+		{OrderStatusPending, OrderStatusCancelled, true},
+		{OrderStatusReady, OrderStatusCancelled, true},
+		{OrderStatusPending, OrderStatusPickedUp, false},
+		{OrderStatusAccepted, OrderStatusReady, false},
+		{OrderStatusPreparing, OrderStatusAccepted, false},
+		{OrderStatusPending, OrderStatusPending, false},
+		{OrderStatusPickedUp, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusPending, false},
+		{"unknown", OrderStatusAccepted, false},
+		{OrderStatusPending, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := CanTransitionOrder(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransitionOrder(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFinalOrderStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{OrderStatusPending, false},
+		{OrderStatusAccepted, false},
+		{OrderStatusPreparing, false},
+		{OrderStatusReady, false},
+		{OrderStatusPickedUp, true},
+		{OrderStatusCancelled, true},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := IsFinalOrderStatus(tt.status); got != tt.want {
+				t.Errorf("IsFinalOrderStatus(%q) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOrderStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{OrderStatusPending, OrderStatusAccepted},
+		{OrderStatusAccepted, OrderStatusPreparing},
+		{OrderStatusPreparing, OrderStatusReady},
+		{OrderStatusReady, OrderStatusPickedUp},
+		{OrderStatusPickedUp, ""},
+		{OrderStatusCancelled, ""},
+		{"unknown", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := NextOrderStatus(tt.status); got != tt.want {
+				t.Errorf("NextOrderStatus(%q) = %q, want %q", tt.status, got, tt.want)
+			}
+		})
+	}
+}
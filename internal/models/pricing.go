@@ -0,0 +1,118 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// PriceChange is a change of the menu item price logged by the price_change_trigger
+type PriceChange struct {
+	ID        int       `json:"history_id"`
+	OldPrice  float64   `json:"old_price"`
+	NewPrice  float64   `json:"new_price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ScheduledPrice is a menu item price that the scheduler applies at EffectiveAt
+type ScheduledPrice struct {
+	ID          int        `json:"scheduled_price_id"`
+	ProductID   int        `json:"product_id"`
+	Name        string     `json:"name,omitempty"`
+	Price       float64    `json:"price"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
+
+// PriceHistory lists the price changes of a menu item, newest first, and the prices scheduled for it
+type PriceHistory struct {
+	ProductID    int              `json:"product_id"`
+	Name         string           `json:"name"`
+	CurrentPrice float64          `json:"current_price"`
+	Changes      []PriceChange    `json:"changes"`
+	Scheduled    []ScheduledPrice `json:"scheduled"`
+}
+
+const (
+	AdjustmentPercentage = "percentage"
+	AdjustmentFixed      = "fixed"
+)
+
+// PricingRule adjusts menu prices during a daily time window, e.g. an afternoon happy hour.
+// Times are HH:MM in the local time of the cafe, a window ending before it starts runs over midnight
+// and equal times mean the whole day. Value is a percentage (-20 is 20% off) or a fixed amount
+// (-0.5 is 0.50 off) added to the price. When several rules match, the highest priority wins.
+type PricingRule struct {
+	ID             int     `json:"rule_id"`
+	Name           string  `json:"name"`
+	CategoryID     int     `json:"category_id,omitempty"` // 0 means the whole menu, subcategories are included
+	Weekdays       []int   `json:"weekdays,omitempty"`    // 1 is Monday ... 7 is Sunday, empty means every day
+	StartTime      string  `json:"start_time"`
+	EndTime        string  `json:"end_time"`
+	AdjustmentType string  `json:"adjustment_type"`
+	Value          float64 `json:"value"`
+	Priority       int     `json:"priority"`
+	Active         bool    `json:"active"`
+}
+
+// ParseClock parses an HH:MM time of day into minutes since midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Covers reports whether the rule is active on the weekday and in the time window of at,
+// both taken in the cafe time zone loc. Times that do not parse never match, rules are validated when saved.
+func (r PricingRule) Covers(at time.Time, loc *time.Location) bool {
+	if !r.Active {
+		return false
+	}
+	at = at.In(loc)
+	if len(r.Weekdays) > 0 {
+		weekday := int(at.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		if !slices.Contains(r.Weekdays, weekday) {
+			return false
+		}
+	}
+
+	start, err := ParseClock(r.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := ParseClock(r.EndTime)
+	if err != nil {
+		return false
+	}
+	minute := at.Hour()*60 + at.Minute()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+// Apply returns the adjusted price, never below zero
+func (r PricingRule) Apply(price float64) float64 {
+	adjusted := price + r.Value
+	if r.AdjustmentType == AdjustmentPercentage {
+		adjusted = price * (1 + r.Value/100)
+	}
+	return math.Max(0, math.Round(adjusted*100)/100)
+}
+
+// EffectivePrice tells which pricing rule changed the price of a menu item or variant,
+// empty when the regular price applies
+type EffectivePrice struct {
+	RegularPrice float64 `json:"regular_price,omitempty"`
+	PricingRule  string  `json:"pricing_rule,omitempty"`
+}
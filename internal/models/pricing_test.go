@@ -0,0 +1,138 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPricingRuleCovers(t *testing.T) {
+	cafe := time.FixedZone("cafe", 5*60*60)
+	// 2026-10-12 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, cafe)
+	}
+
+	tests := []struct {
+		name string
+		rule PricingRule
+		at   time.Time
+		want bool
+	}{
+		{
+			name: "inactive rule",
+			rule: PricingRule{StartTime: "15:00", EndTime: "17:00"},
+			at:   at(12, 16, 0),
+			want: false,
+		},
+		{
+			name: "inside window",
+			rule: PricingRule{StartTime: "15:00", EndTime: "17:00", Active: true},
+			at:   at(12, 15, 0),
+			want: true,
+		},
+		{
+			name: "window end is exclusive",
+			rule: PricingRule{StartTime: "15:00", EndTime: "17:00", Active: true},
+			at:   at(12, 17, 0),
+			want: false,
+		},
+		{
+			name: "before window",
+			rule: PricingRule{StartTime: "15:00", EndTime: "17:00", Active: true},
+			at:   at(12, 14, 59),
+			want: false,
+		},
+		{
+			name: "equal times cover the whole day",
+			rule: PricingRule{StartTime: "00:00", EndTime: "00:00", Active: true},
+			at:   at(12, 3, 30),
+			want: true,
+		},
+		{
+			name: "overnight window before midnight",
+			rule: PricingRule{StartTime: "22:00", EndTime: "02:00", Active: true},
+			at:   at(12, 23, 0),
+			want: true,
+		},
+		{
+			name: "overnight window after midnight",
+			rule: PricingRule{StartTime: "22:00", EndTime: "02:00", Active: true},
+			at:   at(13, 1, 59),
+			want: true,
+		},
+		{
+			name: "outside overnight window",
+			rule: PricingRule{StartTime: "22:00", EndTime: "02:00", Active: true},
+			at:   at(13, 12, 0),
+			want: false,
+		},
+		{
+			name: "listed weekday",
+			rule: PricingRule{Weekdays: []int{1, 2}, StartTime: "00:00", EndTime: "00:00", Active: true},
+			at:   at(12, 10, 0),
+			want: true,
+		},
+		{
+			name: "sunday is seven",
+			rule: PricingRule{Weekdays: []int{7}, StartTime: "00:00", EndTime: "00:00", Active: true},
+			at:   at(18, 10, 0),
+			want: true,
+		},
+		{
+			name: "weekday not listed",
+			rule: PricingRule{Weekdays: []int{6, 7}, StartTime: "00:00", EndTime: "00:00", Active: true},
+			at:   at(12, 10, 0),
+			want: false,
+		},
+		{
+			name: "time taken in the cafe time zone",
+			rule: PricingRule{StartTime: "15:00", EndTime: "17:00", Active: true},
+			at:   time.Date(2026, 10, 12, 10, 30, 0, 0, time.UTC),
+			want: true,
+		},
+		{
+			name: "weekday taken in the cafe time zone",
+			rule: PricingRule{Weekdays: []int{1}, StartTime: "00:00", EndTime: "00:00", Active: true},
+			at:   time.Date(2026, 10, 11, 22, 0, 0, 0, time.UTC),
+			want: true,
+		},
+		{
+			name: "invalid time never matches",
+			rule: PricingRule{StartTime: "25:00", EndTime: "17:00", Active: true},
+			at:   at(12, 16, 0),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Covers(tt.at, cafe); got != tt.want {
+				t.Errorf("Covers(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricingRuleApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  PricingRule
+		price float64
+		want  float64
+	}{
+		{"percentage off", PricingRule{AdjustmentType: AdjustmentPercentage, Value: -20}, 5, 4},
+		{"percentage surcharge", PricingRule{AdjustmentType: AdjustmentPercentage, Value: 10}, 3, 3.3},
+		{"percentage rounded to cents", PricingRule{AdjustmentType: AdjustmentPercentage, Value: -15}, 2.99, 2.54},
+		{"fixed amount off", PricingRule{AdjustmentType: AdjustmentFixed, Value: -0.5}, 3, 2.5},
+		{"fixed amount added", PricingRule{AdjustmentType: AdjustmentFixed, Value: 1.25}, 3, 4.25},
+		{"never below zero", PricingRule{AdjustmentType: AdjustmentFixed, Value: -5}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Apply(tt.price); got != tt.want {
+				t.Errorf("Apply(%v) = %v, want %v", tt.price, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// PreparedRecipe is the recipe of a prepared ingredient, e.g. vanilla syrup or cold brew.
+// One batch consumes Components and yields Yield stock units of the prepared ingredient.
+// Components may be prepared ingredients themselves.
+type PreparedRecipe struct {
+	IngredientID int               `json:"ingredient_id"`
+	Name         string            `json:"name,omitempty"`
+	Unit         string            `json:"unit,omitempty"`
+	Yield        float64           `json:"yield"`
+	Components   []RecipeComponent `json:"components"`
+	BatchCost    float64           `json:"batch_cost"`
+	UnitCost     float64           `json:"unit_cost"`
+}
+
+// RecipeComponent is an ingredient of a prepared recipe. UnitCost of a prepared component
+// is rolled up through its own recipe.
+type RecipeComponent struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name,omitempty"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit,omitempty"`
+	Prepared     bool    `json:"prepared"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+}
+
+// ProductionRequest produces Batches batches of a prepared ingredient. Quantity is the actual
+// yield and defaults to the recipe yield of the batches. The produced stock becomes a lot expiring at ExpiresAt.
+type ProductionRequest struct {
+	Batches   int        `json:"batches"`
+	Quantity  float64    `json:"quantity,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ProductionBatch is a recorded production run with the stock it consumed
+type ProductionBatch struct {
+	ID           int                   `json:"production_id"`
+	IngredientID int                   `json:"ingredient_id"`
+	Batches      int                   `json:"batches"`
+	Quantity     float64               `json:"quantity"`
+	Cost         float64               `json:"cost"`
+	UnitCost     float64               `json:"unit_cost"`
+	Consumed     []ProductionComponent `json:"consumed"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+type ProductionComponent struct {
+	IngredientID int     `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Cost         float64 `json:"cost"`
+}
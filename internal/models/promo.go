@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PromoPercentage = "percentage"
+	PromoFixed      = "fixed"
+	PromoBuyXGetY   = "buy_x_get_y"
+)
+
+// PromoCode is a discount customers get by giving the code with the order.
+// Value is the percentage off, the amount off, or for buy_x_get_y the percentage off the Y cheapest
+// items of every X+Y eligible items (100 makes them free). The code applies to the items of ProductID
+// or of CategoryID with its subcategories, to the whole order when neither is set.
+// MinSpend is checked against the whole order, zero limits mean unlimited.
+type PromoCode struct {
+	ID                 int        `json:"promo_code_id"`
+	Code               string     `json:"code"`
+	Description        string     `json:"description,omitempty"`
+	DiscountType       string     `json:"discount_type"`
+	Value              float64    `json:"value"`
+	BuyQuantity        int        `json:"buy_quantity,omitempty"`
+	GetQuantity        int        `json:"get_quantity,omitempty"`
+	MinSpend           float64    `json:"min_spend,omitempty"`
+	ProductID          int        `json:"product_id,omitempty"`
+	CategoryID         int        `json:"category_id,omitempty"`
+	ValidFrom          *time.Time `json:"valid_from,omitempty"`
+	ValidTo            *time.Time `json:"valid_to,omitempty"`
+	MaxUses            int        `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer int        `json:"max_uses_per_customer,omitempty"`
+	Active             bool       `json:"active"`
+	Uses               int        `json:"uses"`
+}
+
+// NormalizePromoCode makes codes case insensitive
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidAt reports why the code can not be used at the given time, nil if it can
+func (p PromoCode) ValidAt(at time.Time) error {
+	if !p.Active {
+		return fmt.Errorf("%w: the code is not active", ErrPromoCodeNotApplicable)
+	}
+	if p.ValidFrom != nil && at.Before(*p.ValidFrom) {
+		return fmt.Errorf("%w: the code is valid from %s", ErrPromoCodeNotApplicable, p.ValidFrom.Format(time.RFC3339))
+	}
+	if p.ValidTo != nil && !at.Before(*p.ValidTo) {
+		return fmt.Errorf("%w: the code expired at %s", ErrPromoCodeNotApplicable, p.ValidTo.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// PromoLine is an order line as the discount engine sees it. InScope tells whether the code applies to it.
+type PromoLine struct {
+	ProductID  int
+	CategoryID int
+	Quantity   int
+	UnitPrice  float64
+	InScope    bool
+}
+
+// Discount computes the discount the code gives on the order lines, rounded to cents
+func (p PromoCode) Discount(lines []PromoLine) (float64, error) {
+	var subtotal, eligible float64
+	var units []float64
+	for _, line := range lines {
+		lineTotal := line.UnitPrice * float64(line.Quantity)
+		subtotal += lineTotal
+		if !line.InScope {
+			continue
+		}
+		eligible += lineTotal
+		for i := 0; i < line.Quantity; i++ {
+			units = append(units, line.UnitPrice)
+		}
+	}
+	if subtotal < p.MinSpend {
+		return 0, fmt.Errorf("%w: minimum spend is %.2f", ErrPromoCodeNotApplicable, p.MinSpend)
+	}
+	if eligible == 0 {
+		return 0, fmt.Errorf("%w: the order has no items the code applies to", ErrPromoCodeNotApplicable)
+	}
+
+	var discount float64
+	switch p.DiscountType {
+	case PromoPercentage:
+		discount = eligible * p.Value / 100
+	case PromoFixed:
+		discount = math.Min(p.Value, eligible)
+	case PromoBuyXGetY:
+		// Most expensive items are bought, the cheapest of every group are discounted
+		sort.Sort(sort.Reverse(sort.Float64Slice(units)))
+		group := p.BuyQuantity + p.GetQuantity
+		for start := 0; start+group <= len(units); start += group {
+			for _, price := range units[start+p.BuyQuantity : start+group] {
+				discount += price * p.Value / 100
+			}
+		}
+		if discount == 0 {
+			return 0, fmt.Errorf("%w: buy %d get %d needs at least %d eligible items", ErrPromoCodeNotApplicable, p.BuyQuantity, p.GetQuantity, group)
+		}
+	}
+	return math.Round(discount*100) / 100, nil
+}
+
+// OrderDiscount is a discount line of an order. Code is kept even when the promo code is deleted.
+type OrderDiscount struct {
+	PromoCodeID int     `json:"promo_code_id,omitempty"`
+	Code        string  `json:"code"`
+	Description string  `json:"description,omitempty"`
+	Amount      float64 `json:"amount"`
+}
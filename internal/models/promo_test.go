@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPromoCodeDiscount(t *testing.T) {
+	line := func(productID, quantity int, price float64, inScope bool) PromoLine {
+		return PromoLine{ProductID: productID, Quantity: quantity, UnitPrice: price, InScope: inScope}
+	}
+
+	tests := []struct {
+		name    string
+		promo   PromoCode
+		lines   []PromoLine
+		want    float64
+		wantErr error
+	}{
+		{
+			name:  "percentage of the lines in scope",
+			promo: PromoCode{DiscountType: PromoPercentage, Value: 10},
+			lines: []PromoLine{line(1, 2, 3, true), line(2, 1, 4, false)},
+			want:  0.6,
+		},
+		{
+			name:  "percentage rounded to cents",
+			promo: PromoCode{DiscountType: PromoPercentage, Value: 15},
+			lines: []PromoLine{line(1, 1, 2.99, true)},
+			want:  0.45,
+		},
+		{
+			name:  "fixed amount",
+			promo: PromoCode{DiscountType: PromoFixed, Value: 2},
+			lines: []PromoLine{line(1, 2, 3, true)},
+			want:  2,
+		},
+		{
+			name:  "fixed amount capped at the eligible total",
+			promo: PromoCode{DiscountType: PromoFixed, Value: 10},
+			lines: []PromoLine{line(1, 2, 3, true), line(2, 1, 4, false)},
+			want:  6,
+		},
+		{
+			name:  "min spend counts the whole order",
+			promo: PromoCode{DiscountType: PromoFixed, Value: 1, MinSpend: 10},
+			lines: []PromoLine{line(1, 2, 3, true), line(2, 1, 4, false)},
+			want:  1,
+		},
+		{
+			name:    "min spend not reached",
+			promo:   PromoCode{DiscountType: PromoFixed, Value: 1, MinSpend: 10.01},
+			lines:   []PromoLine{line(1, 2, 3, true), line(2, 1, 4, false)},
+			wantErr: ErrPromoCodeNotApplicable,
+		},
+		{
+			name:    "no lines in scope",
+			promo:   PromoCode{DiscountType: PromoPercentage, Value: 10},
+			lines:   []PromoLine{line(1, 2, 3, false)},
+			wantErr: ErrPromoCodeNotApplicable,
+		},
+		{
+			name:  "buy two get one discounts the cheapest of the group",
+			promo: PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			lines: []PromoLine{line(1, 1, 5, true), line(2, 1, 4, true), line(3, 1, 3, true)},
+			want:  3,
+		},
+		{
+			name:  "buy two get one leaves an incomplete group alone",
+			promo: PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			lines: []PromoLine{line(1, 1, 5, true), line(2, 1, 4, true), line(3, 2, 3, true), line(4, 1, 2, true)},
+			want:  3,
+		},
+		{
+			name:  "buy two get one over several groups",
+			promo: PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			lines: []PromoLine{line(1, 2, 5, true), line(2, 1, 4, true), line(3, 2, 3, true), line(4, 1, 2, true)},
+			want:  6,
+		},
+		{
+			name:  "buy one get one half price counts units of a line",
+			promo: PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 1, GetQuantity: 1, Value: 50},
+			lines: []PromoLine{line(1, 2, 4, true), line(2, 1, 10, false)},
+			want:  2,
+		},
+		{
+			name:  "buy x get y ignores lines out of scope",
+			promo: PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 1, GetQuantity: 1, Value: 100},
+			lines: []PromoLine{line(1, 1, 4, true), line(2, 1, 1, false), line(3, 1, 3, true)},
+			want:  3,
+		},
+		{
+			name:    "buy x get y without a full group",
+			promo:   PromoCode{DiscountType: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			lines:   []PromoLine{line(1, 2, 5, true), line(2, 3, 1, false)},
+			wantErr: ErrPromoCodeNotApplicable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.promo.Discount(tt.lines)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Discount() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Discount() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Discount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+const (
+	PurchaseOrderStatusDraft             = "draft"
+	PurchaseOrderStatusSent              = "sent"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+)
+
+func IsPurchaseOrderStatus(status string) bool {
+	switch status {
+	case PurchaseOrderStatusDraft, PurchaseOrderStatusSent, PurchaseOrderStatusPartiallyReceived, PurchaseOrderStatusReceived:
+		return true
+	}
+	return false
+}
+
+type Supplier struct {
+	ID          int    `json:"supplier_id"`
+	Name        string `json:"name"`
+	ContactName string `json:"contact_name,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Email       string `json:"email,omitempty"`
+}
+
+type PurchaseOrder struct {
+	ID         int                 `json:"purchase_order_id"`
+	SupplierID int                 `json:"supplier_id"`
+	Status     string              `json:"status"`
+	Notes      string              `json:"notes,omitempty"`
+	Items      []PurchaseOrderItem `json:"items"`
+	Total      float64             `json:"total"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// PurchaseOrderItem is kept in the ingredient stock unit. Unit may be given on input,
+// quantity and unit cost are then converted to the stock unit.
+type PurchaseOrderItem struct {
+	IngredientID     int     `json:"ingredient_id"`
+	Quantity         float64 `json:"quantity"`
+	ReceivedQuantity float64 `json:"received_quantity"`
+	UnitCost         float64 `json:"unit_cost"`
+	Unit             string  `json:"unit,omitempty"`
+}
+
+// Outstanding is the quantity still expected from the supplier
+func (item PurchaseOrderItem) Outstanding() float64 {
+	return item.Quantity - item.ReceivedQuantity
+}
+
+// PurchaseReceipt lists the quantities delivered by the supplier.
+// An empty Items list receives everything still outstanding.
+type PurchaseReceipt struct {
+	Items []ReceivedItem `json:"items"`
+}
+
+// ReceivedItem is a delivered line. Every received line becomes an inventory lot,
+// ExpiresAt is optional for ingredients that do not expire.
+type ReceivedItem struct {
+	IngredientID int        `json:"ingredient_id"`
+	Quantity     float64    `json:"quantity"`
+	Unit         string     `json:"unit,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
@@ -1,18 +1,108 @@
 package models
 
+import "time"
+
+// TotalSales is the revenue report. TotalSales is the gross amount of money taken.
 type TotalSales struct {
-	TotalSales int `json:"total_sales"`
+	SalesSummary
+	GroupBy string        `json:"group_by,omitempty"`
+	Periods []SalesPeriod `json:"periods,omitempty"`
+}
+
+type SalesSummary struct {
+	TotalSales        float64 `json:"total_sales"`
+	NumberOfOrders    int     `json:"number_of_orders"`
+	AverageOrderValue float64 `json:"average_order_value"`
+	UnitsSold         int     `json:"units_sold"`
+}
+
+type SalesPeriod struct {
+	Period string `json:"period"`
+	SalesSummary
+}
+
+// SalesFilter narrows down the revenue report. Zero From/To mean no bound,
+// empty Statuses means every status except cancelled.
+type SalesFilter struct {
+	From     time.Time
+	To       time.Time
+	Statuses []string
+	GroupBy  string
 }
 
 type PopularItems struct {
 	Items []PopularItem `json:"popular_items"`
 }
 
+// PopularItem counts the units of a menu item sold on its own order lines as Quantity, a bundle
+// counting as its own item, and the units served as a bundle component as BundleQuantity
 type PopularItem struct {
-	ProductID   int    `json:"product_id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Quantity    int    `json:"quantity"`
+	ProductID      int              `json:"product_id"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	Quantity       int              `json:"quantity"`
+	BundleQuantity int              `json:"bundle_quantity"`
+	Variants       []PopularVariant `json:"variants,omitempty"`
+}
+
+type PopularVariant struct {
+	Variant  string `json:"variant"`
+	Quantity int    `json:"quantity"`
+}
+
+// CategorySales holds sales of a category including all of its subcategories
+type CategorySales struct {
+	CategoryID int     `json:"category_id"`
+	Name       string  `json:"name"`
+	ParentID   int     `json:"parent_id,omitempty"`
+	UnitsSold  int     `json:"units_sold"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// ItemMargin is the contribution of a menu item over a period. Revenue is net of the promo code
+// discounts the item's lines got, Discounts is that share. Food cost is taken from
+// the ingredients recorded on the sold order lines at their unit costs at the time of sale.
+type ItemMargin struct {
+	ProductID          int     `json:"product_id"`
+	Name               string  `json:"name"`
+	UnitsSold          int     `json:"units_sold"`
+	Discounts          float64 `json:"discounts"`
+	Revenue            float64 `json:"revenue"`
+	FoodCost           float64 `json:"food_cost"`
+	ContributionMargin float64 `json:"contribution_margin"`
+	MarginPercent      float64 `json:"margin_percent"`
+}
+
+// UsageVariance compares what the sold items should have used by recipe with what left the stock.
+// ActualUsage is what sales, waste and count corrections took from the stock, cancelled sales given back.
+// Waste is the part of it written off as waste.
+type UsageVariance struct {
+	IngredientID     int     `json:"ingredient_id"`
+	Name             string  `json:"name"`
+	Unit             string  `json:"unit"`
+	TheoreticalUsage float64 `json:"theoretical_usage"`
+	ActualUsage      float64 `json:"actual_usage"`
+	Waste            float64 `json:"waste"`
+	Variance         float64 `json:"variance"`
+	VarianceCost     float64 `json:"variance_cost"`
+}
+
+// DiscountReport sums up the promo code discounts of the orders in a period, cancelled orders excluded.
+// GrossSales of a code is what its orders would have cost without any discount, NetSales what was charged.
+type DiscountReport struct {
+	DiscountedOrders int              `json:"discounted_orders"`
+	TotalDiscount    float64          `json:"total_discount"`
+	Codes            []PromoCodeUsage `json:"codes"`
+}
+
+type PromoCodeUsage struct {
+	PromoCodeID   int     `json:"promo_code_id,omitempty"`
+	Code          string  `json:"code"`
+	Orders        int     `json:"orders"`
+	Customers     int     `json:"customers"`
+	TotalDiscount float64 `json:"total_discount"`
+	GrossSales    float64 `json:"gross_sales"`
+	NetSales      float64 `json:"net_sales"`
 }
 
 type SearchResult struct {
@@ -27,6 +117,7 @@ type SearchMenuItem struct {
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	Relevance   float64 `json:"relavance"`
+	DietaryInfo
 }
 
 type SearchOrderResult struct {
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusCommitted = "committed"
+)
+
+// Stocktake is a physical count session. Counts can be resubmitted while it is open.
+type Stocktake struct {
+	ID          int              `json:"stocktake_id"`
+	Status      string           `json:"status"`
+	Notes       string           `json:"notes,omitempty"`
+	Counts      []StocktakeCount `json:"counts"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CommittedAt *time.Time       `json:"committed_at,omitempty"`
+}
+
+type StocktakeCount struct {
+	IngredientID    int `json:"ingredient_id"`
+	CountedQuantity int `json:"counted_quantity"`
+}
+
+type StocktakeCountsRequest struct {
+	Counts []StocktakeCount `json:"counts"`
+}
+
+// StocktakeVariance compares counted quantities with the system ones. Before commit the
+// current inventory is used, after commit the quantities the counts replaced.
+type StocktakeVariance struct {
+	StocktakeID       int            `json:"stocktake_id"`
+	Status            string         `json:"status"`
+	Lines             []VarianceLine `json:"lines"`
+	TotalVarianceCost float64        `json:"total_variance_cost"`
+}
+
+type VarianceLine struct {
+	IngredientID    int     `json:"ingredient_id"`
+	Name            string  `json:"name"`
+	Unit            string  `json:"unit"`
+	SystemQuantity  int     `json:"system_quantity"`
+	CountedQuantity int     `json:"counted_quantity"`
+	Variance        int     `json:"variance"`
+	VarianceCost    float64 `json:"variance_cost"`
+}
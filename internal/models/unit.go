@@ -0,0 +1,84 @@
+package models
+
+import "fmt"
+
+// Unit dimensions. Quantities convert only between units of the same dimension.
+const (
+	DimensionMass   = "mass"
+	DimensionVolume = "volume"
+	DimensionCount  = "count"
+	DimensionShots  = "shots" // espresso shots, a shot is not interchangeable with a piece
+)
+
+type unitDefinition struct {
+	dimension string
+	toBase    float64 // how many base units (g, ml, pcs, shots) one unit holds
+}
+
+var standardUnits = map[string]unitDefinition{
+	"mg":    {DimensionMass, 0.001},
+	"g":     {DimensionMass, 1},
+	"kg":    {DimensionMass, 1000},
+	"oz":    {DimensionMass, 28.349523125},
+	"lb":    {DimensionMass, 453.59237},
+	"ml":    {DimensionVolume, 1},
+	"cl":    {DimensionVolume, 10},
+	"l":     {DimensionVolume, 1000},
+	"fl_oz": {DimensionVolume, 29.5735295625},
+	"pcs":   {DimensionCount, 1},
+	"shots": {DimensionShots, 1},
+	"dozen": {DimensionCount, 12},
+}
+
+// stockUnits are the units inventory is kept in, see the unit_types type in init.sql
+var stockUnits = map[string]bool{"g": true, "ml": true, "shots": true, "pcs": true}
+
+func IsStandardUnit(unit string) bool {
+	_, ok := standardUnits[unit]
+	return ok
+}
+
+func IsStockUnit(unit string) bool {
+	return stockUnits[unit]
+}
+
+// ConvertUnits converts a quantity between two standard units of the same dimension
+func ConvertUnits(quantity float64, from, to string) (float64, error) {
+	fromUnit, ok := standardUnits[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, from)
+	}
+	toUnit, ok := standardUnits[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, to)
+	}
+	if fromUnit.dimension != toUnit.dimension {
+		return 0, fmt.Errorf("%w: %s is %s, %s is %s", ErrIncompatibleUnits, from, fromUnit.dimension, to, toUnit.dimension)
+	}
+	if from == to {
+		return quantity, nil
+	}
+	return quantity * fromUnit.toBase / toUnit.toBase, nil
+}
+
+// UnitConversion is an ingredient specific unit, e.g. 1 bag = 1000 g
+type UnitConversion struct {
+	IngredientID int     `json:"ingredient_id"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	ToUnit       string  `json:"to_unit"`
+}
+
+// ConvertToStockUnit converts a quantity of an ingredient given in unit to its stock unit.
+// An empty unit means the stock unit. Custom conversions of the ingredient are tried before standard units.
+func ConvertToStockUnit(quantity float64, unit, stockUnit string, conversions []UnitConversion) (float64, error) {
+	if unit == "" || unit == stockUnit {
+		return quantity, nil
+	}
+	for _, conversion := range conversions {
+		if conversion.Unit == unit {
+			return ConvertUnits(quantity*conversion.Quantity, conversion.ToUnit, stockUnit)
+		}
+	}
+	return ConvertUnits(quantity, unit, stockUnit)
+}
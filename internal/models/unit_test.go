@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		from     string
+		to       string
+		want     float64
+		wantErr  error
+	}{
+		{name: "same unit", quantity: 250, from: "g", to: "g", want: 250},
+		{name: "kilograms to grams", quantity: 1.5, from: "kg", to: "g", want: 1500},
+		{name: "milligrams to grams", quantity: 500, from: "mg", to: "g", want: 0.5},
+		{name: "pounds to grams", quantity: 1, from: "lb", to: "g", want: 453.59237},
+		{name: "liters to milliliters", quantity: 0.25, from: "l", to: "ml", want: 250},
+		{name: "fluid ounces to milliliters", quantity: 2, from: "fl_oz", to: "ml", want: 59.147059125},
+		{name: "dozen to pieces", quantity: 2, from: "dozen", to: "pcs", want: 24},
+		{name: "shots to shots", quantity: 2, from: "shots", to: "shots", want: 2},
+		{name: "mass to volume", quantity: 1, from: "g", to: "ml", wantErr: ErrIncompatibleUnits},
+		{name: "shots are not pieces", quantity: 1, from: "shots", to: "pcs", wantErr: ErrIncompatibleUnits},
+		{name: "dozen is not shots", quantity: 1, from: "dozen", to: "shots", wantErr: ErrIncompatibleUnits},
+		{name: "unknown source unit", quantity: 1, from: "cup", to: "ml", wantErr: ErrUnknownUnit},
+		{name: "unknown target unit", quantity: 1, from: "ml", to: "cup", wantErr: ErrUnknownUnit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertUnits(tt.quantity, tt.from, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ConvertUnits() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertUnits() unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ConvertUnits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertToStockUnit(t *testing.T) {
+	conversions := []UnitConversion{
+		{IngredientID: 1, Unit: "bag", Quantity: 1, ToUnit: "kg"},
+		{IngredientID: 1, Unit: "scoop", Quantity: 15, ToUnit: "g"},
+		{IngredientID: 1, Unit: "kg", Quantity: 900, ToUnit: "g"},
+	}
+
+	tests := []struct {
+		name      string
+		quantity  float64
+		unit      string
+		stockUnit string
+		want      float64
+		wantErr   error
+	}{
+		{name: "empty unit is the stock unit", quantity: 18, unit: "", stockUnit: "g", want: 18},
+		{name: "stock unit", quantity: 18, unit: "g", stockUnit: "g", want: 18},
+		{name: "standard unit", quantity: 0.2, unit: "l", stockUnit: "ml", want: 200},
+		{name: "custom unit", quantity: 2, unit: "scoop", stockUnit: "g", want: 30},
+		{name: "custom unit through a standard unit", quantity: 2, unit: "bag", stockUnit: "g", want: 2000},
+		{name: "custom unit before standard unit", quantity: 1, unit: "kg", stockUnit: "g", want: 900},
+		{name: "custom unit of another dimension", quantity: 1, unit: "scoop", stockUnit: "ml", wantErr: ErrIncompatibleUnits},
+		{name: "unknown unit", quantity: 1, unit: "pinch", stockUnit: "g", wantErr: ErrUnknownUnit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertToStockUnit(tt.quantity, tt.unit, tt.stockUnit, conversions)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ConvertToStockUnit() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertToStockUnit() unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ConvertToStockUnit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Reason codes of written off stock, see the waste_reason type in init.sql
+const (
+	WasteReasonSpoiled = "spoiled"
+	WasteReasonExpired = "expired"
+	WasteReasonSpilled = "spilled"
+	WasteReasonDamaged = "damaged"
+	WasteReasonOther   = "other"
+)
+
+func IsWasteReason(reason string) bool {
+	switch reason {
+	case WasteReasonSpoiled, WasteReasonExpired, WasteReasonSpilled, WasteReasonDamaged, WasteReasonOther:
+		return true
+	}
+	return false
+}
+
+// WasteRequest writes off ingredients or prepared menu items. Each item sets either
+// IngredientID or ProductID, menu items are expanded through their recipe.
+type WasteRequest struct {
+	Reason string      `json:"reason"`
+	Notes  string      `json:"notes,omitempty"`
+	Items  []WasteItem `json:"items"`
+}
+
+type WasteItem struct {
+	IngredientID int `json:"ingredient_id,omitempty"`
+	ProductID    int `json:"product_id,omitempty"`
+	Quantity     int `json:"quantity"`
+}
+
+// WasteLog is a recorded write-off with the ingredients it took from the stock
+type WasteLog struct {
+	ID        int            `json:"waste_id"`
+	Reason    string         `json:"reason"`
+	Notes     string         `json:"notes,omitempty"`
+	Items     []WasteLogItem `json:"items"`
+	TotalCost float64        `json:"total_cost"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type WasteLogItem struct {
+	IngredientID int     `json:"ingredient_id"`
+	ProductID    int     `json:"product_id,omitempty"`
+	Quantity     int     `json:"quantity"`
+	Cost         float64 `json:"cost"`
+}
+
+// WasteFilter narrows down the waste report. Zero From/To mean no bound, To is exclusive.
+type WasteFilter struct {
+	From    time.Time
+	To      time.Time
+	GroupBy string
+}
+
+type WasteReport struct {
+	TotalCost    float64             `json:"total_cost"`
+	ByReason     []WasteByReason     `json:"by_reason"`
+	ByIngredient []WasteByIngredient `json:"by_ingredient"`
+	GroupBy      string              `json:"group_by,omitempty"`
+	Periods      []WastePeriod       `json:"periods,omitempty"`
+}
+
+type WasteByReason struct {
+	Reason  string  `json:"reason"`
+	Entries int     `json:"entries"`
+	Cost    float64 `json:"cost"`
+}
+
+type WasteByIngredient struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     int     `json:"quantity"`
+	Cost         float64 `json:"cost"`
+}
+
+type WastePeriod struct {
+	Period string  `json:"period"`
+	Cost   float64 `json:"cost"`
+}
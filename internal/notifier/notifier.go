@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+// Notifier delivers low stock alerts. Implementations must not block the caller for long
+// and handle their own delivery errors.
+type Notifier interface {
+	NotifyLowStock(alerts []models.LowStockAlert)
+}
+
+// LogNotifier writes alerts to the application log
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) NotifyLowStock(alerts []models.LowStockAlert) {
+	for _, alert := range alerts {
+		n.logger.Warn("Low stock",
+			"ingredient_id", alert.IngredientID,
+			"name", alert.Name,
+			"quantity", alert.Quantity,
+			"unit", alert.Unit,
+			"reorder_level", alert.ReorderLevel,
+			"suggested_order", alert.SuggestedOrder,
+		)
+	}
+}
+
+// WebhookNotifier posts alerts as JSON to the configured URL in the background
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+	logger *slog.Logger
+}
+
+func NewWebhookNotifier(url string, logger *slog.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+	}
+}
+
+type webhookPayload struct {
+	Event  string                 `json:"event"`
+	SentAt time.Time              `json:"sent_at"`
+	Alerts []models.LowStockAlert `json:"alerts"`
+}
+
+func (n *WebhookNotifier) NotifyLowStock(alerts []models.LowStockAlert) {
+	const op = "notifier.WebhookNotifier.NotifyLowStock"
+	payload := webhookPayload{Event: "low_stock", SentAt: time.Now(), Alerts: alerts}
+	go func() {
+		if err := n.send(payload); err != nil {
+			n.logger.Error(fmt.Sprintf("%s: Error sending low stock webhook", op), "error", err, "url", n.url)
+		}
+	}()
+}
+
+func (n *WebhookNotifier) send(payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Multi sends alerts to every notifier in the list
+type Multi []Notifier
+
+func (m Multi) NotifyLowStock(alerts []models.LowStockAlert) {
+	for _, n := range m {
+		n.NotifyLowStock(alerts)
+	}
+}
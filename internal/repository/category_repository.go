@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type CategoryRepositoryInterface interface {
+	GetAll() ([]models.Category, error)
+	GetByID(id int) (models.Category, error)
+	Add(category models.Category) (int, error)
+	Update(category models.Category) error
+	Delete(id int) error
+	GetDescendantIDs(id int) ([]int, error)
+}
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (repo *CategoryRepository) GetAll() ([]models.Category, error) {
+	query := `
+	select ID, Name, COALESCE(ParentID, 0), DisplayOrder from categories
+	order by DisplayOrder, Name
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.ParentID, &category.DisplayOrder); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (repo *CategoryRepository) GetByID(id int) (models.Category, error) {
+	query := `
+	select ID, Name, COALESCE(ParentID, 0), DisplayOrder from categories where ID = $1
+	`
+	var category models.Category
+	err := repo.db.QueryRow(query, id).Scan(&category.ID, &category.Name, &category.ParentID, &category.DisplayOrder)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Category{}, models.ErrCategoryNotFound
+		}
+		return models.Category{}, err
+	}
+	return category, nil
+}
+
+func (repo *CategoryRepository) Add(category models.Category) (int, error) {
+	query := `
+	insert into categories (Name, ParentID, DisplayOrder) values
+	($1, $2, $3)
+	returning ID
+	`
+	var id int
+	err := repo.db.QueryRow(query, category.Name, nullableID(category.ParentID), category.DisplayOrder).Scan(&id)
+	return id, err
+}
+
+func (repo *CategoryRepository) Update(category models.Category) error {
+	query := `
+	update categories
+	set Name = $1, ParentID = $2, DisplayOrder = $3
+	where ID = $4
+	`
+	_, err := repo.db.Exec(query, category.Name, nullableID(category.ParentID), category.DisplayOrder, category.ID)
+	return err
+}
+
+// Delete removes the category. Its subcategories become top level and its menu items uncategorized.
+func (repo *CategoryRepository) Delete(id int) error {
+	_, err := repo.db.Exec(`delete from categories where ID = $1`, id)
+	return err
+}
+
+// GetDescendantIDs returns the category ID together with the IDs of all nested subcategories
+func (repo *CategoryRepository) GetDescendantIDs(id int) ([]int, error) {
+	return categoryTreeIDs(repo.db, id)
+}
+
+func categoryTreeIDs(q querier, id int) ([]int, error) {
+	query := `
+	with recursive tree as (
+		select ID from categories where ID = $1
+		union all
+		select c.ID from categories c join tree t on c.ParentID = t.ID
+	)
+	select ID from tree
+	`
+	rows, err := q.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var categoryID int
+		if err := rows.Scan(&categoryID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, categoryID)
+	}
+	return ids, rows.Err()
+}
+
+// nullableID maps a zero ID to SQL NULL
+func nullableID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -4,19 +4,30 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/sunzhqr/frappuccino/internal/models"
 )
 
 type InventoryRepositoryInterface interface {
 	GetAll() ([]models.InventoryItem, error)
+	GetByIDs(ids []int) ([]models.InventoryItem, error)
+	GetLowStock() ([]models.InventoryItem, error)
 	Exists(ID int) bool
 	SubtractIngredients(ingredients map[int]float64) error
 	AddInventoryItemRepo(item models.InventoryItem) error
 	UpdateItemRepo(id int, newItem models.InventoryItem) error
 	DeleteItemRepo(id int) error
 	GetLeftOvers(sortBy, page, pageSize string) (map[string]any, error)
+	GetTransactions(filter models.TransactionFilter) (models.TransactionPage, error)
+	GetConversions(ingredientID int) ([]models.UnitConversion, error)
+	SaveConversion(conversion models.UnitConversion) error
+	DeleteConversion(ingredientID int, unit string) (bool, error)
+	GetLots(ingredientID int) ([]models.InventoryLot, error)
+	GetExpiringLots(until time.Time) ([]models.InventoryLot, error)
 }
 
 type InventoryRepository struct {
@@ -27,25 +38,61 @@ func NewInventoryRepository(db *sql.DB) *InventoryRepository {
 	return &InventoryRepository{db: db}
 }
 
+// setInventoryReason makes the inventory trigger log every stock change done in tx under reason.
+// reference names the document behind the change, e.g. "order:12", and may be empty.
+func setInventoryReason(tx *sql.Tx, reason, reference string) error {
+	_, err := tx.Exec(`
+		SELECT set_config('frappuccino.inventory_reason', $1, true),
+			set_config('frappuccino.inventory_reference', $2, true)
+	`, reason, reference)
+	return err
+}
+
+// lockInventoryRows locks the inventory rows of the ingredients until tx ends. Rows are always
+// locked in ingredient order, so transactions changing several ingredients do not deadlock.
+func lockInventoryRows(tx *sql.Tx, ingredientIDs []int) error {
+	_, err := tx.Exec(`select IngredientID from inventory where IngredientID = any($1) order by IngredientID for update`, pq.Array(ingredientIDs))
+	return err
+}
+
 func (repo *InventoryRepository) GetAll() ([]models.InventoryItem, error) {
+	return repo.getItems(``)
+}
+
+func (repo *InventoryRepository) GetByIDs(ids []int) ([]models.InventoryItem, error) {
+	return repo.getItems(`where IngredientID = ANY($1)`, pq.Array(ids))
+}
+
+// GetLowStock returns the ingredients at or below their reorder level, the most depleted first
+func (repo *InventoryRepository) GetLowStock() ([]models.InventoryItem, error) {
+	return repo.getItems(`where ReorderLevel > 0 and Quantity <= ReorderLevel order by Quantity::numeric / ReorderLevel, Name`)
+}
+
+func (repo *InventoryRepository) getItems(where string, args ...any) ([]models.InventoryItem, error) {
 	queryGetIngredients := `
-	select IngredientID, Name, Quantity, Unit from inventory
-	`
-	rows, err := repo.db.Query(queryGetIngredients)
+	select IngredientID, Name, Quantity, Unit, ReorderLevel, ParLevel, UnitCost, Allergens, Dietary,
+		Calories, Protein, Fat, Carbohydrates, Sugar
+	from inventory
+	` + where
+	rows, err := repo.db.Query(queryGetIngredients, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var inventoryItems []models.InventoryItem
 
 	for rows.Next() {
 		var inventoryItem models.InventoryItem
-		err = rows.Scan(&inventoryItem.IngredientID, &inventoryItem.Name, &inventoryItem.Quantity, &inventoryItem.Unit)
+		err = rows.Scan(&inventoryItem.IngredientID, &inventoryItem.Name, &inventoryItem.Quantity, &inventoryItem.Unit, &inventoryItem.ReorderLevel, &inventoryItem.ParLevel, &inventoryItem.UnitCost,
+			pq.Array(&inventoryItem.Allergens), pq.Array(&inventoryItem.Dietary),
+			&inventoryItem.Nutrition.Calories, &inventoryItem.Nutrition.Protein, &inventoryItem.Nutrition.Fat,
+			&inventoryItem.Nutrition.Carbohydrates, &inventoryItem.Nutrition.Sugar)
 		if err != nil {
 			return nil, err
 		}
 		inventoryItems = append(inventoryItems, inventoryItem)
 	}
-	return inventoryItems, nil
+	return inventoryItems, rows.Err()
 }
 
 func (repo *InventoryRepository) Exists(ID int) bool {
@@ -76,10 +123,13 @@ func (repo *InventoryRepository) SubtractIngredients(ingredients map[int]float64
 
 func (repo *InventoryRepository) AddInventoryItemRepo(item models.InventoryItem) error {
 	queryToAddInventory := `
-	insert into inventory (Name, Quantity, Unit) values
-	($1, $2, $3)
+	insert into inventory (Name, Quantity, Unit, ReorderLevel, ParLevel, UnitCost, Allergens, Dietary,
+		Calories, Protein, Fat, Carbohydrates, Sugar) values
+	($1, $2, $3, $4, $5, $6, coalesce($7, '{}'::text[]), coalesce($8, '{}'::text[]), $9, $10, $11, $12, $13)
 	`
-	_, err := repo.db.Exec(queryToAddInventory, item.Name, item.Quantity, item.Unit)
+	_, err := repo.db.Exec(queryToAddInventory, item.Name, item.Quantity, item.Unit, item.ReorderLevel, item.ParLevel, item.UnitCost,
+		pq.Array(item.Allergens), pq.Array(item.Dietary),
+		item.Nutrition.Calories, item.Nutrition.Protein, item.Nutrition.Fat, item.Nutrition.Carbohydrates, item.Nutrition.Sugar)
 	if err != nil {
 		return err
 	}
@@ -87,16 +137,29 @@ func (repo *InventoryRepository) AddInventoryItemRepo(item models.InventoryItem)
 }
 
 func (repo *InventoryRepository) UpdateItemRepo(id int, newItem models.InventoryItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	queryToUpdate := `
 	update inventory
-	set Quantity = $1, Name = $2, Unit = $3
-	where IngredientID = $4
+	set Quantity = $1, Name = $2, Unit = $3, ReorderLevel = $4, ParLevel = $5, UnitCost = $6,
+		Allergens = coalesce($8, '{}'::text[]), Dietary = coalesce($9, '{}'::text[]),
+		Calories = $10, Protein = $11, Fat = $12, Carbohydrates = $13, Sugar = $14
+	where IngredientID = $7
 	`
-	_, err := repo.db.Exec(queryToUpdate, newItem.Quantity, newItem.Name, newItem.Unit, id)
+	_, err = tx.Exec(queryToUpdate, newItem.Quantity, newItem.Name, newItem.Unit, newItem.ReorderLevel, newItem.ParLevel, newItem.UnitCost, id,
+		pq.Array(newItem.Allergens), pq.Array(newItem.Dietary),
+		newItem.Nutrition.Calories, newItem.Nutrition.Protein, newItem.Nutrition.Fat, newItem.Nutrition.Carbohydrates, newItem.Nutrition.Sugar)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := trimLots(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (repo *InventoryRepository) DeleteItemRepo(id int) error {
@@ -124,14 +187,14 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 	offset := (pageNum - 1) * pageSizeNum
 
 	query := `
-        SELECT i.IngredientID, i.Name, i.Quantity, i.Unit
+        SELECT i.IngredientID, i.Name, i.Quantity, i.Unit, i.UnitCost
         FROM inventory i
     `
 	switch sortBy {
 	case "price":
-		query += " ORDER BY i.Price"
+		query += " ORDER BY i.UnitCost, i.IngredientID"
 	case "quantity":
-		query += " ORDER BY i.Quantity"
+		query += " ORDER BY i.Quantity, i.IngredientID"
 	default:
 		return nil, errors.New("invalid sortBy value, must be 'price' or 'quantity'")
 	}
@@ -150,7 +213,8 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 		var name string
 		var quantity int
 		var unit string
-		if err := rows.Scan(&ingredientID, &name, &quantity, &unit); err != nil {
+		var unitCost float64
+		if err := rows.Scan(&ingredientID, &name, &quantity, &unit, &unitCost); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 
@@ -159,6 +223,8 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 			"name":         name,
 			"quantity":     quantity,
 			"unit":         unit,
+			"unitCost":     unitCost,
+			"stockValue":   math.Round(float64(quantity)*unitCost*100) / 100,
 		})
 	}
 
@@ -185,3 +251,260 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 
 	return response, nil
 }
+
+// GetTransactions returns a page of the inventory ledger, newest first
+func (repo *InventoryRepository) GetTransactions(filter models.TransactionFilter) (models.TransactionPage, error) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.IngredientID != 0 {
+		where += fmt.Sprintf(" AND IngredientID = $%d", argIndex)
+		args = append(args, filter.IngredientID)
+		argIndex++
+	}
+	if !filter.From.IsZero() {
+		where += fmt.Sprintf(" AND created_at >= $%d", argIndex)
+		args = append(args, filter.From)
+		argIndex++
+	}
+	if !filter.To.IsZero() {
+		where += fmt.Sprintf(" AND created_at < $%d", argIndex)
+		args = append(args, filter.To)
+		argIndex++
+	}
+	if len(filter.Reasons) > 0 {
+		where += fmt.Sprintf(" AND reason = ANY($%d::inventory_reason[])", argIndex)
+		args = append(args, pq.Array(filter.Reasons))
+		argIndex++
+	}
+
+	var totalItems int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM inventory_transactions"+where, args...).Scan(&totalItems); err != nil {
+		return models.TransactionPage{}, fmt.Errorf("failed to count transactions: %v", err)
+	}
+
+	query := `
+		SELECT transactionId, IngredientID, quantity_change, reason, COALESCE(reference, ''), created_at
+		FROM inventory_transactions` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC, transactionId DESC LIMIT %d OFFSET %d", filter.PageSize, (filter.Page-1)*filter.PageSize)
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return models.TransactionPage{}, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	transactions := []models.InventoryTransaction{}
+	for rows.Next() {
+		var transaction models.InventoryTransaction
+		err := rows.Scan(&transaction.ID, &transaction.IngredientID, &transaction.QuantityChange, &transaction.Reason, &transaction.Reference, &transaction.CreatedAt)
+		if err != nil {
+			return models.TransactionPage{}, fmt.Errorf("failed to scan row: %v", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return models.TransactionPage{}, fmt.Errorf("rows iteration error: %v", err)
+	}
+
+	totalPages := (totalItems + filter.PageSize - 1) / filter.PageSize
+	return models.TransactionPage{
+		CurrentPage: filter.Page,
+		HasNextPage: filter.Page < totalPages,
+		PageSize:    filter.PageSize,
+		TotalPages:  totalPages,
+		Data:        transactions,
+	}, nil
+}
+
+func (repo *InventoryRepository) GetConversions(ingredientID int) ([]models.UnitConversion, error) {
+	query := `
+	select IngredientID, Unit, Quantity, ToUnit from ingredient_unit_conversions
+	where IngredientID = $1
+	order by Unit
+	`
+	rows, err := repo.db.Query(query, ingredientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversions := []models.UnitConversion{}
+	for rows.Next() {
+		var conversion models.UnitConversion
+		if err := rows.Scan(&conversion.IngredientID, &conversion.Unit, &conversion.Quantity, &conversion.ToUnit); err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, conversion)
+	}
+	return conversions, rows.Err()
+}
+
+// SaveConversion adds a custom unit of the ingredient or redefines an existing one
+func (repo *InventoryRepository) SaveConversion(conversion models.UnitConversion) error {
+	query := `
+	insert into ingredient_unit_conversions (IngredientID, Unit, Quantity, ToUnit) values
+	($1, $2, $3, $4)
+	on conflict (IngredientID, Unit) do update set Quantity = excluded.Quantity, ToUnit = excluded.ToUnit
+	`
+	_, err := repo.db.Exec(query, conversion.IngredientID, conversion.Unit, conversion.Quantity, conversion.ToUnit)
+	return err
+}
+
+// DeleteConversion removes a custom unit, reporting whether it existed
+func (repo *InventoryRepository) DeleteConversion(ingredientID int, unit string) (bool, error) {
+	result, err := repo.db.Exec(`delete from ingredient_unit_conversions where IngredientID = $1 and Unit = $2`, ingredientID, unit)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// GetLots returns the lots of the ingredient that still have stock, in consumption order
+func (repo *InventoryRepository) GetLots(ingredientID int) ([]models.InventoryLot, error) {
+	return repo.getLots(`where l.IngredientID = $1 and l.Quantity > 0
+	order by l.ExpiresAt nulls last, l.ReceivedAt, l.ID`, ingredientID)
+}
+
+// GetExpiringLots returns the lots with stock that expire before until, expired ones included
+func (repo *InventoryRepository) GetExpiringLots(until time.Time) ([]models.InventoryLot, error) {
+	return repo.getLots(`where l.Quantity > 0 and l.ExpiresAt <= $1
+	order by l.ExpiresAt, i.Name, l.ID`, until)
+}
+
+func (repo *InventoryRepository) getLots(where string, args ...any) ([]models.InventoryLot, error) {
+	query := `
+	select l.ID, l.IngredientID, i.Name, i.Unit, coalesce(l.PurchaseOrderID, 0), l.ReceivedQuantity, l.Quantity,
+		l.ReceivedAt, l.ExpiresAt, coalesce(l.ExpiresAt <= now(), false)
+	from inventory_lots l
+	join inventory i on i.IngredientID = l.IngredientID
+	` + where
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []models.InventoryLot{}
+	for rows.Next() {
+		var lot models.InventoryLot
+		var expiresAt sql.NullTime
+		err := rows.Scan(&lot.ID, &lot.IngredientID, &lot.Name, &lot.Unit, &lot.PurchaseOrderID, &lot.ReceivedQuantity, &lot.Quantity,
+			&lot.ReceivedAt, &expiresAt, &lot.Expired)
+		if err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			lot.ExpiresAt = &expiresAt.Time
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// Lots are only changed while the inventory row of their ingredient is locked by the same
+// transaction, so the helpers below do not lock lot rows themselves. Stock on hand may be
+// larger than the sum of the lots, the difference is stock not tracked in lots
+// (initial stock, cancelled orders), but never smaller.
+
+// addLot records a received batch of the ingredient. purchaseOrderID 0 means no purchase order.
+func addLot(tx *sql.Tx, ingredientID, purchaseOrderID int, quantity float64, expiresAt *time.Time) error {
+	query := `
+	insert into inventory_lots (IngredientID, PurchaseOrderID, ReceivedQuantity, Quantity, ExpiresAt) values
+	($1, $2, $3, $3, $4)
+	`
+	_, err := tx.Exec(query, ingredientID, nullableID(purchaseOrderID), quantity, expiresAt)
+	return err
+}
+
+// depleteLots takes up to quantity from the lots of the ingredient, earliest expiry first and
+// oldest first within the same expiry. Expired lots are skipped unless includeExpired is set.
+// Returns the part of quantity the lots could not cover and what was taken from each lot.
+func depleteLots(tx *sql.Tx, ingredientID int, quantity float64, includeExpired bool) (float64, []models.LotUsage, error) {
+	query := `
+	select ID, Quantity, ReceivedAt, ExpiresAt, coalesce(ExpiresAt <= now(), false)
+	from inventory_lots
+	where IngredientID = $1 and Quantity > 0
+	order by ID
+	for update
+	`
+	rows, err := tx.Query(query, ingredientID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to consume lots: %w", err)
+	}
+	defer rows.Close()
+
+	var lots []models.InventoryLot
+	for rows.Next() {
+		var lot models.InventoryLot
+		if err := rows.Scan(&lot.ID, &lot.Quantity, &lot.ReceivedAt, &lot.ExpiresAt, &lot.Expired); err != nil {
+			return 0, nil, fmt.Errorf("failed to consume lots: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to consume lots: %w", err)
+	}
+	rows.Close()
+
+	usages, left := models.DepleteLots(lots, quantity, includeExpired)
+	for _, usage := range usages {
+		_, err := tx.Exec(`update inventory_lots set Quantity = Quantity - $1::numeric where ID = $2`, usage.Quantity, usage.LotID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to consume lots: %w", err)
+		}
+	}
+	return left, usages, nil
+}
+
+// consumeLots takes quantity sold from the lots of the ingredient after its stock on hand was
+// already decreased by it. Expired lots are never sold: what the fresh lots do not cover comes
+// from stock not tracked in lots, and the sale fails when only expired stock is left.
+// Returns what was taken from each lot.
+func consumeLots(tx *sql.Tx, ingredientID int, quantity float64) ([]models.LotUsage, error) {
+	var untracked float64
+	query := `
+	select i.Quantity + $2 - coalesce(sum(l.Quantity), 0)
+	from inventory i
+	left join inventory_lots l on l.IngredientID = i.IngredientID
+	where i.IngredientID = $1
+	group by i.Quantity
+	`
+	if err := tx.QueryRow(query, ingredientID, quantity).Scan(&untracked); err != nil {
+		return nil, err
+	}
+
+	left, usages, err := depleteLots(tx, ingredientID, quantity, false)
+	if err != nil {
+		return nil, err
+	}
+	if left > untracked {
+		return nil, fmt.Errorf("%w: ingredient %d, the rest of the stock is expired", models.ErrInsufficientInventory, ingredientID)
+	}
+	return usages, nil
+}
+
+// trimLots takes the difference from the lots, expired first, when the stock on hand of
+// the ingredient was set below what its lots hold, e.g. by a count correction
+func trimLots(tx *sql.Tx, ingredientID int) error {
+	var excess float64
+	query := `
+	select coalesce(sum(l.Quantity), 0) - i.Quantity
+	from inventory i
+	left join inventory_lots l on l.IngredientID = i.IngredientID
+	where i.IngredientID = $1
+	group by i.Quantity
+	`
+	if err := tx.QueryRow(query, ingredientID).Scan(&excess); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if excess <= 0 {
+		return nil
+	}
+	_, _, err := depleteLots(tx, ingredientID, excess, true)
+	return err
+}
@@ -2,12 +2,21 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"math"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/sunzhqr/frappuccino/internal/models"
 )
 
 type MenuRepositoryInterface interface {
 	GetAll() ([]models.MenuItem, error)
+	GetFiltered(filter models.MenuFilter) ([]models.MenuItem, error)
+	GetAvailableServings(menuItemID int) (*int, error)
+	GetIngredientCosts(menuItemID int) ([]models.IngredientCost, error)
+	GetIngredientNutrition(menuItemID int) ([]models.IngredientNutrition, error)
+	GetBundleSlots(menuItemID int) ([]models.BundleSlot, error)
 	Exists(itemID int) bool
 	DeleteMenuItemRepo(MenuItemID int) error
 	UpdateMenuItemRepo(menuItem models.MenuItem) error
@@ -17,26 +26,51 @@ type MenuRepositoryInterface interface {
 
 // MenuRepository implements MenuRepository using JSON files
 type MenuRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	location *time.Location
 }
 
-// NewMenuRepository creates a new FileMenuRepository
-func NewMenuRepository(db *sql.DB) *MenuRepository {
-	return &MenuRepository{db: db}
+// NewMenuRepository creates a new FileMenuRepository. Pricing rules are matched in the cafe time zone location.
+func NewMenuRepository(db *sql.DB, location *time.Location) *MenuRepository {
+	return &MenuRepository{db: db, location: location}
 }
 
 func (repo *MenuRepository) GetAll() ([]models.MenuItem, error) {
+	return repo.GetFiltered(models.MenuFilter{})
+}
+
+func (repo *MenuRepository) GetFiltered(filter models.MenuFilter) ([]models.MenuItem, error) {
 	queryMenuItems := `
-	select ID, Name, Description, Price from menu_items
+	select ID, Name, Description, Price, COALESCE(CategoryID, 0) from menu_items
+	where 1 = 1
 	`
-	rows, err := repo.db.Query(queryMenuItems)
+	args := []interface{}{}
+	argIndex := 1
+
+	if filter.CategoryID != 0 {
+		queryMenuItems += fmt.Sprintf(`
+	and CategoryID in (
+		with recursive tree as (
+			select ID from categories where ID = $%d
+			union all
+			select c.ID from categories c join tree t on c.ParentID = t.ID
+		)
+		select ID from tree
+	)`, argIndex)
+		args = append(args, filter.CategoryID)
+		argIndex++
+	}
+	queryMenuItems += " order by ID"
+
+	rows, err := repo.db.Query(queryMenuItems, args...)
 	if err != nil {
 		return []models.MenuItem{}, err
 	}
+	defer rows.Close()
 	var MenuItems []models.MenuItem
 	for rows.Next() {
 		var MenuItem models.MenuItem
-		rows.Scan(&MenuItem.ID, &MenuItem.Name, &MenuItem.Description, &MenuItem.Price)
+		rows.Scan(&MenuItem.ID, &MenuItem.Name, &MenuItem.Description, &MenuItem.Price, &MenuItem.CategoryID)
 		var MenuItemIngredients []models.MenuItemIngredient
 		queryMenuItemIngredients := `
 	        select IngredientID, Quantity from menu_item_ingredients where MenuID = $1
@@ -51,11 +85,539 @@ func (repo *MenuRepository) GetAll() ([]models.MenuItem, error) {
 			MenuItemIngredients = append(MenuItemIngredients, MenuItemIngredient)
 		}
 		MenuItem.Ingredients = MenuItemIngredients
+		MenuItem.Variants, err = getVariants(repo.db, `where MenuID = $1`, MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		MenuItem.ModifierGroups, err = getModifierGroups(repo.db, MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		MenuItem.BundleSlots, err = getBundleSlots(repo.db, MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
 		MenuItems = append(MenuItems, MenuItem)
 	}
+
+	if filter.At != nil {
+		adjuster, err := loadPriceAdjuster(repo.db, repo.location)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		for i := range MenuItems {
+			adjuster.applyPricingRule(&MenuItems[i], *filter.At)
+		}
+	}
+
+	if err := repo.fillAvailability(MenuItems); err != nil {
+		return []models.MenuItem{}, err
+	}
+	if err := repo.fillCosts(MenuItems); err != nil {
+		return []models.MenuItem{}, err
+	}
+	if err := repo.fillDietary(MenuItems); err != nil {
+		return []models.MenuItem{}, err
+	}
+	if err := repo.fillNutrition(MenuItems); err != nil {
+		return []models.MenuItem{}, err
+	}
+
+	if len(filter.ExcludeAllergens) > 0 {
+		filtered := []models.MenuItem{}
+		for _, item := range MenuItems {
+			if !item.ContainsAny(filter.ExcludeAllergens) {
+				filtered = append(filtered, item)
+			}
+		}
+		MenuItems = filtered
+	}
 	return MenuItems, nil
 }
 
+// queryMenuServings counts how many servings of each menu item the inventory allows
+const queryMenuServings = `
+	select mii.MenuID, MIN(FLOOR(i.Quantity::numeric / mii.Quantity))::int
+	from menu_item_ingredients mii
+	join inventory i on i.IngredientID = mii.IngredientID
+	`
+
+// variantRecipe joins the recipe of variant v: its own ingredients
+// or the base recipe scaled by the variant multiplier
+const variantRecipe = `
+	join lateral (
+		select vi.IngredientID, vi.Quantity::numeric as Quantity
+		from menu_item_variant_ingredients vi
+		where vi.VariantID = v.ID
+		union all
+		select mii.IngredientID, ROUND(mii.Quantity * v.RecipeMultiplier)
+		from menu_item_ingredients mii
+		where mii.MenuID = v.MenuID
+			and not exists (select 1 from menu_item_variant_ingredients vi where vi.VariantID = v.ID)
+	) r on r.Quantity > 0
+	join inventory i on i.IngredientID = r.IngredientID
+	`
+
+// queryVariantServings does the same for variants
+const queryVariantServings = `
+	select v.ID, MIN(FLOOR(i.Quantity::numeric / r.Quantity))::int
+	from menu_item_variants v
+	` + variantRecipe + `
+	group by v.ID
+	`
+
+// queryMenuCosts and queryVariantCosts sum the recipe at current ingredient unit costs
+const queryMenuCosts = `
+	select mii.MenuID, SUM(mii.Quantity * i.UnitCost)::float8
+	from menu_item_ingredients mii
+	join inventory i on i.IngredientID = mii.IngredientID
+	group by mii.MenuID
+	`
+
+const queryVariantCosts = `
+	select v.ID, SUM(r.Quantity * i.UnitCost)::float8
+	from menu_item_variants v
+	` + variantRecipe + `
+	group by v.ID
+	`
+
+// fillAvailability sets the available servings and sold out flags of the items and their variants.
+// An item with variants is sold out only when none of its variants can be made, a bundle when one of its slots can not be filled.
+func (repo *MenuRepository) fillAvailability(items []models.MenuItem) error {
+	menuServings, err := scanServings(repo.db, queryMenuServings+" group by mii.MenuID")
+	if err != nil {
+		return err
+	}
+	variantServings, err := scanServings(repo.db, queryVariantServings)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		if servings, ok := menuServings[items[i].ID]; ok {
+			items[i].SetServings(servings)
+		}
+		if servings, ok := bundleServings(items[i].BundleSlots, menuServings); ok {
+			items[i].SetServings(servings)
+		}
+		allVariantsSoldOut := len(items[i].Variants) > 0
+		for j := range items[i].Variants {
+			variant := &items[i].Variants[j]
+			if servings, ok := variantServings[variant.ID]; ok {
+				variant.SetServings(servings)
+			}
+			allVariantsSoldOut = allVariantsSoldOut && variant.SoldOut
+		}
+		if len(items[i].Variants) > 0 {
+			items[i].SoldOut = items[i].SoldOut && allVariantsSoldOut
+		}
+	}
+	return nil
+}
+
+// fillCosts sets the food cost and margin of the items and their variants
+func (repo *MenuRepository) fillCosts(items []models.MenuItem) error {
+	menuCosts, err := scanCosts(repo.db, queryMenuCosts)
+	if err != nil {
+		return err
+	}
+	variantCosts, err := scanCosts(repo.db, queryVariantCosts)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		cost := menuCosts[items[i].ID]
+		if len(items[i].BundleSlots) > 0 {
+			cost = bundleCost(items[i].BundleSlots, menuCosts)
+		}
+		items[i].SetCost(items[i].Price, cost)
+		for j := range items[i].Variants {
+			variant := &items[i].Variants[j]
+			variant.SetCost(variant.Price, variantCosts[variant.ID])
+		}
+	}
+	return nil
+}
+
+// bundleServings counts the bundles the inventory allows, every slot filled with the option most servings
+// can be made of. ok is false when no slot is limited by stock.
+func bundleServings(slots []models.BundleSlot, servings map[int]int) (count int, ok bool) {
+	for _, slot := range slots {
+		best, limited := 0, true
+		for _, option := range slot.Options {
+			optionServings, found := servings[option.ProductID]
+			if !found {
+				limited = false
+				break
+			}
+			if optionServings/slot.Quantity > best {
+				best = optionServings / slot.Quantity
+			}
+		}
+		if !limited {
+			continue
+		}
+		if !ok || best < count {
+			count = best
+		}
+		ok = true
+	}
+	return count, ok
+}
+
+// bundleCost is the food cost of a bundle with the priciest option in every slot,
+// so the margin shown is the lowest one the bundle can make
+func bundleCost(slots []models.BundleSlot, costs map[int]float64) float64 {
+	var total float64
+	for _, slot := range slots {
+		var highest float64
+		for _, option := range slot.Options {
+			highest = math.Max(highest, costs[option.ProductID])
+		}
+		total += highest * float64(slot.Quantity)
+	}
+	return total
+}
+
+func scanCosts(q querier, query string, args ...any) (map[int]float64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var cost float64
+		if err := rows.Scan(&id, &cost); err != nil {
+			return nil, err
+		}
+		costs[id] = cost
+	}
+	return costs, rows.Err()
+}
+
+// fillDietary sets the allergens and dietary tags of the items derived from their ingredients,
+// and the allergens modifiers add
+func (repo *MenuRepository) fillDietary(items []models.MenuItem) error {
+	ingredientTags, err := getIngredientDietary(repo.db)
+	if err != nil {
+		return err
+	}
+	menuTags, err := getMenuDietary(repo.db, ingredientTags)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		info, ok := menuTags[items[i].ID]
+		if !ok {
+			info = models.CombineDietary(nil)
+		}
+		items[i].DietaryInfo = info
+		for j := range items[i].ModifierGroups {
+			for k := range items[i].ModifierGroups[j].Modifiers {
+				modifier := &items[i].ModifierGroups[j].Modifiers[k]
+				var added []models.DietaryInfo
+				for _, ingredient := range modifier.Ingredients {
+					if ingredient.Quantity > 0 {
+						added = append(added, ingredientTags[ingredient.IngredientID])
+					}
+				}
+				if allergens := models.CombineDietary(added).Allergens; len(allergens) > 0 {
+					modifier.Allergens = allergens
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getIngredientDietary returns the allergens and dietary tags of every ingredient. A prepared
+// ingredient also carries the tags of the components of its nested recipes.
+func getIngredientDietary(q querier) (map[int]models.DietaryInfo, error) {
+	query := `
+	with recursive sources as (
+		select IngredientID, IngredientID as SourceID from inventory
+		union
+		select s.IngredientID, c.IngredientID
+		from sources s
+		join prepared_ingredient_components c on c.PreparedID = s.SourceID
+	)
+	select s.IngredientID, i.Allergens, i.Dietary
+	from sources s
+	join inventory i on i.IngredientID = s.SourceID
+	order by s.IngredientID
+	`
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make(map[int][]models.DietaryInfo)
+	for rows.Next() {
+		var id int
+		var source models.DietaryInfo
+		if err := rows.Scan(&id, pq.Array(&source.Allergens), pq.Array(&source.Dietary)); err != nil {
+			return nil, err
+		}
+		sources[id] = append(sources[id], source)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[int]models.DietaryInfo, len(sources))
+	for id, list := range sources {
+		tags[id] = models.CombineDietary(list)
+	}
+	return tags, nil
+}
+
+// getMenuDietary combines the ingredient tags per menu item over its base recipe and
+// the own recipes of its variants, for a bundle over the base recipes of all its slot options
+func getMenuDietary(q querier, ingredientTags map[int]models.DietaryInfo) (map[int]models.DietaryInfo, error) {
+	query := `
+	select MenuID, IngredientID from menu_item_ingredients
+	union
+	select v.MenuID, vi.IngredientID
+	from menu_item_variant_ingredients vi
+	join menu_item_variants v on v.ID = vi.VariantID
+	union
+	select s.BundleID, mii.IngredientID
+	from bundle_slots s
+	join bundle_slot_options o on o.SlotID = s.ID
+	join menu_item_ingredients mii on mii.MenuID = o.MenuItemID
+	`
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := make(map[int][]models.DietaryInfo)
+	for rows.Next() {
+		var menuID, ingredientID int
+		if err := rows.Scan(&menuID, &ingredientID); err != nil {
+			return nil, err
+		}
+		ingredients[menuID] = append(ingredients[menuID], ingredientTags[ingredientID])
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[int]models.DietaryInfo, len(ingredients))
+	for menuID, list := range ingredients {
+		tags[menuID] = models.CombineDietary(list)
+	}
+	return tags, nil
+}
+
+// fillNutrition sets the per serving nutrition of the items and their variants,
+// and how much each modifier adds to or removes from a serving
+func (repo *MenuRepository) fillNutrition(items []models.MenuItem) error {
+	perUnit, err := getIngredientNutrition(repo.db)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		items[i].Nutrition = recipeNutrition(items[i].Ingredients, perUnit)
+		for j := range items[i].Variants {
+			variant := &items[i].Variants[j]
+			recipe := variant.Ingredients
+			if len(recipe) == 0 {
+				// Same scaling as variantRecipe does
+				for _, ingredient := range items[i].Ingredients {
+					ingredient.Quantity = math.Round(ingredient.Quantity * variant.RecipeMultiplier)
+					recipe = append(recipe, ingredient)
+				}
+			}
+			variant.Nutrition = recipeNutrition(recipe, perUnit)
+		}
+		for j := range items[i].ModifierGroups {
+			for k := range items[i].ModifierGroups[j].Modifiers {
+				// The delta of the base recipe, like the ingredient deltas it scales with a variant's RecipeMultiplier
+				modifier := &items[i].ModifierGroups[j].Modifiers[k]
+				modifier.Nutrition = recipeNutrition(modifier.Ingredients, perUnit)
+			}
+		}
+	}
+	return nil
+}
+
+func recipeNutrition(recipe []models.MenuItemIngredient, perUnit map[int]models.Nutrition) models.Nutrition {
+	var total models.Nutrition
+	for _, ingredient := range recipe {
+		total = total.Add(perUnit[ingredient.IngredientID].Scale(ingredient.Quantity))
+	}
+	return total.Rounded()
+}
+
+// getIngredientNutrition returns the nutrition per stock unit of every ingredient. A prepared
+// ingredient gets the nutrition of its recipe per unit of yield instead of its own values.
+func getIngredientNutrition(q querier) (map[int]models.Nutrition, error) {
+	rows, err := q.Query(`select IngredientID, Calories, Protein, Fat, Carbohydrates, Sugar from inventory`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	own := make(map[int]models.Nutrition)
+	for rows.Next() {
+		var id int
+		var n models.Nutrition
+		if err := rows.Scan(&id, &n.Calories, &n.Protein, &n.Fat, &n.Carbohydrates, &n.Sugar); err != nil {
+			return nil, err
+		}
+		own[id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list, err := getRecipes(q, ``)
+	if err != nil {
+		return nil, err
+	}
+	recipes := make(map[int]models.PreparedRecipe, len(list))
+	for _, recipe := range list {
+		recipes[recipe.IngredientID] = recipe
+	}
+
+	perUnit := make(map[int]models.Nutrition, len(own))
+	for id := range own {
+		perUnit[id] = rolledUpNutrition(id, recipes, own, map[int]bool{})
+	}
+	return perUnit, nil
+}
+
+// rolledUpNutrition is the nutrition of one stock unit of the ingredient made from its recipe,
+// or its own nutrition when it is raw. path guards against cycles.
+func rolledUpNutrition(ingredientID int, recipes map[int]models.PreparedRecipe, own map[int]models.Nutrition, path map[int]bool) models.Nutrition {
+	recipe, ok := recipes[ingredientID]
+	if !ok || path[ingredientID] {
+		return own[ingredientID]
+	}
+	path[ingredientID] = true
+	defer delete(path, ingredientID)
+
+	var total models.Nutrition
+	for _, component := range recipe.Components {
+		total = total.Add(rolledUpNutrition(component.IngredientID, recipes, own, path).Scale(component.Quantity))
+	}
+	return total.Scale(1 / recipe.Yield)
+}
+
+// GetIngredientNutrition returns the base recipe of the menu item with the nutrition each ingredient brings
+func (repo *MenuRepository) GetIngredientNutrition(menuItemID int) ([]models.IngredientNutrition, error) {
+	perUnit, err := getIngredientNutrition(repo.db)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	select i.IngredientID, i.Name, mii.Quantity, i.Unit
+	from menu_item_ingredients mii
+	join inventory i on i.IngredientID = mii.IngredientID
+	where mii.MenuID = $1
+	order by i.IngredientID
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := []models.IngredientNutrition{}
+	for rows.Next() {
+		var ingredient models.IngredientNutrition
+		if err := rows.Scan(&ingredient.IngredientID, &ingredient.Name, &ingredient.Quantity, &ingredient.Unit); err != nil {
+			return nil, err
+		}
+		ingredient.Nutrition = perUnit[ingredient.IngredientID].Scale(ingredient.Quantity).Rounded()
+		ingredients = append(ingredients, ingredient)
+	}
+	return ingredients, rows.Err()
+}
+
+// GetIngredientCosts returns the base recipe of the menu item priced at current ingredient unit costs
+func (repo *MenuRepository) GetIngredientCosts(menuItemID int) ([]models.IngredientCost, error) {
+	query := `
+	select i.IngredientID, i.Name, mii.Quantity, i.Unit, i.UnitCost
+	from menu_item_ingredients mii
+	join inventory i on i.IngredientID = mii.IngredientID
+	where mii.MenuID = $1
+	order by i.IngredientID
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := []models.IngredientCost{}
+	for rows.Next() {
+		var cost models.IngredientCost
+		if err := rows.Scan(&cost.IngredientID, &cost.Name, &cost.Quantity, &cost.Unit, &cost.UnitCost); err != nil {
+			return nil, err
+		}
+		cost.Cost = math.Round(cost.Quantity*cost.UnitCost*10000) / 10000
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
+
+// GetAvailableServings returns how many servings of the base menu item or bundles can be made,
+// nil when the item has no recipe
+func (repo *MenuRepository) GetAvailableServings(menuItemID int) (*int, error) {
+	slots, err := getBundleSlots(repo.db, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(slots) > 0 {
+		servings, err := scanServings(repo.db, queryMenuServings+" group by mii.MenuID")
+		if err != nil {
+			return nil, err
+		}
+		if count, ok := bundleServings(slots, servings); ok {
+			return &count, nil
+		}
+		return nil, nil
+	}
+
+	servings, err := scanServings(repo.db, queryMenuServings+" where mii.MenuID = $1 group by mii.MenuID", menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := servings[menuItemID]; ok {
+		return &value, nil
+	}
+	return nil, nil
+}
+
+func scanServings(q querier, query string, args ...any) (map[int]int, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	servings := make(map[int]int)
+	for rows.Next() {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		servings[id] = count
+	}
+	return servings, rows.Err()
+}
+
 func (repo *MenuRepository) Exists(itemID int) bool {
 	items, _ := repo.GetAll()
 	for _, item := range items {
@@ -78,48 +640,72 @@ func (repo *MenuRepository) DeleteMenuItemRepo(MenuItemID int) error {
 	return nil
 }
 
+// UpdateMenuItemRepo updates the menu item in one transaction. Variants, modifier groups, modifiers and
+// bundle slots keep their IDs, so past orders and clients holding the IDs still match them.
+// A collection left out of the request is kept as it is.
 func (repo *MenuRepository) UpdateMenuItemRepo(menuItem models.MenuItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	queryUpdateMenu := `
 	update menu_items
-	set Name = $1, Description = $2, Price = $3
-	where ID = $4
+	set Name = $1, Description = $2, Price = $3, CategoryID = $4
+	where ID = $5
 	`
-	_, err := repo.db.Exec(queryUpdateMenu, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.ID)
+	_, err = tx.Exec(queryUpdateMenu, menuItem.Name, menuItem.Description, menuItem.Price, nullableID(menuItem.CategoryID), menuItem.ID)
 	if err != nil {
 		return err
 	}
-	queryUpdateMenuIngredients1 := `
-			delete from menu_item_ingredients 
-			where MenuID = $1
+	if menuItem.Ingredients != nil {
+		if _, err = tx.Exec(`delete from menu_item_ingredients where MenuID = $1`, menuItem.ID); err != nil {
+			return err
+		}
+		queryAddIngredient := `
+		insert into menu_item_ingredients (MenuID, IngredientID, Quantity) values
+		($1, $2, $3)
 		`
-	// Execute the update query
-	_, err = repo.db.Exec(queryUpdateMenuIngredients1, menuItem.ID)
-	if err != nil {
-		return err
+		for _, v := range menuItem.Ingredients {
+			if _, err = tx.Exec(queryAddIngredient, menuItem.ID, v.IngredientID, v.Quantity); err != nil {
+				return err
+			}
+		}
 	}
-	for _, v := range menuItem.Ingredients {
-
-		queryUpdateMenuIngredients2 := `
-			insert into menu_item_ingredients (MenuID, IngredientID, Quantity) values
-			($1, $2, $3)
-		`
-		_, err = repo.db.Exec(queryUpdateMenuIngredients2, menuItem.ID, v.IngredientID, v.Quantity)
-		if err != nil {
+	if menuItem.Variants != nil {
+		if err = saveVariants(tx, menuItem.ID, menuItem.Variants); err != nil {
 			return err
 		}
 	}
-	return nil
+	if menuItem.ModifierGroups != nil {
+		if err = saveModifierGroups(tx, menuItem.ID, menuItem.ModifierGroups); err != nil {
+			return err
+		}
+	}
+	if menuItem.BundleSlots != nil {
+		if err = saveBundleSlots(tx, menuItem.ID, menuItem.BundleSlots); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (repo *MenuRepository) AddMenuItemRepo(menuItem models.MenuItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	queryAddItem := `
-	Insert into menu_items (Name, Description, Price) values
-    ($1, $2, $3)
+	Insert into menu_items (Name, Description, Price, CategoryID) values
+    ($1, $2, $3, $4)
 	RETURNING id
 	`
 	var menuID int
 
-	err := repo.db.QueryRow(queryAddItem, menuItem.Name, menuItem.Description, menuItem.Price).Scan(&menuID)
+	err = tx.QueryRow(queryAddItem, menuItem.Name, menuItem.Description, menuItem.Price, nullableID(menuItem.CategoryID)).Scan(&menuID)
 	if err != nil {
 		return err
 	}
@@ -128,12 +714,21 @@ func (repo *MenuRepository) AddMenuItemRepo(menuItem models.MenuItem) error {
 		insert into menu_item_ingredients (MenuID, IngredientID, Quantity) values
 		($1, $2, $3)
 	    `
-		_, err = repo.db.Exec(queryAddItemIngredients, menuID, v.IngredientID, v.Quantity)
+		_, err = tx.Exec(queryAddItemIngredients, menuID, v.IngredientID, v.Quantity)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	if err = saveVariants(tx, menuID, menuItem.Variants); err != nil {
+		return err
+	}
+	if err = saveModifierGroups(tx, menuID, menuItem.ModifierGroups); err != nil {
+		return err
+	}
+	if err = saveBundleSlots(tx, menuID, menuItem.BundleSlots); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (repo *MenuRepository) MenuCheckByIDRepo(ID int) bool {
@@ -146,3 +741,394 @@ func (repo *MenuRepository) MenuCheckByIDRepo(ID int) bool {
 	}
 	return rows.Next()
 }
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// getVariants loads variants matching the where clause together with their own recipes
+func getVariants(q querier, where string, args ...any) ([]models.MenuItemVariant, error) {
+	rows, err := q.Query(`select ID, Name, Price, RecipeMultiplier from menu_item_variants `+where+` order by Price, ID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.MenuItemVariant
+	for rows.Next() {
+		var variant models.MenuItemVariant
+		if err := rows.Scan(&variant.ID, &variant.Name, &variant.Price, &variant.RecipeMultiplier); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryIngredients := `
+	select IngredientID, Quantity from menu_item_variant_ingredients where VariantID = $1
+	`
+	for i := range variants {
+		ingredientRows, err := q.Query(queryIngredients, variants[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for ingredientRows.Next() {
+			var ingredient models.MenuItemIngredient
+			if err := ingredientRows.Scan(&ingredient.IngredientID, &ingredient.Quantity); err != nil {
+				ingredientRows.Close()
+				return nil, err
+			}
+			variants[i].Ingredients = append(variants[i].Ingredients, ingredient)
+		}
+		ingredientRows.Close()
+	}
+	return variants, nil
+}
+
+// saveVariants makes the given variants the variants of the menu item: the ones with an ID are updated,
+// the ones without are added and the variants not given are removed
+func saveVariants(q querier, menuID int, variants []models.MenuItemVariant) error {
+	queryAddVariant := `
+	insert into menu_item_variants (MenuID, Name, Price, RecipeMultiplier) values
+	($1, $2, $3, $4)
+	returning ID
+	`
+	queryUpdateVariant := `
+	update menu_item_variants
+	set Name = $1, Price = $2, RecipeMultiplier = $3
+	where ID = $4 and MenuID = $5
+	`
+	queryAddVariantIngredient := `
+	insert into menu_item_variant_ingredients (VariantID, IngredientID, Quantity) values
+	($1, $2, $3)
+	`
+
+	kept := []int64{}
+	for _, variant := range variants {
+		if variant.ID != 0 {
+			kept = append(kept, int64(variant.ID))
+		}
+	}
+	_, err := q.Exec(`delete from menu_item_variants where MenuID = $1 and not (ID = any($2))`, menuID, pq.Int64Array(kept))
+	if err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		variantID := variant.ID
+		if variantID == 0 {
+			err = q.QueryRow(queryAddVariant, menuID, variant.Name, variant.Price, variant.RecipeMultiplier).Scan(&variantID)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = updateMenuChild(q, "variant", variantID, menuID, queryUpdateVariant, variant.Name, variant.Price, variant.RecipeMultiplier, variantID, menuID)
+			if err != nil {
+				return err
+			}
+			if _, err = q.Exec(`delete from menu_item_variant_ingredients where VariantID = $1`, variantID); err != nil {
+				return err
+			}
+		}
+		for _, ingredient := range variant.Ingredients {
+			_, err = q.Exec(queryAddVariantIngredient, variantID, ingredient.IngredientID, ingredient.Quantity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateMenuChild runs the update of a variant, modifier group, modifier or bundle slot,
+// failing when no row of the menu item has the given ID
+func updateMenuChild(q querier, kind string, id, menuID int, query string, args ...any) error {
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w: %s %d does not belong to menu item %d", models.ErrInvalidMenuItem, kind, id, menuID)
+	}
+	return nil
+}
+
+func getModifierGroups(q querier, menuID int) ([]models.ModifierGroup, error) {
+	queryGroups := `
+	select ID, Name, MinSelect, MaxSelect from modifier_groups where MenuID = $1 order by ID
+	`
+	rows, err := q.Query(queryGroups, menuID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.ModifierGroup
+	for rows.Next() {
+		var group models.ModifierGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.MinSelect, &group.MaxSelect); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		groups[i].Modifiers, err = getModifiers(q, `where GroupID = $1`, groups[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// getModifiers loads modifiers matching the where clause together with their ingredient deltas
+func getModifiers(q querier, where string, args ...any) ([]models.Modifier, error) {
+	rows, err := q.Query(`select ID, GroupID, Name, PriceDelta from modifiers `+where+` order by ID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	modifiers := []models.Modifier{}
+	for rows.Next() {
+		var modifier models.Modifier
+		if err := rows.Scan(&modifier.ID, &modifier.GroupID, &modifier.Name, &modifier.PriceDelta); err != nil {
+			return nil, err
+		}
+		modifiers = append(modifiers, modifier)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryIngredients := `
+	select IngredientID, QuantityDelta from modifier_ingredients where ModifierID = $1
+	`
+	for i := range modifiers {
+		ingredientRows, err := q.Query(queryIngredients, modifiers[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		modifiers[i].Ingredients = []models.MenuItemIngredient{}
+		for ingredientRows.Next() {
+			var ingredient models.MenuItemIngredient
+			if err := ingredientRows.Scan(&ingredient.IngredientID, &ingredient.Quantity); err != nil {
+				ingredientRows.Close()
+				return nil, err
+			}
+			modifiers[i].Ingredients = append(modifiers[i].Ingredients, ingredient)
+		}
+		ingredientRows.Close()
+	}
+	return modifiers, nil
+}
+
+// saveModifierGroups makes the given groups the modifier groups of the menu item the way saveVariants does,
+// for the groups and for their modifiers. A modifier may move to another group of the item.
+func saveModifierGroups(q querier, menuID int, groups []models.ModifierGroup) error {
+	queryAddGroup := `
+	insert into modifier_groups (MenuID, Name, MinSelect, MaxSelect) values
+	($1, $2, $3, $4)
+	returning ID
+	`
+	queryUpdateGroup := `
+	update modifier_groups
+	set Name = $1, MinSelect = $2, MaxSelect = $3
+	where ID = $4 and MenuID = $5
+	`
+	queryAddModifier := `
+	insert into modifiers (GroupID, Name, PriceDelta) values
+	($1, $2, $3)
+	returning ID
+	`
+	queryUpdateModifier := `
+	update modifiers
+	set GroupID = $1, Name = $2, PriceDelta = $3
+	where ID = $4 and GroupID in (select ID from modifier_groups where MenuID = $5)
+	`
+	queryAddModifierIngredient := `
+	insert into modifier_ingredients (ModifierID, IngredientID, QuantityDelta) values
+	($1, $2, $3)
+	`
+
+	keptModifiers := []int64{}
+	for _, group := range groups {
+		for _, modifier := range group.Modifiers {
+			if modifier.ID != 0 {
+				keptModifiers = append(keptModifiers, int64(modifier.ID))
+			}
+		}
+	}
+	queryDeleteModifiers := `
+	delete from modifiers
+	where GroupID in (select ID from modifier_groups where MenuID = $1) and not (ID = any($2))
+	`
+	if _, err := q.Exec(queryDeleteModifiers, menuID, pq.Int64Array(keptModifiers)); err != nil {
+		return err
+	}
+
+	keptGroups := []int64{}
+	for _, group := range groups {
+		groupID := group.ID
+		if groupID == 0 {
+			err := q.QueryRow(queryAddGroup, menuID, group.Name, group.MinSelect, group.MaxSelect).Scan(&groupID)
+			if err != nil {
+				return err
+			}
+		} else {
+			err := updateMenuChild(q, "modifier group", groupID, menuID, queryUpdateGroup, group.Name, group.MinSelect, group.MaxSelect, groupID, menuID)
+			if err != nil {
+				return err
+			}
+		}
+		keptGroups = append(keptGroups, int64(groupID))
+
+		for _, modifier := range group.Modifiers {
+			modifierID := modifier.ID
+			if modifierID == 0 {
+				err := q.QueryRow(queryAddModifier, groupID, modifier.Name, modifier.PriceDelta).Scan(&modifierID)
+				if err != nil {
+					return err
+				}
+			} else {
+				err := updateMenuChild(q, "modifier", modifierID, menuID, queryUpdateModifier, groupID, modifier.Name, modifier.PriceDelta, modifierID, menuID)
+				if err != nil {
+					return err
+				}
+				if _, err = q.Exec(`delete from modifier_ingredients where ModifierID = $1`, modifierID); err != nil {
+					return err
+				}
+			}
+			for _, ingredient := range modifier.Ingredients {
+				_, err := q.Exec(queryAddModifierIngredient, modifierID, ingredient.IngredientID, ingredient.Quantity)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Groups go last so the modifiers moved out of a removed group are not removed with it
+	_, err := q.Exec(`delete from modifier_groups where MenuID = $1 and not (ID = any($2))`, menuID, pq.Int64Array(keptGroups))
+	return err
+}
+
+func (repo *MenuRepository) GetBundleSlots(menuItemID int) ([]models.BundleSlot, error) {
+	return getBundleSlots(repo.db, menuItemID)
+}
+
+// getBundleSlots loads the slots of a bundle with their options, nil for items that are not bundles
+func getBundleSlots(q querier, bundleID int) ([]models.BundleSlot, error) {
+	querySlots := `
+	select ID, Name, Quantity from bundle_slots where BundleID = $1 order by ID
+	`
+	rows, err := q.Query(querySlots, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []models.BundleSlot
+	for rows.Next() {
+		var slot models.BundleSlot
+		if err := rows.Scan(&slot.ID, &slot.Name, &slot.Quantity); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryOptions := `
+	select o.MenuItemID, mi.Name, o.PriceDelta::float8
+	from bundle_slot_options o
+	join menu_items mi on mi.ID = o.MenuItemID
+	where o.SlotID = $1
+	order by o.PriceDelta, o.MenuItemID
+	`
+	for i := range slots {
+		optionRows, err := q.Query(queryOptions, slots[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		slots[i].Options = []models.BundleOption{}
+		for optionRows.Next() {
+			var option models.BundleOption
+			if err := optionRows.Scan(&option.ProductID, &option.Name, &option.PriceDelta); err != nil {
+				optionRows.Close()
+				return nil, err
+			}
+			slots[i].Options = append(slots[i].Options, option)
+		}
+		optionRows.Close()
+	}
+	return slots, nil
+}
+
+// saveBundleSlots makes the given slots the slots of the bundle the way saveVariants does.
+// The options of a slot are replaced.
+func saveBundleSlots(q querier, bundleID int, slots []models.BundleSlot) error {
+	queryAddSlot := `
+	insert into bundle_slots (BundleID, Name, Quantity) values
+	($1, $2, $3)
+	returning ID
+	`
+	queryUpdateSlot := `
+	update bundle_slots
+	set Name = $1, Quantity = $2
+	where ID = $3 and BundleID = $4
+	`
+	queryAddOption := `
+	insert into bundle_slot_options (SlotID, MenuItemID, PriceDelta) values
+	($1, $2, $3)
+	`
+
+	kept := []int64{}
+	for _, slot := range slots {
+		if slot.ID != 0 {
+			kept = append(kept, int64(slot.ID))
+		}
+	}
+	_, err := q.Exec(`delete from bundle_slots where BundleID = $1 and not (ID = any($2))`, bundleID, pq.Int64Array(kept))
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range slots {
+		slotID := slot.ID
+		if slotID == 0 {
+			err = q.QueryRow(queryAddSlot, bundleID, slot.Name, slot.Quantity).Scan(&slotID)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = updateMenuChild(q, "bundle slot", slotID, bundleID, queryUpdateSlot, slot.Name, slot.Quantity, slotID, bundleID)
+			if err != nil {
+				return err
+			}
+			if _, err = q.Exec(`delete from bundle_slot_options where SlotID = $1`, slotID); err != nil {
+				return err
+			}
+		}
+		for _, option := range slot.Options {
+			_, err = q.Exec(queryAddOption, slotID, option.ProductID, option.PriceDelta)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
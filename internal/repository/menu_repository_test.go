@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+func TestBundleServings(t *testing.T) {
+	slot := func(quantity int, productIDs ...int) models.BundleSlot {
+		s := models.BundleSlot{Quantity: quantity}
+		for _, id := range productIDs {
+			s.Options = append(s.Options, models.BundleOption{ProductID: id})
+		}
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		slots     []models.BundleSlot
+		servings  map[int]int
+		wantCount int
+		wantOK    bool
+	}{
+		{
+			name:     "no slots",
+			servings: map[int]int{1: 5},
+		},
+		{
+			name:      "fixed component",
+			slots:     []models.BundleSlot{slot(1, 1)},
+			servings:  map[int]int{1: 10},
+			wantCount: 10,
+			wantOK:    true,
+		},
+		{
+			name:      "slot quantity divides the servings",
+			slots:     []models.BundleSlot{slot(2, 1)},
+			servings:  map[int]int{1: 9},
+			wantCount: 4,
+			wantOK:    true,
+		},
+		{
+			name:      "slot takes the option most servings can be made of",
+			slots:     []models.BundleSlot{slot(1, 1, 2)},
+			servings:  map[int]int{1: 3, 2: 7},
+			wantCount: 7,
+			wantOK:    true,
+		},
+		{
+			name:      "scarcest slot limits the bundle",
+			slots:     []models.BundleSlot{slot(1, 1, 2), slot(1, 3)},
+			servings:  map[int]int{1: 3, 2: 7, 3: 5},
+			wantCount: 5,
+			wantOK:    true,
+		},
+		{
+			name:      "slot with an option not limited by stock",
+			slots:     []models.BundleSlot{slot(1, 1, 2), slot(1, 3)},
+			servings:  map[int]int{1: 3, 3: 5},
+			wantCount: 5,
+			wantOK:    true,
+		},
+		{
+			name:     "no slot limited by stock",
+			slots:    []models.BundleSlot{slot(1, 1), slot(2, 2)},
+			servings: map[int]int{},
+		},
+		{
+			name:      "sold out component",
+			slots:     []models.BundleSlot{slot(1, 1), slot(1, 2)},
+			servings:  map[int]int{1: 0, 2: 8},
+			wantCount: 0,
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, ok := bundleServings(tt.slots, tt.servings)
+			if count != tt.wantCount || ok != tt.wantOK {
+				t.Errorf("bundleServings() = %d, %v, want %d, %v", count, ok, tt.wantCount, tt.wantOK)
+			}
+		})
+	}
+}
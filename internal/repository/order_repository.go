@@ -3,7 +3,11 @@ package repository
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,17 +21,21 @@ type OrderRepositoryInterface interface {
 	SaveUpdatedOrder(updatedOrder models.Order, OrderID string) error
 	DeleteOrder(OrderID int) error
 	CloseOrderRepo(id int) error
+	TransitionOrder(id int, toStatus, actor string) (models.OrderStatusChange, error)
+	GetStatusHistory(id int) ([]models.OrderStatusChange, error)
 	GetNumberOfItems(startDate, endDate time.Time) (map[string]int, error)
 	OrderedItemsByDay(month, year int) (map[string]interface{}, error)
 	OrderedItemsByMonth(year int) (map[string]interface{}, error)
 }
 
 type OrderRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	location *time.Location
 }
 
-func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+// NewOrderRepository creates an order repository. Pricing rules are matched in the cafe time zone location.
+func NewOrderRepository(db *sql.DB, location *time.Location) *OrderRepository {
+	return &OrderRepository{db: db, location: location}
 }
 
 func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error) {
@@ -40,12 +48,7 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 		processInfo.Reason = "internal server error. Failed to start transaction."
 		return processInfo, []models.BatchOrderInventoryUpdate{}, err
 	}
-
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	defer tx.Rollback()
 
 	// Inserting order and getting ID
 	queryOrder := `
@@ -68,96 +71,211 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 	}
 	processInfo.OrderID = ID
 
-	// Inserting order items. in case when same product id is given, it check on conflict, if so it's just adding quantity for previus row.
+	if err = setInventoryReason(tx, models.InventoryReasonSale, models.OrderReference(ID)); err != nil {
+		processInfo.Reason = "internal server error. Failed to set inventory reason."
+		return processInfo, []models.BatchOrderInventoryUpdate{}, err
+	}
+
+	placement, reason, err := repo.placeOrderItems(tx, ID, order.CustomerName, order.Items, order.PromoCodes, nil, time.Now())
+	if err != nil {
+		processInfo.Reason = reason
+		return processInfo, []models.BatchOrderInventoryUpdate{}, err
+	}
+
+	// Commiting transaction
+	err = tx.Commit()
+	if err != nil {
+		processInfo.Reason = "Internal server error. Error commiting transaction."
+		return processInfo, placement.Inventory, err
+	}
+	processInfo.Total = placement.Total
+	processInfo.Discounts = placement.Discounts
+	processInfo.DiscountTotal = placement.DiscountTotal
+	processInfo.Status = models.StatusOrderAccepted
+	processInfo.Reason = "OK"
+	return processInfo, placement.Inventory, nil
+}
+
+// orderPlacement is what the items of an order came to once placed
+type orderPlacement struct {
+	Total         float64
+	Discounts     []models.OrderDiscount
+	DiscountTotal float64
+	Inventory     []models.BatchOrderInventoryUpdate
+}
+
+// placeOrderItems prices the items as of pricedAt, saves them with their modifiers and bundle components,
+// takes their ingredients from inventory, applies the promo codes and saves the order total.
+// Discounts kept from an earlier placement of the order are recomputed for the items instead of the codes.
+// On failure the returned reason describes what went wrong for the batch report.
+func (repo *OrderRepository) placeOrderItems(tx *sql.Tx, orderID int, customerName string, items []models.OrderItem, codes []string, kept []models.OrderDiscount, pricedAt time.Time) (orderPlacement, string, error) {
+	// Inserting order items with the price snapshot. Every requested line is a separate row,
+	// so the same product with different modifiers is not merged.
 	queryOrderItems := `
-		INSERT INTO order_items (ProductID, Quantity, OrderID) VALUES
-		($1, $2, $3)
-		ON CONFLICT (OrderID, ProductID)
-		DO UPDATE SET Quantity = order_items.Quantity + EXCLUDED.Quantity;
+		INSERT INTO order_items (OrderID, ProductID, Quantity, UnitPrice, LineTotal, VariantID, VariantName) VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ID
 	`
 
-	// Getting price of menu item
+	// Getting price and category of menu item
 	queryGetPrice := `
-		SELECT price FROM menu_items WHERE id = $1
+		SELECT price, COALESCE(CategoryID, 0) FROM menu_items WHERE id = $1
 	`
 
-	// Reducing ingredients from inventory
-	queryGetIngredients := `
-		SELECT IngredientID, Quantity FROM menu_item_ingredients WHERE MenuID = $1
-	`
+	// Pricing rules active at the time of the order adjust the item and variant prices
+	adjuster, err := loadPriceAdjuster(tx, repo.location)
+	if err != nil {
+		return orderPlacement{}, "internal server error. Failed to load pricing rules.", err
+	}
 
+	// Reducing ingredients from inventory
 	queryUpdateInventory := `
 		UPDATE inventory SET Quantity = Quantity - $1 WHERE IngredientID = $2 AND Quantity >= $1
 	`
-	// InventoryUpdatesInfo
-	inventoryInfo := []models.BatchOrderInventoryUpdate{}
-	for _, v := range order.Items {
 
-		_, err = tx.Exec(queryOrderItems, v.ProductID, v.Quantity, ID)
+	// Remembering what was consumed by the order item and what it cost at the time of sale
+	queryOrderItemIngredients := `
+		INSERT INTO order_item_ingredients (OrderItemID, IngredientID, Quantity, UnitCost) VALUES
+		($1, $2, $3, $4)
+	`
+
+	// Remembering the lots the order item was sold from, so a cancellation can give them back
+	queryOrderItemLots := `
+		INSERT INTO order_item_lots (OrderItemID, LotID, Quantity) VALUES
+		($1, $2, $3)
+	`
+
+	// Resolving every line against the menu first, so the inventory rows of the whole order
+	// can be locked at once before anything is taken from stock
+	lines := make([]orderLine, 0, len(items))
+	ingredientIDs := []int{}
+	for _, v := range items {
+		line := orderLine{item: v}
+
+		var price float64
+		err = tx.QueryRow(queryGetPrice, v.ProductID).Scan(&price, &line.categoryID)
+		if err != nil {
+			return orderPlacement{}, "internal server error." + err.Error(), err
+		}
+
+		line.variant, err = getOrderItemVariant(tx, v.ProductID, v.VariantID)
+		if err != nil {
+			return orderPlacement{}, err.Error(), err
+		}
+		if line.variant != nil {
+			price = line.variant.Price
+		}
+		if rule := adjuster.rule(line.categoryID, pricedAt); rule != nil {
+			price = rule.Apply(price)
+		}
+
+		line.modifiers, err = getOrderItemModifiers(tx, v.ProductID, v.Modifiers)
 		if err != nil {
-			processInfo.Reason = "internal server error. " + err.Error()
-			processInfo.Total = 0
-			return processInfo, []models.BatchOrderInventoryUpdate{}, err
+			return orderPlacement{}, err.Error(), err
 		}
 
-		var price float64
-		err = tx.QueryRow(queryGetPrice, v.ProductID).Scan(&price)
+		line.components, err = getOrderItemComponents(tx, v.ProductID, v.BundleChoices)
 		if err != nil {
-			processInfo.Reason = "internal server error." + err.Error()
-			processInfo.Total = 0
-			return processInfo, []models.BatchOrderInventoryUpdate{}, err
+			return orderPlacement{}, err.Error(), err
 		}
-		processInfo.Total += float64(v.Quantity) * price
 
-		var ingredients []struct {
-			IngredientID     int
-			RequiredQuantity int
+		line.unitPrice = price
+		for _, modifier := range line.modifiers {
+			line.unitPrice += modifier.PriceDelta
+		}
+		for _, component := range line.components {
+			line.unitPrice += component.PriceDelta
 		}
 
-		rows, err := tx.Query(queryGetIngredients, v.ProductID)
+		line.ingredients, err = getOrderItemRecipe(tx, v.ProductID, line.variant, line.modifiers)
+		if err == nil && len(line.components) > 0 {
+			line.ingredients, err = addComponentRecipes(tx, line.ingredients, line.components)
+		}
 		if err != nil {
-			processInfo.Reason = "internal server error. Failed to get ingredients."
-			processInfo.Total = 0
-			return processInfo, []models.BatchOrderInventoryUpdate{}, err
+			return orderPlacement{}, "internal server error. Failed to get ingredients.", err
 		}
-		defer rows.Close()
+		for _, ing := range line.ingredients {
+			ingredientIDs = append(ingredientIDs, ing.IngredientID)
+		}
+		lines = append(lines, line)
+	}
 
-		for rows.Next() {
-			var ingredient struct {
-				IngredientID     int
-				RequiredQuantity int
-			}
-			if err := rows.Scan(&ingredient.IngredientID, &ingredient.RequiredQuantity); err != nil {
-				processInfo.Reason = "internal server error. Failed to scan ingredient."
-				processInfo.Total = 0
-				return processInfo, []models.BatchOrderInventoryUpdate{}, err
-			}
-			ingredients = append(ingredients, ingredient)
+	// Locking the inventory rows in ingredient order, as every other stock change does
+	if err = lockInventoryRows(tx, ingredientIDs); err != nil {
+		return orderPlacement{}, "internal server error. Failed to lock inventory.", err
+	}
+
+	placement := orderPlacement{Inventory: []models.BatchOrderInventoryUpdate{}}
+	promoLines := []models.PromoLine{}
+	var subtotal float64
+	for _, line := range lines {
+		v := line.item
+		lineTotal := float64(v.Quantity) * line.unitPrice
+
+		var variantID, variantName any
+		if line.variant != nil {
+			variantID, variantName = line.variant.ID, line.variant.Name
 		}
-		for _, ing := range ingredients {
-			totalRequired := ing.RequiredQuantity * v.Quantity
+
+		var orderItemID int
+		err = tx.QueryRow(queryOrderItems, orderID, v.ProductID, v.Quantity, line.unitPrice, lineTotal, variantID, variantName).Scan(&orderItemID)
+		if err != nil {
+			return orderPlacement{}, "internal server error. " + err.Error(), err
+		}
+		subtotal += lineTotal
+		promoLines = append(promoLines, models.PromoLine{
+			ProductID:  v.ProductID,
+			CategoryID: line.categoryID,
+			Quantity:   v.Quantity,
+			UnitPrice:  line.unitPrice,
+		})
+
+		if err = saveOrderItemModifiers(tx, orderItemID, line.modifiers); err != nil {
+			return orderPlacement{}, "internal server error. Failed to save modifiers.", err
+		}
+		if err = saveOrderItemComponents(tx, orderItemID, line.components); err != nil {
+			return orderPlacement{}, "internal server error. Failed to save bundle components.", err
+		}
+
+		for _, ing := range line.ingredients {
+			totalRequired := ing.Quantity * v.Quantity
 
 			var availableQuantity int
 			var InvName string
+			var unitCost float64
 
-			err = tx.QueryRow("SELECT quantity, name FROM inventory WHERE IngredientID = $1", ing.IngredientID).Scan(&availableQuantity, &InvName)
+			err = tx.QueryRow("SELECT quantity, name, UnitCost::float8 FROM inventory WHERE IngredientID = $1", ing.IngredientID).Scan(&availableQuantity, &InvName, &unitCost)
 			if err != nil {
-				processInfo.Reason = fmt.Sprintf("internal server error. Failed to check inventory. ID=%d", ing.IngredientID)
-				processInfo.Total = 0
-				return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				return orderPlacement{}, fmt.Sprintf("internal server error. Failed to check inventory. ID=%d", ing.IngredientID), err
 			}
 
 			if availableQuantity < totalRequired {
-				processInfo.Reason = fmt.Sprintf("insufficient_inventory. IngredientID: %d. Required: %d, Available: %d", ing.IngredientID, totalRequired, availableQuantity)
-				processInfo.Total = 0
-				return processInfo, []models.BatchOrderInventoryUpdate{}, fmt.Errorf(processInfo.Reason)
+				err = fmt.Errorf("%w: ingredient %d, required %d, available %d", models.ErrInsufficientInventory, ing.IngredientID, totalRequired, availableQuantity)
+				return orderPlacement{}, "insufficient_inventory. " + err.Error(), err
 			}
 
 			_, err = tx.Exec(queryUpdateInventory, totalRequired, ing.IngredientID)
 			if err != nil {
-				processInfo.Reason = "internal server error. Failed to update inventory."
-				processInfo.Total = 0
-				return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				return orderPlacement{}, "internal server error. Failed to update inventory.", err
+			}
+
+			// Taking the sold quantity from the lots, earliest expiry first
+			usages, err := consumeLots(tx, ing.IngredientID, float64(totalRequired))
+			if err != nil {
+				if errors.Is(err, models.ErrInsufficientInventory) {
+					return orderPlacement{}, "insufficient_inventory. " + err.Error(), err
+				}
+				return orderPlacement{}, "internal server error. Failed to update inventory lots.", err
+			}
+			for _, usage := range usages {
+				if _, err = tx.Exec(queryOrderItemLots, orderItemID, usage.LotID, usage.Quantity); err != nil {
+					return orderPlacement{}, "internal server error. Failed to save consumed lots.", err
+				}
+			}
+
+			_, err = tx.Exec(queryOrderItemIngredients, orderItemID, ing.IngredientID, totalRequired, unitCost)
+			if err != nil {
+				return orderPlacement{}, "internal server error. Failed to save consumed ingredients.", err
 			}
 
 			InvInfo := models.BatchOrderInventoryUpdate{
@@ -166,25 +284,259 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 				Quantity_used: totalRequired,
 				Remaining:     availableQuantity - totalRequired,
 			}
-			inventoryInfo = append(inventoryInfo, InvInfo)
+			placement.Inventory = append(placement.Inventory, InvInfo)
 		}
 	}
 
-	// Commiting transaction
-	err = tx.Commit()
+	// Promo codes discount the order, every applied code is saved as a discount line
+	var discounts []models.OrderDiscount
+	if kept != nil {
+		discounts, err = keepPromoDiscounts(tx, orderID, kept, promoLines)
+	} else {
+		discounts, err = applyPromoCodes(tx, orderID, customerName, codes, promoLines, pricedAt)
+	}
 	if err != nil {
-		processInfo.Reason = "Internal server error. Error commiting transaction."
-		processInfo.Total = 0
-		return processInfo, inventoryInfo, err
+		if errors.Is(err, models.ErrPromoCodeNotFound) || errors.Is(err, models.ErrPromoCodeNotApplicable) {
+			return orderPlacement{}, err.Error(), err
+		}
+		return orderPlacement{}, "internal server error. Failed to apply promo codes.", err
 	}
-	processInfo.Status = models.StatusOrderAccepted
-	processInfo.Reason = "OK"
-	return processInfo, inventoryInfo, nil
+	for _, discount := range discounts {
+		placement.DiscountTotal += discount.Amount
+	}
+	placement.Discounts = discounts
+	placement.DiscountTotal = math.Round(placement.DiscountTotal*100) / 100
+	placement.Total = math.Round((subtotal-placement.DiscountTotal)*100) / 100
+
+	// Saving order total
+	_, err = tx.Exec(`UPDATE orders SET Total = $1, DiscountTotal = $2 WHERE ID = $3`, placement.Total, placement.DiscountTotal, orderID)
+	if err != nil {
+		return orderPlacement{}, "internal server error. Failed to save order total.", err
+	}
+	return placement, "", nil
+}
+
+// orderLine is an order item resolved against the menu, priced and with the ingredients one unit takes
+type orderLine struct {
+	item        models.OrderItem
+	categoryID  int
+	variant     *models.MenuItemVariant
+	modifiers   []models.Modifier
+	components  []models.OrderItemComponent
+	unitPrice   float64
+	ingredients []ingredientAmount
+}
+
+// ingredientAmount is a quantity of a single inventory ingredient
+type ingredientAmount struct {
+	IngredientID int
+	Quantity     int
+}
+
+// getOrderItemVariant loads the chosen variant of the product. Returns nil when no variant is chosen.
+func getOrderItemVariant(q querier, productID, variantID int) (*models.MenuItemVariant, error) {
+	if variantID == 0 {
+		return nil, nil
+	}
+	variants, err := getVariants(q, `where ID = $1 and MenuID = $2`, variantID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%w: variant %d is not available for product %d", models.ErrInvalidVariant, variantID, productID)
+	}
+	return &variants[0], nil
+}
+
+// getOrderItemModifiers loads the chosen modifiers and checks them against the modifier groups of the product
+func getOrderItemModifiers(q querier, productID int, modifierIDs []int) ([]models.Modifier, error) {
+	groups, err := getModifierGroups(q, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[int]models.Modifier)
+	for _, group := range groups {
+		for _, modifier := range group.Modifiers {
+			available[modifier.ID] = modifier
+		}
+	}
+
+	selected := []models.Modifier{}
+	perGroup := make(map[int]int)
+	for _, id := range modifierIDs {
+		modifier, ok := available[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: modifier %d is not available for product %d", models.ErrInvalidModifiers, id, productID)
+		}
+		delete(available, id)
+		perGroup[modifier.GroupID]++
+		selected = append(selected, modifier)
+	}
+
+	for _, group := range groups {
+		if count := perGroup[group.ID]; count < group.MinSelect || count > group.MaxSelect {
+			return nil, fmt.Errorf("%w: choose from %d to %d options of '%s' for product %d", models.ErrInvalidModifiers, group.MinSelect, group.MaxSelect, group.Name, productID)
+		}
+	}
+	return selected, nil
+}
+
+func saveOrderItemModifiers(tx *sql.Tx, orderItemID int, modifiers []models.Modifier) error {
+	query := `
+		INSERT INTO order_item_modifiers (OrderItemID, ModifierID, Name, PriceDelta) VALUES
+		($1, $2, $3, $4)
+	`
+	for _, modifier := range modifiers {
+		if _, err := tx.Exec(query, orderItemID, modifier.ID, modifier.Name, modifier.PriceDelta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOrderItemComponents resolves the components of a bundle line: the only option of a fixed slot
+// or the option chosen for the slot. Returns an empty slice for products that are not bundles.
+func getOrderItemComponents(q querier, productID int, choices []models.BundleChoice) ([]models.OrderItemComponent, error) {
+	slots, err := getBundleSlots(q, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := make(map[int]int)
+	for _, choice := range choices {
+		if _, ok := chosen[choice.SlotID]; ok {
+			return nil, fmt.Errorf("%w: slot %d of product %d is chosen twice", models.ErrInvalidBundle, choice.SlotID, productID)
+		}
+		chosen[choice.SlotID] = choice.ProductID
+	}
+
+	components := []models.OrderItemComponent{}
+	for _, slot := range slots {
+		choiceID, ok := chosen[slot.ID]
+		delete(chosen, slot.ID)
+		if !ok {
+			if len(slot.Options) != 1 {
+				return nil, fmt.Errorf("%w: choose an option of '%s' for product %d", models.ErrInvalidBundle, slot.Name, productID)
+			}
+			choiceID = slot.Options[0].ProductID
+		}
+
+		var option *models.BundleOption
+		for i := range slot.Options {
+			if slot.Options[i].ProductID == choiceID {
+				option = &slot.Options[i]
+			}
+		}
+		if option == nil {
+			return nil, fmt.Errorf("%w: product %d is not an option of '%s'", models.ErrInvalidBundle, choiceID, slot.Name)
+		}
+		components = append(components, models.OrderItemComponent{
+			Slot:       slot.Name,
+			ProductID:  option.ProductID,
+			Name:       option.Name,
+			Quantity:   slot.Quantity,
+			PriceDelta: option.PriceDelta,
+		})
+	}
+	for _, choice := range choices {
+		if _, ok := chosen[choice.SlotID]; ok {
+			return nil, fmt.Errorf("%w: product %d has no bundle slot %d", models.ErrInvalidBundle, productID, choice.SlotID)
+		}
+	}
+	return components, nil
+}
+
+func saveOrderItemComponents(tx *sql.Tx, orderItemID int, components []models.OrderItemComponent) error {
+	query := `
+		INSERT INTO order_item_components (OrderItemID, SlotName, ProductID, Quantity, PriceDelta) VALUES
+		($1, $2, $3, $4, $5)
+	`
+	for _, component := range components {
+		if _, err := tx.Exec(query, orderItemID, component.Slot, component.ProductID, component.Quantity, component.PriceDelta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addComponentRecipes adds the base recipes of the bundle components to the ingredients of one bundle
+func addComponentRecipes(q querier, ingredients []ingredientAmount, components []models.OrderItemComponent) ([]ingredientAmount, error) {
+	amounts := make(map[int]int)
+	for _, ingredient := range ingredients {
+		amounts[ingredient.IngredientID] += ingredient.Quantity
+	}
+	for _, component := range components {
+		recipe, err := getOrderItemRecipe(q, component.ProductID, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, ingredient := range recipe {
+			amounts[ingredient.IngredientID] += ingredient.Quantity * component.Quantity
+		}
+	}
+	return sortedAmounts(amounts), nil
+}
+
+// getOrderItemRecipe returns the ingredients needed for one unit of the product in the given variant
+// with the given modifiers, ordered by ingredient ID.
+func getOrderItemRecipe(q querier, productID int, variant *models.MenuItemVariant, modifiers []models.Modifier) ([]ingredientAmount, error) {
+	amounts := make(map[int]int)
+	// Modifier deltas are given for the base recipe and scale with it
+	multiplier := 1.0
+	if variant != nil && len(variant.Ingredients) > 0 {
+		for _, ingredient := range variant.Ingredients {
+			amounts[ingredient.IngredientID] += int(ingredient.Quantity)
+		}
+	} else {
+		if variant != nil {
+			multiplier = variant.RecipeMultiplier
+		}
+
+		rows, err := q.Query(`SELECT IngredientID, Quantity FROM menu_item_ingredients WHERE MenuID = $1`, productID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var ingredientID, quantity int
+			if err := rows.Scan(&ingredientID, &quantity); err != nil {
+				return nil, err
+			}
+			amounts[ingredientID] += int(math.Round(float64(quantity) * multiplier))
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, modifier := range modifiers {
+		for _, ingredient := range modifier.Ingredients {
+			amounts[ingredient.IngredientID] += int(math.Round(ingredient.Quantity * multiplier))
+		}
+	}
+
+	return sortedAmounts(amounts), nil
+}
+
+// sortedAmounts turns an ingredient -> quantity map into a slice ordered by ingredient ID, skipping empty amounts
+func sortedAmounts(amounts map[int]int) []ingredientAmount {
+	result := []ingredientAmount{}
+	for ingredientID, quantity := range amounts {
+		if quantity > 0 {
+			result = append(result, ingredientAmount{IngredientID: ingredientID, Quantity: quantity})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IngredientID < result[j].IngredientID
+	})
+	return result
 }
 
 func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 	query := `
-	 SELECT ID, CustomerName, Status, Notes, CreatedAt
+	 SELECT ID, CustomerName, Status, Notes, DiscountTotal, Total, CreatedAt
 	 FROM orders`
 
 	rows, err := repo.db.Query(query)
@@ -198,7 +550,7 @@ func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 	for rows.Next() {
 		var order models.Order
 		var notes []byte
-		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.CreatedAt); err != nil {
+		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.DiscountTotal, &order.Total, &order.CreatedAt); err != nil {
 			return nil, err
 		}
 
@@ -210,6 +562,9 @@ func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 			return nil, err
 		}
 		order.Items = items
+		if order.Discounts, err = getOrderDiscounts(repo.db, order.ID); err != nil {
+			return nil, err
+		}
 
 		orders = append(orders, order)
 	}
@@ -219,12 +574,12 @@ func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 
 func (repo *OrderRepository) GetOrderByID(id int) (models.Order, error) {
 	query := `
-		SELECT ID, CustomerName, Status, Notes, CreatedAt
+		SELECT ID, CustomerName, Status, Notes, DiscountTotal, Total, CreatedAt
 		FROM orders WHERE ID = $1`
 
 	var order models.Order
 	var notes []byte
-	err := repo.db.QueryRow(query, id).Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.CreatedAt)
+	err := repo.db.QueryRow(query, id).Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.DiscountTotal, &order.Total, &order.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Order{}, models.ErrOrderNotFound
@@ -244,45 +599,74 @@ func (repo *OrderRepository) GetOrderByID(id int) (models.Order, error) {
 		return models.Order{}, err
 	}
 	order.Items = items
+	if order.Discounts, err = getOrderDiscounts(repo.db, id); err != nil {
+		return models.Order{}, err
+	}
 	return order, nil
 }
 
+// SaveUpdatedOrder replaces the items of an open order. The old items give their ingredients back
+// and the new ones are placed the same way as on creation, priced as of when the order was created.
+// Promo codes given with the update replace the ones applied to the order, otherwise the discounts
+// of the order are kept and recomputed for the new items.
 func (repo *OrderRepository) SaveUpdatedOrder(updatedOrder models.Order, OrderID string) error {
-	queryCheckStatus := `
-	select Status from orders where ID = $1
-	`
-	var Status string
-	// Use QueryRow instead of Query for single-row results
-	err := repo.db.QueryRow(queryCheckStatus, OrderID).Scan(&Status)
+	id, err := strconv.Atoi(OrderID)
+	if err != nil {
+		return models.ErrOrderNotFound
+	}
+
+	tx, err := repo.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrOrderNotFound
-		}
 		return err
 	}
+	defer tx.Rollback()
 
-	if Status == "closed" {
+	status, err := lockOrderStatus(tx, id)
+	if err != nil {
+		return err
+	}
+	if models.IsFinalOrderStatus(status) {
 		return models.ErrOrderClosed
 	}
+
+	var createdAt time.Time
 	queryUpdateOrder := `
-	update orders 
+	update orders
 	set CustomerName = $1
 	where ID = $2
+	returning CreatedAt::timestamptz
 	`
-	_, err = repo.db.Query(queryUpdateOrder, updatedOrder.CustomerName, OrderID)
-	if err != nil {
+	if err = tx.QueryRow(queryUpdateOrder, updatedOrder.CustomerName, id).Scan(&createdAt); err != nil {
 		return err
 	}
-	for _, v := range updatedOrder.Items {
-		queryUpdateOrderItems := `
-		update order_items set ProductID = $1, Quantity = $2 where OrderID = $3
-		`
-		_, err = repo.db.Query(queryUpdateOrderItems, v.ProductID, v.Quantity, OrderID)
+
+	var kept []models.OrderDiscount
+	if updatedOrder.PromoCodes == nil {
+		discounts, err := getOrderDiscounts(tx, id)
 		if err != nil {
 			return err
 		}
+		kept = append([]models.OrderDiscount{}, discounts...)
 	}
-	return nil
+
+	if err = restoreOrderInventory(tx, id); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`delete from order_items where OrderID = $1`, id); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`delete from order_discounts where OrderID = $1`, id); err != nil {
+		return err
+	}
+
+	if err = setInventoryReason(tx, models.InventoryReasonSale, models.OrderReference(id)); err != nil {
+		return err
+	}
+	if _, _, err = repo.placeOrderItems(tx, id, updatedOrder.CustomerName, updatedOrder.Items, updatedOrder.PromoCodes, kept, createdAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (repo *OrderRepository) DeleteOrder(OrderID int) error {
@@ -353,41 +737,209 @@ func (repo *OrderRepository) DeleteOrder(OrderID int) error {
 	return nil
 }
 
+// CloseOrderRepo hands an order over to the customer right away. The order walks every
+// intermediate lifecycle step, each one checked and recorded. Used by batch processing.
 func (repo *OrderRepository) CloseOrderRepo(id int) error {
-	// Check current order status
-	var status string
-	queryCheckStatus := `
-		SELECT status FROM orders WHERE ID = $1
-	`
-	err := repo.db.QueryRow(queryCheckStatus, id).Scan(&status)
+	tx, err := repo.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrOrderNotFound
-		}
 		return err
 	}
+	defer tx.Rollback()
 
-	if status == "closed" {
-		return models.ErrOrderClosed
+	status, err := lockOrderStatus(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if models.IsFinalOrderStatus(status) {
+		return fmt.Errorf("%w: %s -> %s", models.ErrIllegalTransition, status, models.OrderStatusPickedUp)
+	}
+
+	for status != models.OrderStatusPickedUp {
+		next := models.NextOrderStatus(status)
+		if !models.CanTransitionOrder(status, next) {
+			return fmt.Errorf("%w: %s -> %s", models.ErrIllegalTransition, status, next)
+		}
+		if _, err = changeOrderStatus(tx, id, status, next, "system"); err != nil {
+			return err
+		}
+		status = next
+	}
+
+	return tx.Commit()
+}
+
+// TransitionOrder moves an order to toStatus if the lifecycle allows it and records the step.
+func (repo *OrderRepository) TransitionOrder(id int, toStatus, actor string) (models.OrderStatusChange, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return models.OrderStatusChange{}, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrderStatus(tx, id)
+	if err != nil {
+		return models.OrderStatusChange{}, err
 	}
 
-	// Update order status to "closed"
-	queryToClose := `
-		UPDATE orders SET status = 'closed' WHERE ID = $1
+	if !models.CanTransitionOrder(status, toStatus) {
+		return models.OrderStatusChange{}, fmt.Errorf("%w: %s -> %s", models.ErrIllegalTransition, status, toStatus)
+	}
+
+	if toStatus == models.OrderStatusCancelled {
+		if err = restoreOrderInventory(tx, id); err != nil {
+			return models.OrderStatusChange{}, err
+		}
+	}
+
+	change, err := changeOrderStatus(tx, id, status, toStatus, actor)
+	if err != nil {
+		return models.OrderStatusChange{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.OrderStatusChange{}, err
+	}
+	return change, nil
+}
+
+func (repo *OrderRepository) GetStatusHistory(id int) ([]models.OrderStatusChange, error) {
+	var exists bool
+	err := repo.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM orders WHERE ID = $1)`, id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrOrderNotFound
+	}
+
+	query := `
+		SELECT ID, OrderID, FromStatus, ToStatus, Actor, ChangedAt
+		FROM order_status_history
+		WHERE OrderID = $1
+		ORDER BY ChangedAt, ID
 	`
-	_, err = repo.db.Exec(queryToClose, id)
+	rows, err := repo.db.Query(query, id)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed request for order_status_history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+	for rows.Next() {
+		var change models.OrderStatusChange
+		var fromStatus sql.NullString
+		if err := rows.Scan(&change.ID, &change.OrderID, &fromStatus, &change.ToStatus, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("error scanning row in order_status_history: %w", err)
+		}
+		change.FromStatus = fromStatus.String
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+// restoreOrderInventory gives back the ingredients recorded as consumed by the order's items,
+// the quantities taken from lots go back to the same lots.
+func restoreOrderInventory(tx *sql.Tx, orderID int) error {
+	if err := setInventoryReason(tx, models.InventoryReasonCancellation, models.OrderReference(orderID)); err != nil {
+		return fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	// Locking the inventory rows in ingredient order like placing an order does
+	queryLock := `
+		SELECT i.IngredientID FROM inventory i
+		WHERE i.IngredientID IN (
+			SELECT oii.IngredientID
+			FROM order_items oi
+			JOIN order_item_ingredients oii ON oii.OrderItemID = oi.ID
+			WHERE oi.OrderID = $1
+		)
+		ORDER BY i.IngredientID
+		FOR UPDATE
+	`
+	if _, err := tx.Exec(queryLock, orderID); err != nil {
+		return fmt.Errorf("failed to lock inventory: %w", err)
 	}
 
+	queryRestore := `
+		UPDATE inventory i
+		SET Quantity = i.Quantity + used.total
+		FROM (
+			SELECT oii.IngredientID, SUM(oii.Quantity) AS total
+			FROM order_items oi
+			JOIN order_item_ingredients oii ON oii.OrderItemID = oi.ID
+			WHERE oi.OrderID = $1
+			GROUP BY oii.IngredientID
+		) used
+		WHERE i.IngredientID = used.IngredientID
+	`
+	if _, err := tx.Exec(queryRestore, orderID); err != nil {
+		return fmt.Errorf("failed to restore inventory: %w", err)
+	}
+
+	queryRestoreLots := `
+		UPDATE inventory_lots l
+		SET Quantity = l.Quantity + used.total
+		FROM (
+			SELECT oil.LotID, SUM(oil.Quantity) AS total
+			FROM order_items oi
+			JOIN order_item_lots oil ON oil.OrderItemID = oi.ID
+			WHERE oi.OrderID = $1
+			GROUP BY oil.LotID
+		) used
+		WHERE l.ID = used.LotID
+	`
+	if _, err := tx.Exec(queryRestoreLots, orderID); err != nil {
+		return fmt.Errorf("failed to restore inventory lots: %w", err)
+	}
 	return nil
 }
 
+// lockOrderStatus returns the current order status and locks the order row until the transaction ends.
+func lockOrderStatus(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT Status FROM orders WHERE ID = $1 FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", models.ErrOrderNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// changeOrderStatus updates the order status and writes the step to order_status_history.
+func changeOrderStatus(tx *sql.Tx, id int, fromStatus, toStatus, actor string) (models.OrderStatusChange, error) {
+	_, err := tx.Exec(`UPDATE orders SET Status = $1 WHERE ID = $2`, toStatus, id)
+	if err != nil {
+		return models.OrderStatusChange{}, err
+	}
+
+	change := models.OrderStatusChange{
+		OrderID:    id,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		Actor:      actor,
+	}
+	queryHistory := `
+		INSERT INTO order_status_history (OrderID, FromStatus, ToStatus, Actor)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ID, ChangedAt
+	`
+	err = tx.QueryRow(queryHistory, id, fromStatus, toStatus, actor).Scan(&change.ID, &change.ChangedAt)
+	if err != nil {
+		return models.OrderStatusChange{}, fmt.Errorf("failed to record status history: %w", err)
+	}
+	return change, nil
+}
+
 func getOrderItems(db *sql.DB, orderID int) ([]models.OrderItem, error) {
 	query := `
-	 SELECT ProductID, Quantity
+	 SELECT ID, ProductID, Quantity, UnitPrice, LineTotal, COALESCE(VariantID, 0), COALESCE(VariantName, '')
 	 FROM order_items
-	 WHERE OrderID = $1`
+	 WHERE OrderID = $1
+	 ORDER BY ID`
 
 	rows, err := db.Query(query, orderID)
 	if err != nil {
@@ -396,13 +948,65 @@ func getOrderItems(db *sql.DB, orderID int) ([]models.OrderItem, error) {
 	defer rows.Close()
 
 	var items []models.OrderItem
+	var itemIDs []int
 
 	for rows.Next() {
 		var item models.OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+		var itemID int
+		if err := rows.Scan(&itemID, &item.ProductID, &item.Quantity, &item.UnitPrice, &item.LineTotal, &item.VariantID, &item.Variant); err != nil {
 			return nil, fmt.Errorf("error scanning row in order_items: %w", err)
 		}
 		items = append(items, item)
+		itemIDs = append(itemIDs, itemID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryModifiers := `
+	 SELECT COALESCE(ModifierID, 0), Name, PriceDelta
+	 FROM order_item_modifiers
+	 WHERE OrderItemID = $1
+	 ORDER BY ID`
+	for i, itemID := range itemIDs {
+		modifierRows, err := db.Query(queryModifiers, itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed request for order_item_modifiers: %w", err)
+		}
+		for modifierRows.Next() {
+			var modifier models.OrderItemModifier
+			if err := modifierRows.Scan(&modifier.ModifierID, &modifier.Name, &modifier.PriceDelta); err != nil {
+				modifierRows.Close()
+				return nil, fmt.Errorf("error scanning row in order_item_modifiers: %w", err)
+			}
+			items[i].AppliedModifiers = append(items[i].AppliedModifiers, modifier)
+			if modifier.ModifierID != 0 {
+				items[i].Modifiers = append(items[i].Modifiers, modifier.ModifierID)
+			}
+		}
+		modifierRows.Close()
+	}
+
+	queryComponents := `
+	 SELECT c.SlotName, c.ProductID, mi.Name, c.Quantity, c.PriceDelta
+	 FROM order_item_components c
+	 JOIN menu_items mi ON mi.ID = c.ProductID
+	 WHERE c.OrderItemID = $1
+	 ORDER BY c.ID`
+	for i, itemID := range itemIDs {
+		componentRows, err := db.Query(queryComponents, itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed request for order_item_components: %w", err)
+		}
+		for componentRows.Next() {
+			var component models.OrderItemComponent
+			if err := componentRows.Scan(&component.Slot, &component.ProductID, &component.Name, &component.Quantity, &component.PriceDelta); err != nil {
+				componentRows.Close()
+				return nil, fmt.Errorf("error scanning row in order_item_components: %w", err)
+			}
+			items[i].Components = append(items[i].Components, component)
+		}
+		componentRows.Close()
 	}
 
 	return items, nil
@@ -421,7 +1025,7 @@ func (repo *OrderRepository) GetNumberOfItems(startDate, endDate time.Time) (map
 		LEFT JOIN
 			orders o ON oi.OrderID = o.ID
 		WHERE
-			(o.CreatedAt BETWEEN $1 AND $2) AND o.Status = 'closed'
+			(o.CreatedAt BETWEEN $1 AND $2) AND o.Status = 'picked_up'
 		GROUP BY
 			m.Name
 		ORDER BY
@@ -524,7 +1128,7 @@ func (repo *OrderRepository) OrderedItemsByMonth(year int) (map[string]interface
 			orders o
 		WHERE 
 			EXTRACT(YEAR FROM o.createdat) = $1
-			AND o.status = 'closed'
+			AND o.status = 'picked_up'
 		GROUP BY 
 			TO_CHAR(o.createdat, 'Month'), EXTRACT(MONTH FROM o.createdat)
 		ORDER BY 
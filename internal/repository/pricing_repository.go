@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type PricingRepositoryInterface interface {
+	GetMenuItemPrice(menuItemID int) (string, float64, error)
+	GetPriceChanges(menuItemID int) ([]models.PriceChange, error)
+	GetScheduledPrices(menuItemID int) ([]models.ScheduledPrice, error)
+	AddScheduledPrice(price models.ScheduledPrice) (int, error)
+	GetScheduledPrice(id int) (models.ScheduledPrice, error)
+	DeleteScheduledPrice(id int) error
+	ApplyDuePrices() ([]models.ScheduledPrice, error)
+	GetRules() ([]models.PricingRule, error)
+	GetRule(id int) (models.PricingRule, error)
+	AddRule(rule models.PricingRule) (int, error)
+	UpdateRule(rule models.PricingRule) error
+	DeleteRule(id int) error
+}
+
+type PricingRepository struct {
+	db *sql.DB
+}
+
+func NewPricingRepository(db *sql.DB) *PricingRepository {
+	return &PricingRepository{db: db}
+}
+
+// GetMenuItemPrice returns the name and the current price of the menu item
+func (repo *PricingRepository) GetMenuItemPrice(menuItemID int) (string, float64, error) {
+	var name string
+	var price float64
+	err := repo.db.QueryRow(`select Name, Price::float8 from menu_items where ID = $1`, menuItemID).Scan(&name, &price)
+	if err == sql.ErrNoRows {
+		return "", 0, models.ErrMenuItemNotFound
+	}
+	return name, price, err
+}
+
+// GetPriceChanges returns the logged price changes of the menu item, newest first
+func (repo *PricingRepository) GetPriceChanges(menuItemID int) ([]models.PriceChange, error) {
+	query := `
+	select HistoryID, old_price::float8, new_price::float8, ChangedAt
+	from price_history
+	where Menu_ItemID = $1
+	order by ChangedAt desc, HistoryID desc
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.PriceChange{}
+	for rows.Next() {
+		var change models.PriceChange
+		if err := rows.Scan(&change.ID, &change.OldPrice, &change.NewPrice, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// GetScheduledPrices returns the pending scheduled prices of the menu item in the order they take effect.
+// menuItemID 0 means every menu item.
+func (repo *PricingRepository) GetScheduledPrices(menuItemID int) ([]models.ScheduledPrice, error) {
+	where := `where s.AppliedAt is null`
+	args := []any{}
+	if menuItemID != 0 {
+		where += ` and s.MenuItemID = $1`
+		args = append(args, menuItemID)
+	}
+	return getScheduledPrices(repo.db, where+` order by s.EffectiveAt, s.ID`, args...)
+}
+
+func getScheduledPrices(q querier, where string, args ...any) ([]models.ScheduledPrice, error) {
+	query := `
+	select s.ID, s.MenuItemID, m.Name, s.Price::float8, s.EffectiveAt, s.CreatedAt, s.AppliedAt
+	from scheduled_prices s
+	join menu_items m on m.ID = s.MenuItemID
+	` + where
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.ScheduledPrice{}
+	for rows.Next() {
+		var price models.ScheduledPrice
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&price.ID, &price.ProductID, &price.Name, &price.Price, &price.EffectiveAt, &price.CreatedAt, &appliedAt); err != nil {
+			return nil, err
+		}
+		if appliedAt.Valid {
+			price.AppliedAt = &appliedAt.Time
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+func (repo *PricingRepository) AddScheduledPrice(price models.ScheduledPrice) (int, error) {
+	var id int
+	query := `
+	insert into scheduled_prices (MenuItemID, Price, EffectiveAt) values ($1, $2, $3)
+	returning ID
+	`
+	err := repo.db.QueryRow(query, price.ProductID, price.Price, price.EffectiveAt).Scan(&id)
+	return id, err
+}
+
+func (repo *PricingRepository) GetScheduledPrice(id int) (models.ScheduledPrice, error) {
+	prices, err := getScheduledPrices(repo.db, `where s.ID = $1`, id)
+	if err != nil {
+		return models.ScheduledPrice{}, err
+	}
+	if len(prices) == 0 {
+		return models.ScheduledPrice{}, models.ErrScheduledPriceNotFound
+	}
+	return prices[0], nil
+}
+
+// DeleteScheduledPrice cancels a scheduled price that is not applied yet
+func (repo *PricingRepository) DeleteScheduledPrice(id int) error {
+	query := `
+	with deleted as (
+		delete from scheduled_prices where ID = $1 and AppliedAt is null
+		returning ID
+	)
+	select exists (select 1 from deleted), exists (select 1 from scheduled_prices where ID = $1)
+	`
+	// The second exists sees the row as it was before the delete
+	var deleted, exists bool
+	if err := repo.db.QueryRow(query, id).Scan(&deleted, &exists); err != nil {
+		return err
+	}
+	if deleted {
+		return nil
+	}
+	if exists {
+		return fmt.Errorf("%w: scheduled price %d", models.ErrScheduledPriceApplied, id)
+	}
+	return models.ErrScheduledPriceNotFound
+}
+
+// ApplyDuePrices sets the prices whose time has come, in the order they take effect, and marks them applied
+// in one transaction. Rows taken by a concurrent run are skipped. The price_change_trigger logs every change.
+func (repo *PricingRepository) ApplyDuePrices() ([]models.ScheduledPrice, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	due, err := getScheduledPrices(tx, `
+	where s.AppliedAt is null and s.EffectiveAt <= now()
+	order by s.EffectiveAt, s.ID
+	for update of s skip locked
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, price := range due {
+		if _, err := tx.Exec(`update menu_items set Price = $1 where ID = $2`, price.Price, price.ProductID); err != nil {
+			return nil, fmt.Errorf("failed to update menu item price: %w", err)
+		}
+		var appliedAt time.Time
+		if err := tx.QueryRow(`update scheduled_prices set AppliedAt = now() where ID = $1 returning AppliedAt`, price.ID).Scan(&appliedAt); err != nil {
+			return nil, err
+		}
+		due[i].AppliedAt = &appliedAt
+	}
+	return due, tx.Commit()
+}
+
+// GetRules returns all pricing rules in the order they are tried
+func (repo *PricingRepository) GetRules() ([]models.PricingRule, error) {
+	return getPricingRules(repo.db, ``)
+}
+
+func (repo *PricingRepository) GetRule(id int) (models.PricingRule, error) {
+	rules, err := getPricingRules(repo.db, `where ID = $1`, id)
+	if err != nil {
+		return models.PricingRule{}, err
+	}
+	if len(rules) == 0 {
+		return models.PricingRule{}, models.ErrPricingRuleNotFound
+	}
+	return rules[0], nil
+}
+
+func getPricingRules(q querier, where string, args ...any) ([]models.PricingRule, error) {
+	query := `
+	select ID, Name, COALESCE(CategoryID, 0), Weekdays, to_char(StartTime, 'HH24:MI'), to_char(EndTime, 'HH24:MI'),
+		AdjustmentType, Value::float8, Priority, Active
+	from pricing_rules
+	` + where + `
+	order by Priority desc, ID desc
+	`
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.PricingRule{}
+	for rows.Next() {
+		var rule models.PricingRule
+		var weekdays pq.Int64Array
+		err := rows.Scan(&rule.ID, &rule.Name, &rule.CategoryID, &weekdays, &rule.StartTime, &rule.EndTime,
+			&rule.AdjustmentType, &rule.Value, &rule.Priority, &rule.Active)
+		if err != nil {
+			return nil, err
+		}
+		for _, day := range weekdays {
+			rule.Weekdays = append(rule.Weekdays, int(day))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (repo *PricingRepository) AddRule(rule models.PricingRule) (int, error) {
+	var id int
+	query := `
+	insert into pricing_rules (Name, CategoryID, Weekdays, StartTime, EndTime, AdjustmentType, Value, Priority, Active) values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning ID
+	`
+	err := repo.db.QueryRow(query, rule.Name, nullableID(rule.CategoryID), pq.Array(weekdaysArray(rule.Weekdays)), rule.StartTime, rule.EndTime,
+		rule.AdjustmentType, rule.Value, rule.Priority, rule.Active).Scan(&id)
+	return id, err
+}
+
+func (repo *PricingRepository) UpdateRule(rule models.PricingRule) error {
+	query := `
+	update pricing_rules
+	set Name = $1, CategoryID = $2, Weekdays = $3, StartTime = $4, EndTime = $5, AdjustmentType = $6,
+		Value = $7, Priority = $8, Active = $9
+	where ID = $10
+	`
+	result, err := repo.db.Exec(query, rule.Name, nullableID(rule.CategoryID), pq.Array(weekdaysArray(rule.Weekdays)), rule.StartTime, rule.EndTime,
+		rule.AdjustmentType, rule.Value, rule.Priority, rule.Active, rule.ID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return models.ErrPricingRuleNotFound
+	}
+	return nil
+}
+
+func (repo *PricingRepository) DeleteRule(id int) error {
+	result, err := repo.db.Exec(`delete from pricing_rules where ID = $1`, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return models.ErrPricingRuleNotFound
+	}
+	return nil
+}
+
+// weekdaysArray keeps an empty weekday list from being stored as NULL
+func weekdaysArray(weekdays []int) []int64 {
+	array := []int64{}
+	for _, day := range weekdays {
+		array = append(array, int64(day))
+	}
+	return array
+}
+
+// priceAdjuster evaluates the pricing rules for menu items of a category
+type priceAdjuster struct {
+	rules    []models.PricingRule
+	parents  map[int]int
+	location *time.Location
+}
+
+// loadPriceAdjuster reads the active pricing rules and the category tree.
+// Rule time windows are matched in the cafe time zone location.
+func loadPriceAdjuster(q querier, location *time.Location) (priceAdjuster, error) {
+	rules, err := getPricingRules(q, `where Active`)
+	if err != nil {
+		return priceAdjuster{}, err
+	}
+	if len(rules) == 0 {
+		return priceAdjuster{}, nil
+	}
+	parents, err := getCategoryParents(q)
+	if err != nil {
+		return priceAdjuster{}, err
+	}
+	return priceAdjuster{rules: rules, parents: parents, location: location}, nil
+}
+
+// getCategoryParents returns the parent of every category, 0 for top level categories
+func getCategoryParents(q querier) (map[int]int, error) {
+	rows, err := q.Query(`select ID, COALESCE(ParentID, 0) from categories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := make(map[int]int)
+	for rows.Next() {
+		var id, parentID int
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		parents[id] = parentID
+	}
+	return parents, rows.Err()
+}
+
+// inCategory reports whether categoryID is the category ancestorID or one of its subcategories
+func inCategory(parents map[int]int, categoryID, ancestorID int) bool {
+	for depth := 0; categoryID != 0 && depth <= len(parents); depth++ {
+		if categoryID == ancestorID {
+			return true
+		}
+		categoryID = parents[categoryID]
+	}
+	return false
+}
+
+// rule returns the rule with the highest priority covering a menu item of the category at the given time, nil if none.
+func (a priceAdjuster) rule(categoryID int, at time.Time) *models.PricingRule {
+	for i, rule := range a.rules {
+		if rule.CategoryID != 0 && !inCategory(a.parents, categoryID, rule.CategoryID) {
+			continue
+		}
+		if rule.Covers(at, a.location) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+// applyPricingRule sets the effective price of the menu item and its variants at the given time
+func (a priceAdjuster) applyPricingRule(item *models.MenuItem, at time.Time) {
+	rule := a.rule(item.CategoryID, at)
+	if rule == nil {
+		return
+	}
+	item.EffectivePrice = models.EffectivePrice{RegularPrice: item.Price, PricingRule: rule.Name}
+	item.Price = rule.Apply(item.Price)
+	for i := range item.Variants {
+		variant := &item.Variants[i]
+		variant.EffectivePrice = models.EffectivePrice{RegularPrice: variant.Price, PricingRule: rule.Name}
+		variant.Price = rule.Apply(variant.Price)
+	}
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type ProductionRepositoryInterface interface {
+	GetRecipes() ([]models.PreparedRecipe, error)
+	SaveRecipe(recipe models.PreparedRecipe) error
+	DeleteRecipe(ingredientID int) (bool, error)
+	Produce(ingredientID int, request models.ProductionRequest) (int, error)
+	GetBatch(id int) (models.ProductionBatch, error)
+}
+
+type ProductionRepository struct {
+	db *sql.DB
+}
+
+func NewProductionRepository(db *sql.DB) *ProductionRepository {
+	return &ProductionRepository{db: db}
+}
+
+// GetRecipes returns the recipes of all prepared ingredients, components are not priced
+func (repo *ProductionRepository) GetRecipes() ([]models.PreparedRecipe, error) {
+	return getRecipes(repo.db, ``)
+}
+
+func getRecipes(q querier, where string, args ...any) ([]models.PreparedRecipe, error) {
+	query := `
+	select p.IngredientID, p.Yield, c.IngredientID, c.Quantity
+	from prepared_ingredients p
+	join prepared_ingredient_components c on c.PreparedID = p.IngredientID
+	` + where + `
+	order by p.IngredientID, c.IngredientID
+	`
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipes := []models.PreparedRecipe{}
+	for rows.Next() {
+		var preparedID int
+		var yield float64
+		var component models.RecipeComponent
+		if err := rows.Scan(&preparedID, &yield, &component.IngredientID, &component.Quantity); err != nil {
+			return nil, err
+		}
+		if len(recipes) == 0 || recipes[len(recipes)-1].IngredientID != preparedID {
+			recipes = append(recipes, models.PreparedRecipe{IngredientID: preparedID, Yield: yield})
+		}
+		last := &recipes[len(recipes)-1]
+		last.Components = append(last.Components, component)
+	}
+	return recipes, rows.Err()
+}
+
+// SaveRecipe makes the ingredient prepared or replaces its recipe
+func (repo *ProductionRepository) SaveRecipe(recipe models.PreparedRecipe) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	insert into prepared_ingredients (IngredientID, Yield) values ($1, $2)
+	on conflict (IngredientID) do update set Yield = excluded.Yield
+	`
+	if _, err := tx.Exec(query, recipe.IngredientID, recipe.Yield); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from prepared_ingredient_components where PreparedID = $1`, recipe.IngredientID); err != nil {
+		return err
+	}
+
+	queryComponent := `
+	insert into prepared_ingredient_components (PreparedID, IngredientID, Quantity) values ($1, $2, $3)
+	`
+	for _, component := range recipe.Components {
+		if _, err := tx.Exec(queryComponent, recipe.IngredientID, component.IngredientID, component.Quantity); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteRecipe turns the prepared ingredient back into a raw one, reporting whether it had a recipe
+func (repo *ProductionRepository) DeleteRecipe(ingredientID int) (bool, error) {
+	result, err := repo.db.Exec(`delete from prepared_ingredients where IngredientID = $1`, ingredientID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Produce consumes the components of the requested batches and adds the produced quantity
+// to the prepared ingredient as a new lot, in one transaction. The prepared ingredient unit cost
+// moves to the weighted average with the actual cost of the consumed components.
+// Every stock change is logged as production referencing the batch.
+func (repo *ProductionRepository) Produce(ingredientID int, request models.ProductionRequest) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The recipe must not change while it is produced
+	if err := tx.QueryRow(`select IngredientID from prepared_ingredients where IngredientID = $1 for share`, ingredientID).Scan(&ingredientID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, models.ErrRecipeNotFound
+		}
+		return 0, err
+	}
+	recipes, err := getRecipes(tx, `where p.IngredientID = $1`, ingredientID)
+	if err != nil {
+		return 0, err
+	}
+	if len(recipes) == 0 {
+		return 0, models.ErrRecipeNotFound
+	}
+	components := recipes[0].Components
+
+	var id int
+	queryBatch := `
+	insert into production_batches (IngredientID, Batches, Quantity) values ($1, $2, $3)
+	returning ID
+	`
+	if err := tx.QueryRow(queryBatch, ingredientID, request.Batches, request.Quantity).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := setInventoryReason(tx, models.InventoryReasonProduction, models.ProductionReference(id)); err != nil {
+		return 0, fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	// Locking the inventory rows in ingredient order like orders do
+	ids := []int{ingredientID}
+	for _, component := range components {
+		ids = append(ids, component.IngredientID)
+	}
+	if err := lockInventoryRows(tx, ids); err != nil {
+		return 0, err
+	}
+
+	queryDeduct := `
+	update inventory set Quantity = Quantity - $1
+	where IngredientID = $2 and Quantity >= $1
+	returning UnitCost
+	`
+	queryItem := `
+	insert into production_batch_items (BatchID, IngredientID, Quantity, Cost) values ($1, $2, $3, $4)
+	`
+	var cost float64
+	for _, component := range components {
+		quantity := component.Quantity * float64(request.Batches)
+
+		var unitCost float64
+		if err := tx.QueryRow(queryDeduct, quantity, component.IngredientID).Scan(&unitCost); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("%w: ingredient %d", models.ErrInsufficientInventory, component.IngredientID)
+			}
+			return 0, fmt.Errorf("failed to update inventory: %w", err)
+		}
+		if _, err := consumeLots(tx, component.IngredientID, quantity); err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.Exec(queryItem, id, component.IngredientID, quantity, quantity*unitCost); err != nil {
+			return 0, err
+		}
+		cost += quantity * unitCost
+	}
+
+	unitCost := math.Round(cost/request.Quantity*10000) / 10000
+	if err := receiveIntoInventory(tx, ingredientID, request.Quantity, unitCost); err != nil {
+		return 0, fmt.Errorf("failed to update inventory: %w", err)
+	}
+	if err := addLot(tx, ingredientID, 0, request.Quantity, request.ExpiresAt); err != nil {
+		return 0, fmt.Errorf("failed to add inventory lot: %w", err)
+	}
+
+	if _, err := tx.Exec(`update production_batches set Cost = $1 where ID = $2`, cost, id); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (repo *ProductionRepository) GetBatch(id int) (models.ProductionBatch, error) {
+	var batch models.ProductionBatch
+	query := `
+	select ID, IngredientID, Batches, Quantity, Cost::float8, CreatedAt
+	from production_batches
+	where ID = $1
+	`
+	err := repo.db.QueryRow(query, id).Scan(&batch.ID, &batch.IngredientID, &batch.Batches, &batch.Quantity, &batch.Cost, &batch.CreatedAt)
+	if err != nil {
+		return models.ProductionBatch{}, err
+	}
+	batch.UnitCost = math.Round(batch.Cost/batch.Quantity*10000) / 10000
+	batch.Cost = math.Round(batch.Cost*100) / 100
+
+	rows, err := repo.db.Query(`
+	select IngredientID, Quantity, Cost::float8
+	from production_batch_items
+	where BatchID = $1
+	order by IngredientID
+	`, id)
+	if err != nil {
+		return models.ProductionBatch{}, err
+	}
+	defer rows.Close()
+
+	batch.Consumed = []models.ProductionComponent{}
+	for rows.Next() {
+		var component models.ProductionComponent
+		if err := rows.Scan(&component.IngredientID, &component.Quantity, &component.Cost); err != nil {
+			return models.ProductionBatch{}, err
+		}
+		component.Cost = math.Round(component.Cost*100) / 100
+		batch.Consumed = append(batch.Consumed, component)
+	}
+	return batch, rows.Err()
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type PromoRepositoryInterface interface {
+	GetAll() ([]models.PromoCode, error)
+	GetByID(id int) (models.PromoCode, error)
+	Add(promo models.PromoCode) (int, error)
+	Update(promo models.PromoCode) error
+	Delete(id int) error
+}
+
+type PromoRepository struct {
+	db *sql.DB
+}
+
+func NewPromoRepository(db *sql.DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+func (repo *PromoRepository) GetAll() ([]models.PromoCode, error) {
+	return getPromoCodes(repo.db, `order by p.ID`)
+}
+
+func (repo *PromoRepository) GetByID(id int) (models.PromoCode, error) {
+	promos, err := getPromoCodes(repo.db, `where p.ID = $1`, id)
+	if err != nil {
+		return models.PromoCode{}, err
+	}
+	if len(promos) == 0 {
+		return models.PromoCode{}, models.ErrPromoCodeNotFound
+	}
+	return promos[0], nil
+}
+
+// getPromoCodes loads promo codes with the number of orders that used them, cancelled orders excluded
+func getPromoCodes(q querier, where string, args ...any) ([]models.PromoCode, error) {
+	query := `
+	select p.ID, p.Code, COALESCE(p.Description, ''), p.DiscountType, p.Value::float8, p.BuyQuantity, p.GetQuantity,
+		p.MinSpend::float8, COALESCE(p.ProductID, 0), COALESCE(p.CategoryID, 0), p.ValidFrom, p.ValidTo,
+		p.MaxUses, p.MaxUsesPerCustomer, p.Active,
+		(
+			select count(distinct d.OrderID)
+			from order_discounts d
+			join orders o on o.ID = d.OrderID
+			where d.PromoCodeID = p.ID and o.Status <> 'cancelled'
+		)
+	from promo_codes p
+	` + where
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promos := []models.PromoCode{}
+	for rows.Next() {
+		var promo models.PromoCode
+		var validFrom, validTo sql.NullTime
+		err := rows.Scan(&promo.ID, &promo.Code, &promo.Description, &promo.DiscountType, &promo.Value, &promo.BuyQuantity, &promo.GetQuantity,
+			&promo.MinSpend, &promo.ProductID, &promo.CategoryID, &validFrom, &validTo,
+			&promo.MaxUses, &promo.MaxUsesPerCustomer, &promo.Active, &promo.Uses)
+		if err != nil {
+			return nil, err
+		}
+		if validFrom.Valid {
+			promo.ValidFrom = &validFrom.Time
+		}
+		if validTo.Valid {
+			promo.ValidTo = &validTo.Time
+		}
+		promos = append(promos, promo)
+	}
+	return promos, rows.Err()
+}
+
+func (repo *PromoRepository) Add(promo models.PromoCode) (int, error) {
+	var id int
+	query := `
+	insert into promo_codes (Code, Description, DiscountType, Value, BuyQuantity, GetQuantity, MinSpend, ProductID, CategoryID,
+		ValidFrom, ValidTo, MaxUses, MaxUsesPerCustomer, Active) values
+	($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	returning ID
+	`
+	err := repo.db.QueryRow(query, promo.Code, promo.Description, promo.DiscountType, promo.Value, promo.BuyQuantity, promo.GetQuantity,
+		promo.MinSpend, nullableID(promo.ProductID), nullableID(promo.CategoryID), promo.ValidFrom, promo.ValidTo,
+		promo.MaxUses, promo.MaxUsesPerCustomer, promo.Active).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, fmt.Errorf("%w: %s", models.ErrPromoCodeExists, promo.Code)
+	}
+	return id, err
+}
+
+func (repo *PromoRepository) Update(promo models.PromoCode) error {
+	query := `
+	update promo_codes
+	set Code = $1, Description = NULLIF($2, ''), DiscountType = $3, Value = $4, BuyQuantity = $5, GetQuantity = $6,
+		MinSpend = $7, ProductID = $8, CategoryID = $9, ValidFrom = $10, ValidTo = $11,
+		MaxUses = $12, MaxUsesPerCustomer = $13, Active = $14
+	where ID = $15
+	`
+	result, err := repo.db.Exec(query, promo.Code, promo.Description, promo.DiscountType, promo.Value, promo.BuyQuantity, promo.GetQuantity,
+		promo.MinSpend, nullableID(promo.ProductID), nullableID(promo.CategoryID), promo.ValidFrom, promo.ValidTo,
+		promo.MaxUses, promo.MaxUsesPerCustomer, promo.Active, promo.ID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %s", models.ErrPromoCodeExists, promo.Code)
+	}
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return models.ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+// Delete removes the promo code, discount lines of past orders keep the code
+func (repo *PromoRepository) Delete(id int) error {
+	result, err := repo.db.Exec(`delete from promo_codes where ID = $1`, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return models.ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// applyPromoCodes checks the promo codes given with the order and saves a discount line for each of them.
+// The codes are locked until the order commits so concurrent orders can not exceed the usage limits.
+// The total discount never exceeds the order subtotal.
+func applyPromoCodes(tx *sql.Tx, orderID int, customerName string, codes []string, lines []models.PromoLine, at time.Time) ([]models.OrderDiscount, error) {
+	discounts := []models.OrderDiscount{}
+	if len(codes) == 0 {
+		return discounts, nil
+	}
+
+	parents, err := getCategoryParents(tx)
+	if err != nil {
+		return nil, err
+	}
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.UnitPrice * float64(line.Quantity)
+	}
+
+	queryCustomerUses := `
+	select count(distinct d.OrderID)
+	from order_discounts d
+	join orders o on o.ID = d.OrderID
+	where d.PromoCodeID = $1 and o.Status <> 'cancelled' and lower(o.CustomerName) = lower($2)
+	`
+	queryDiscount := `
+	insert into order_discounts (OrderID, PromoCodeID, Code, Description, Amount) values
+	($1, $2, $3, NULLIF($4, ''), $5)
+	`
+	applied := make(map[string]bool)
+	var total float64
+	for _, code := range codes {
+		code = models.NormalizePromoCode(code)
+		if applied[code] {
+			continue
+		}
+		applied[code] = true
+
+		if _, err := tx.Exec(`select ID from promo_codes where Code = $1 for update`, code); err != nil {
+			return nil, err
+		}
+		promos, err := getPromoCodes(tx, `where p.Code = $1`, code)
+		if err != nil {
+			return nil, err
+		}
+		if len(promos) == 0 {
+			return nil, fmt.Errorf("%w: %s", models.ErrPromoCodeNotFound, code)
+		}
+		promo := promos[0]
+
+		if err := promo.ValidAt(at); err != nil {
+			return nil, fmt.Errorf("%s: %w", code, err)
+		}
+		if promo.MaxUses > 0 && promo.Uses >= promo.MaxUses {
+			return nil, fmt.Errorf("%s: %w: the code is used up", code, models.ErrPromoCodeNotApplicable)
+		}
+		if promo.MaxUsesPerCustomer > 0 {
+			var uses int
+			if err := tx.QueryRow(queryCustomerUses, promo.ID, customerName).Scan(&uses); err != nil {
+				return nil, err
+			}
+			if uses >= promo.MaxUsesPerCustomer {
+				return nil, fmt.Errorf("%s: %w: the customer has already used the code %d times", code, models.ErrPromoCodeNotApplicable, uses)
+			}
+		}
+
+		amount, err := promo.Discount(scopePromoLines(promo, lines, parents))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", code, err)
+		}
+		amount = math.Min(amount, math.Round((subtotal-total)*100)/100)
+
+		if _, err := tx.Exec(queryDiscount, orderID, promo.ID, promo.Code, promo.Description, amount); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, models.OrderDiscount{
+			PromoCodeID: promo.ID,
+			Code:        promo.Code,
+			Description: promo.Description,
+			Amount:      amount,
+		})
+		total += amount
+	}
+	return discounts, nil
+}
+
+// scopePromoLines marks the lines the promo code applies to
+func scopePromoLines(promo models.PromoCode, lines []models.PromoLine, parents map[int]int) []models.PromoLine {
+	scoped := make([]models.PromoLine, len(lines))
+	for i, line := range lines {
+		line.InScope = (promo.ProductID == 0 && promo.CategoryID == 0) ||
+			(promo.ProductID != 0 && line.ProductID == promo.ProductID) ||
+			(promo.CategoryID != 0 && inCategory(parents, line.CategoryID, promo.CategoryID))
+		scoped[i] = line
+	}
+	return scoped
+}
+
+// keepPromoDiscounts saves again the discount lines an order already had, recomputed for its new lines.
+// The codes were checked when first applied, so they are not checked for being active, valid or used up again.
+// A code that no longer gives a discount on the lines keeps a zero line, the discount of a deleted code
+// keeps its previous amount. The total discount never exceeds the order subtotal.
+func keepPromoDiscounts(tx *sql.Tx, orderID int, kept []models.OrderDiscount, lines []models.PromoLine) ([]models.OrderDiscount, error) {
+	discounts := []models.OrderDiscount{}
+	if len(kept) == 0 {
+		return discounts, nil
+	}
+
+	parents, err := getCategoryParents(tx)
+	if err != nil {
+		return nil, err
+	}
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.UnitPrice * float64(line.Quantity)
+	}
+
+	queryDiscount := `
+	insert into order_discounts (OrderID, PromoCodeID, Code, Description, Amount) values
+	($1, $2, $3, NULLIF($4, ''), $5)
+	`
+	var total float64
+	for _, discount := range kept {
+		var promos []models.PromoCode
+		if discount.PromoCodeID != 0 {
+			if promos, err = getPromoCodes(tx, `where p.ID = $1`, discount.PromoCodeID); err != nil {
+				return nil, err
+			}
+		}
+		if len(promos) > 0 {
+			amount, err := promos[0].Discount(scopePromoLines(promos[0], lines, parents))
+			if err != nil && !errors.Is(err, models.ErrPromoCodeNotApplicable) {
+				return nil, err
+			}
+			discount.Amount = amount
+		}
+		discount.Amount = math.Max(0, math.Min(discount.Amount, math.Round((subtotal-total)*100)/100))
+
+		if _, err := tx.Exec(queryDiscount, orderID, nullableID(discount.PromoCodeID), discount.Code, discount.Description, discount.Amount); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, discount)
+		total += discount.Amount
+	}
+	return discounts, nil
+}
+
+// getOrderDiscounts returns the discount lines of the order
+func getOrderDiscounts(q querier, orderID int) ([]models.OrderDiscount, error) {
+	query := `
+	select COALESCE(PromoCodeID, 0), Code, COALESCE(Description, ''), Amount::float8
+	from order_discounts
+	where OrderID = $1
+	order by ID
+	`
+	rows, err := q.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []models.OrderDiscount
+	for rows.Next() {
+		var discount models.OrderDiscount
+		if err := rows.Scan(&discount.PromoCodeID, &discount.Code, &discount.Description, &discount.Amount); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, discount)
+	}
+	return discounts, rows.Err()
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type PurchaseOrderRepositoryInterface interface {
+	GetAll(status string) ([]models.PurchaseOrder, error)
+	GetByID(id int) (models.PurchaseOrder, error)
+	Add(order models.PurchaseOrder) (int, error)
+	Update(order models.PurchaseOrder) error
+	Send(id int) error
+	Receive(id int, receipt models.PurchaseReceipt) error
+}
+
+type PurchaseOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderRepository(db *sql.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+// GetAll returns purchase orders, newest first. An empty status returns all of them.
+func (repo *PurchaseOrderRepository) GetAll(status string) ([]models.PurchaseOrder, error) {
+	query := `
+	select ID, SupplierID, Status, COALESCE(Notes, ''), CreatedAt, UpdatedAt from purchase_orders
+	where $1 = '' or Status::text = $1
+	order by CreatedAt desc, ID desc
+	`
+	rows, err := repo.db.Query(query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []models.PurchaseOrder{}
+	for rows.Next() {
+		var order models.PurchaseOrder
+		if err := rows.Scan(&order.ID, &order.SupplierID, &order.Status, &order.Notes, &order.CreatedAt, &order.UpdatedAt); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		if err := fillPurchaseOrderItems(repo.db, &orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+func (repo *PurchaseOrderRepository) GetByID(id int) (models.PurchaseOrder, error) {
+	query := `
+	select ID, SupplierID, Status, COALESCE(Notes, ''), CreatedAt, UpdatedAt from purchase_orders where ID = $1
+	`
+	var order models.PurchaseOrder
+	err := repo.db.QueryRow(query, id).Scan(&order.ID, &order.SupplierID, &order.Status, &order.Notes, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.PurchaseOrder{}, models.ErrPurchaseOrderNotFound
+		}
+		return models.PurchaseOrder{}, err
+	}
+
+	if err := fillPurchaseOrderItems(repo.db, &order); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return order, nil
+}
+
+// Add saves a new purchase order in draft status together with its lines
+func (repo *PurchaseOrderRepository) Add(order models.PurchaseOrder) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	insert into purchase_orders (SupplierID, Status, Notes) values
+	($1, 'draft', NULLIF($2, ''))
+	returning ID
+	`
+	var id int
+	if err := tx.QueryRow(query, order.SupplierID, order.Notes).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := savePurchaseOrderItems(tx, id, order.Items); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Update replaces supplier, notes and lines of a draft purchase order
+func (repo *PurchaseOrderRepository) Update(order models.PurchaseOrder) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrderStatus(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusDraft {
+		return fmt.Errorf("%w: only draft purchase orders can be edited", models.ErrPurchaseOrderStatus)
+	}
+
+	query := `
+	update purchase_orders
+	set SupplierID = $1, Notes = NULLIF($2, ''), UpdatedAt = CURRENT_TIMESTAMP
+	where ID = $3
+	`
+	if _, err := tx.Exec(query, order.SupplierID, order.Notes, order.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from purchase_order_items where PurchaseOrderID = $1`, order.ID); err != nil {
+		return err
+	}
+	if err := savePurchaseOrderItems(tx, order.ID, order.Items); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Send marks a draft purchase order as sent to the supplier
+func (repo *PurchaseOrderRepository) Send(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrderStatus(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusDraft {
+		return fmt.Errorf("%w: purchase order is already %s", models.ErrPurchaseOrderStatus, status)
+	}
+
+	if err := setPurchaseOrderStatus(tx, id, models.PurchaseOrderStatusSent); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Receive books delivered quantities into inventory. Every stock change is logged
+// as purchase_receipt referencing the purchase order. The order becomes received once
+// all of its lines are delivered in full, partially_received otherwise.
+func (repo *PurchaseOrderRepository) Receive(id int, receipt models.PurchaseReceipt) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrderStatus(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusSent && status != models.PurchaseOrderStatusPartiallyReceived {
+		return fmt.Errorf("%w: purchase order is %s", models.ErrPurchaseOrderStatus, status)
+	}
+
+	items, err := getPurchaseOrderItems(tx, id)
+	if err != nil {
+		return err
+	}
+	outstanding := make(map[int]float64)
+	unitCosts := make(map[int]float64)
+	for _, item := range items {
+		outstanding[item.IngredientID] = item.Outstanding()
+		unitCosts[item.IngredientID] = item.UnitCost
+	}
+
+	received := receipt.Items
+	if len(received) == 0 {
+		for _, item := range items {
+			if item.Outstanding() > 0 {
+				received = append(received, models.ReceivedItem{IngredientID: item.IngredientID, Quantity: item.Outstanding()})
+			}
+		}
+	}
+	if len(received) == 0 {
+		return fmt.Errorf("%w: nothing left to receive", models.ErrInvalidReceipt)
+	}
+
+	if err := setInventoryReason(tx, models.InventoryReasonPurchaseReceipt, models.PurchaseOrderReference(id)); err != nil {
+		return fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	for _, item := range received {
+		left, ok := outstanding[item.IngredientID]
+		if !ok {
+			return fmt.Errorf("%w: ingredient %d is not in the purchase order", models.ErrInvalidReceipt, item.IngredientID)
+		}
+		if item.Quantity <= 0 || item.Quantity > left {
+			return fmt.Errorf("%w: quantity of ingredient %d must be between 0 and %v", models.ErrInvalidReceipt, item.IngredientID, left)
+		}
+		outstanding[item.IngredientID] = left - item.Quantity
+
+		queryLine := `
+		update purchase_order_items
+		set ReceivedQuantity = ReceivedQuantity + $1
+		where PurchaseOrderID = $2 and IngredientID = $3
+		`
+		if _, err := tx.Exec(queryLine, item.Quantity, id, item.IngredientID); err != nil {
+			return err
+		}
+		if err := receiveIntoInventory(tx, item.IngredientID, item.Quantity, unitCosts[item.IngredientID]); err != nil {
+			return fmt.Errorf("failed to update inventory: %w", err)
+		}
+		if err := addLot(tx, item.IngredientID, id, item.Quantity, item.ExpiresAt); err != nil {
+			return fmt.Errorf("failed to add inventory lot: %w", err)
+		}
+	}
+
+	newStatus := models.PurchaseOrderStatusReceived
+	for _, left := range outstanding {
+		if left > 0 {
+			newStatus = models.PurchaseOrderStatusPartiallyReceived
+			break
+		}
+	}
+	if err := setPurchaseOrderStatus(tx, id, newStatus); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// receiveIntoInventory adds the received quantity to the stock and moves the ingredient unit cost
+// to the weighted average of the stock on hand and the receipt.
+func receiveIntoInventory(tx *sql.Tx, ingredientID int, quantity, unitCost float64) error {
+	query := `
+	update inventory
+	set UnitCost = case
+			when Quantity + $1 > 0 then ROUND((Quantity * UnitCost + $1 * $2) / (Quantity + $1), 4)
+			else $2
+		end,
+		Quantity = Quantity + $1
+	where IngredientID = $3
+	`
+	_, err := tx.Exec(query, quantity, unitCost, ingredientID)
+	return err
+}
+
+// lockPurchaseOrderStatus returns the purchase order status and locks the row until the transaction ends.
+func lockPurchaseOrderStatus(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow(`select Status from purchase_orders where ID = $1 for update`, id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", models.ErrPurchaseOrderNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+func setPurchaseOrderStatus(tx *sql.Tx, id int, status string) error {
+	_, err := tx.Exec(`update purchase_orders set Status = $1, UpdatedAt = CURRENT_TIMESTAMP where ID = $2`, status, id)
+	return err
+}
+
+func savePurchaseOrderItems(tx *sql.Tx, orderID int, items []models.PurchaseOrderItem) error {
+	query := `
+	insert into purchase_order_items (PurchaseOrderID, IngredientID, Quantity, UnitCost) values
+	($1, $2, $3, $4)
+	`
+	for _, item := range items {
+		if _, err := tx.Exec(query, orderID, item.IngredientID, item.Quantity, item.UnitCost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getPurchaseOrderItems(q querier, orderID int) ([]models.PurchaseOrderItem, error) {
+	query := `
+	select IngredientID, Quantity, ReceivedQuantity, UnitCost from purchase_order_items
+	where PurchaseOrderID = $1
+	order by ID
+	`
+	rows, err := q.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PurchaseOrderItem{}
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		if err := rows.Scan(&item.IngredientID, &item.Quantity, &item.ReceivedQuantity, &item.UnitCost); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func fillPurchaseOrderItems(q querier, order *models.PurchaseOrder) error {
+	items, err := getPurchaseOrderItems(q, order.ID)
+	if err != nil {
+		return err
+	}
+	order.Items = items
+	order.Total = 0
+	for _, item := range items {
+		order.Total += item.Quantity * item.UnitCost
+	}
+	order.Total = math.Round(order.Total*100) / 100
+	return nil
+}
@@ -4,15 +4,21 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/sunzhqr/frappuccino/internal/models"
 )
 
 type ReportRespositoryInterface interface {
+	GetSales(filter models.SalesFilter) (models.TotalSales, error)
 	GetPopularMenuItems() ([]models.PopularItem, error)
+	GetSalesByCategory(from, to time.Time) ([]models.CategorySales, error)
+	GetMargins(from, to time.Time) ([]models.ItemMargin, error)
+	GetUsageVariance(from, to time.Time) ([]models.UsageVariance, error)
+	GetDiscounts(from, to time.Time) (models.DiscountReport, error)
 	SearchOrders(searchQuery string) ([]models.SearchOrderResult, error)
-	SearchMenuItems(searchQuery string, minPrice, maxPrice int) ([]models.SearchMenuItem, error)
+	SearchMenuItems(searchQuery string, minPrice, maxPrice int, excludeAllergens []string) ([]models.SearchMenuItem, error)
 }
 
 type ReportRespository struct {
@@ -23,13 +29,97 @@ func NewReportRespository(db *sql.DB) *ReportRespository {
 	return &ReportRespository{db: db}
 }
 
+// GetSales computes revenue figures from the order totals stored at checkout.
+func (repo *ReportRespository) GetSales(filter models.SalesFilter) (models.TotalSales, error) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !filter.From.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt >= $%d", argIndex)
+		args = append(args, filter.From)
+		argIndex++
+	}
+	if !filter.To.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt < $%d", argIndex)
+		args = append(args, filter.To)
+		argIndex++
+	}
+	if len(filter.Statuses) > 0 {
+		where += fmt.Sprintf(" AND o.Status = ANY($%d::order_status[])", argIndex)
+		args = append(args, pq.Array(filter.Statuses))
+		argIndex++
+	} else {
+		where += " AND o.Status <> 'cancelled'"
+	}
+
+	selectSummary := `
+			COUNT(o.ID),
+			COALESCE(SUM(o.Total), 0),
+			COALESCE(ROUND(AVG(o.Total), 2), 0),
+			COALESCE(SUM(u.units), 0)
+		FROM orders o
+		LEFT JOIN (
+			SELECT OrderID, SUM(Quantity) AS units
+			FROM order_items
+			GROUP BY OrderID
+		) u ON u.OrderID = o.ID
+	`
+
+	var result models.TotalSales
+	err := repo.db.QueryRow("SELECT"+selectSummary+where, args...).Scan(
+		&result.NumberOfOrders, &result.TotalSales, &result.AverageOrderValue, &result.UnitsSold,
+	)
+	if err != nil {
+		return models.TotalSales{}, fmt.Errorf("error getting total sales: %v", err)
+	}
+
+	if filter.GroupBy == "" {
+		return result, nil
+	}
+	result.GroupBy = filter.GroupBy
+
+	queryPeriods := fmt.Sprintf(`
+		SELECT
+			TO_CHAR(DATE_TRUNC('%s', o.CreatedAt), 'YYYY-MM-DD') AS period,`, filter.GroupBy) +
+		selectSummary + where + `
+		GROUP BY period
+		ORDER BY period
+	`
+	rows, err := repo.db.Query(queryPeriods, args...)
+	if err != nil {
+		return models.TotalSales{}, fmt.Errorf("error getting sales by %s: %v", filter.GroupBy, err)
+	}
+	defer rows.Close()
+
+	result.Periods = []models.SalesPeriod{}
+	for rows.Next() {
+		var period models.SalesPeriod
+		if err := rows.Scan(&period.Period, &period.NumberOfOrders, &period.TotalSales, &period.AverageOrderValue, &period.UnitsSold); err != nil {
+			return models.TotalSales{}, err
+		}
+		result.Periods = append(result.Periods, period)
+	}
+
+	return result, rows.Err()
+}
+
 func (repo *ReportRespository) GetPopularMenuItems() ([]models.PopularItem, error) {
+	// Bundle components are attributed to their own menu items as well
 	query := `
-		SELECT oi.productid, mi.name, mi.description, SUM(quantity) as total 
-		FROM order_items oi
-		JOIN menu_items mi on oi.productid = mi.ID
-		GROUP BY oi.productid, mi.name, mi.description
-		ORDER BY total DESC
+		WITH sold AS (
+			SELECT ProductID, Quantity AS direct, 0 AS bundled
+			FROM order_items
+			UNION ALL
+			SELECT c.ProductID, 0, c.Quantity * oi.Quantity
+			FROM order_item_components c
+			JOIN order_items oi ON oi.ID = c.OrderItemID
+		)
+		SELECT s.ProductID, mi.name, mi.description, SUM(s.direct), SUM(s.bundled)
+		FROM sold s
+		JOIN menu_items mi on s.ProductID = mi.ID
+		GROUP BY s.ProductID, mi.name, mi.description
+		ORDER BY SUM(s.direct) + SUM(s.bundled) DESC
 	`
 	rows, err := repo.db.Query(query)
 	if err != nil {
@@ -40,13 +130,293 @@ func (repo *ReportRespository) GetPopularMenuItems() ([]models.PopularItem, erro
 	var result []models.PopularItem
 	for rows.Next() {
 		var item models.PopularItem
-		if err := rows.Scan(&item.ProductID, &item.Name, &item.Description, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.Description, &item.Quantity, &item.BundleQuantity); err != nil {
 			return nil, err
 		}
 		result = append(result, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return result, nil
+	// Splitting sold quantity of each item by variant
+	queryVariants := `
+		SELECT productid, VariantName, SUM(quantity) as total
+		FROM order_items
+		WHERE VariantName IS NOT NULL
+		GROUP BY productid, VariantName
+		ORDER BY total DESC
+	`
+	variantRows, err := repo.db.Query(queryVariants)
+	if err != nil {
+		return nil, fmt.Errorf("error getting popular variants %v", err)
+	}
+	defer variantRows.Close()
+
+	variants := make(map[int][]models.PopularVariant)
+	for variantRows.Next() {
+		var productID int
+		var variant models.PopularVariant
+		if err := variantRows.Scan(&productID, &variant.Variant, &variant.Quantity); err != nil {
+			return nil, err
+		}
+		variants[productID] = append(variants[productID], variant)
+	}
+	for i := range result {
+		result[i].Variants = variants[result[i].ProductID]
+	}
+
+	return result, variantRows.Err()
+}
+
+// GetSalesByCategory returns units sold and revenue per category, subcategories included.
+// Zero from/to mean no bound, to is exclusive.
+func (repo *ReportRespository) GetSalesByCategory(from, to time.Time) ([]models.CategorySales, error) {
+	where := " WHERE o.Status <> 'cancelled'"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !from.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt >= $%d", argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if !to.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt < $%d", argIndex)
+		args = append(args, to)
+		argIndex++
+	}
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT ID AS ancestor, ID AS descendant FROM categories
+			UNION ALL
+			SELECT t.ancestor, c.ID FROM tree t JOIN categories c ON c.ParentID = t.descendant
+		),
+		sales AS (
+			SELECT mi.CategoryID, SUM(oi.Quantity) AS units, SUM(oi.LineTotal) AS revenue
+			FROM order_items oi
+			JOIN orders o ON o.ID = oi.OrderID
+			JOIN menu_items mi ON mi.ID = oi.ProductID` + where + `
+			GROUP BY mi.CategoryID
+		)
+		SELECT c.ID, c.Name, COALESCE(c.ParentID, 0),
+			COALESCE(SUM(s.units), 0) AS units,
+			COALESCE(SUM(s.revenue), 0) AS revenue
+		FROM categories c
+		JOIN tree t ON t.ancestor = c.ID
+		LEFT JOIN sales s ON s.CategoryID = t.descendant
+		GROUP BY c.ID, c.Name, c.ParentID
+		ORDER BY revenue DESC, c.ID
+	`
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sales by category %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.CategorySales{}
+	for rows.Next() {
+		var item models.CategorySales
+		if err := rows.Scan(&item.CategoryID, &item.Name, &item.ParentID, &item.UnitsSold, &item.Revenue); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// GetMargins ranks menu items by contribution margin: revenue of the sold lines minus
+// the ingredients recorded on them at the unit costs of the time of sale. The order discount is split over
+// the order lines in proportion to their totals. Zero from/to mean no bound, to is exclusive.
+func (repo *ReportRespository) GetMargins(from, to time.Time) ([]models.ItemMargin, error) {
+	where := " WHERE o.Status <> 'cancelled'"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !from.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt >= $%d", argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if !to.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt < $%d", argIndex)
+		args = append(args, to)
+		argIndex++
+	}
+
+	query := `
+		WITH lines AS (
+			SELECT oi.ID, oi.ProductID, oi.Quantity, oi.LineTotal,
+				COALESCE(o.DiscountTotal * oi.LineTotal / NULLIF(SUM(oi.LineTotal) OVER (PARTITION BY oi.OrderID), 0), 0) AS Discount
+			FROM order_items oi
+			JOIN orders o ON o.ID = oi.OrderID` + where + `
+		),
+		line_costs AS (
+			SELECT oii.OrderItemID, SUM(oii.Quantity * oii.UnitCost) AS cost
+			FROM order_item_ingredients oii
+			WHERE oii.OrderItemID IN (SELECT ID FROM lines)
+			GROUP BY oii.OrderItemID
+		)
+		SELECT mi.ID, mi.Name,
+			SUM(l.Quantity) AS units,
+			SUM(l.Discount)::float8 AS discounts,
+			SUM(l.LineTotal - l.Discount)::float8 AS revenue,
+			COALESCE(SUM(lc.cost), 0)::float8 AS food_cost
+		FROM lines l
+		JOIN menu_items mi ON mi.ID = l.ProductID
+		LEFT JOIN line_costs lc ON lc.OrderItemID = l.ID
+		GROUP BY mi.ID, mi.Name
+		ORDER BY SUM(l.LineTotal - l.Discount) - COALESCE(SUM(lc.cost), 0) DESC, mi.ID
+	`
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting margins %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.ItemMargin{}
+	for rows.Next() {
+		var item models.ItemMargin
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.UnitsSold, &item.Discounts, &item.Revenue, &item.FoodCost); err != nil {
+			return nil, err
+		}
+		item.ContributionMargin = math.Round((item.Revenue-item.FoodCost)*100) / 100
+		if item.Revenue > 0 {
+			item.MarginPercent = math.Round(item.ContributionMargin/item.Revenue*10000) / 100
+		}
+		item.Discounts = math.Round(item.Discounts*100) / 100
+		item.Revenue = math.Round(item.Revenue*100) / 100
+		item.FoodCost = math.Round(item.FoodCost*100) / 100
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// GetUsageVariance compares per ingredient the recipe usage of sold order lines with the stock decrease
+// recorded in inventory_transactions. Only sales with their cancellations, waste and count corrections are
+// consumption: receipts, production and manual adjustments such as a restock only move stock.
+// Zero from/to mean no bound, to is exclusive.
+func (repo *ReportRespository) GetUsageVariance(from, to time.Time) ([]models.UsageVariance, error) {
+	orderWhere := " WHERE o.Status <> 'cancelled'"
+	ledgerWhere := " WHERE reason IN ('sale', 'cancellation', 'waste', 'count_correction')"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !from.IsZero() {
+		orderWhere += fmt.Sprintf(" AND o.CreatedAt >= $%d", argIndex)
+		ledgerWhere += fmt.Sprintf(" AND created_at >= $%d", argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if !to.IsZero() {
+		orderWhere += fmt.Sprintf(" AND o.CreatedAt < $%d", argIndex)
+		ledgerWhere += fmt.Sprintf(" AND created_at < $%d", argIndex)
+		args = append(args, to)
+		argIndex++
+	}
+
+	query := `
+		WITH theoretical AS (
+			SELECT oii.IngredientID, SUM(oii.Quantity) AS used
+			FROM order_item_ingredients oii
+			JOIN order_items oi ON oi.ID = oii.OrderItemID
+			JOIN orders o ON o.ID = oi.OrderID` + orderWhere + `
+			GROUP BY oii.IngredientID
+		),
+		actual AS (
+			SELECT IngredientID,
+				-SUM(quantity_change) AS used,
+				-COALESCE(SUM(quantity_change) FILTER (WHERE reason = 'waste'), 0) AS waste
+			FROM inventory_transactions` + ledgerWhere + `
+			GROUP BY IngredientID
+		)
+		SELECT i.IngredientID, i.Name, i.Unit, i.UnitCost::float8,
+			COALESCE(t.used, 0)::float8, COALESCE(a.used, 0)::float8, COALESCE(a.waste, 0)::float8
+		FROM inventory i
+		LEFT JOIN theoretical t ON t.IngredientID = i.IngredientID
+		LEFT JOIN actual a ON a.IngredientID = i.IngredientID
+		WHERE t.IngredientID IS NOT NULL OR a.IngredientID IS NOT NULL
+		ORDER BY ABS((COALESCE(a.used, 0) - COALESCE(t.used, 0)) * i.UnitCost) DESC, i.IngredientID
+	`
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting usage variance %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.UsageVariance{}
+	for rows.Next() {
+		var item models.UsageVariance
+		var unitCost float64
+		if err := rows.Scan(&item.IngredientID, &item.Name, &item.Unit, &unitCost, &item.TheoreticalUsage, &item.ActualUsage, &item.Waste); err != nil {
+			return nil, err
+		}
+		item.Variance = item.ActualUsage - item.TheoreticalUsage
+		item.VarianceCost = math.Round(item.Variance*unitCost*100) / 100
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// GetDiscounts reports the discounts given per promo code. Codes of deleted promo codes are still reported.
+// Zero from/to mean no bound, to is exclusive.
+func (repo *ReportRespository) GetDiscounts(from, to time.Time) (models.DiscountReport, error) {
+	where := " WHERE o.Status <> 'cancelled' AND o.DiscountTotal > 0"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !from.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt >= $%d", argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if !to.IsZero() {
+		where += fmt.Sprintf(" AND o.CreatedAt < $%d", argIndex)
+		args = append(args, to)
+		argIndex++
+	}
+
+	report := models.DiscountReport{Codes: []models.PromoCodeUsage{}}
+	querySummary := `
+		SELECT COUNT(*), COALESCE(SUM(o.DiscountTotal), 0)::float8
+		FROM orders o` + where
+	if err := repo.db.QueryRow(querySummary, args...).Scan(&report.DiscountedOrders, &report.TotalDiscount); err != nil {
+		return models.DiscountReport{}, fmt.Errorf("error getting discounts %v", err)
+	}
+
+	query := `
+		SELECT COALESCE(d.PromoCodeID, 0), d.Code,
+			COUNT(DISTINCT o.ID),
+			COUNT(DISTINCT lower(o.CustomerName)),
+			SUM(d.Amount)::float8,
+			SUM(o.Total + o.DiscountTotal)::float8,
+			SUM(o.Total)::float8
+		FROM order_discounts d
+		JOIN orders o ON o.ID = d.OrderID` + where + `
+		GROUP BY COALESCE(d.PromoCodeID, 0), d.Code
+		ORDER BY SUM(d.Amount) DESC, d.Code
+	`
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return models.DiscountReport{}, fmt.Errorf("error getting discounts %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage models.PromoCodeUsage
+		err := rows.Scan(&usage.PromoCodeID, &usage.Code, &usage.Orders, &usage.Customers,
+			&usage.TotalDiscount, &usage.GrossSales, &usage.NetSales)
+		if err != nil {
+			return models.DiscountReport{}, err
+		}
+		report.Codes = append(report.Codes, usage)
+	}
+
+	return report, rows.Err()
 }
 
 func (repo *ReportRespository) SearchOrders(searchQuery string) ([]models.SearchOrderResult, error) {
@@ -55,7 +425,7 @@ func (repo *ReportRespository) SearchOrders(searchQuery string) ([]models.Search
 			ord.ID, 
 			ord.CustomerName, 
 			ARRAY_AGG(mi.Name) AS items, 
-			SUM(mi.Price) AS total,
+			ord.Total AS total,
 			ts_rank(
 				to_tsvector(ord.CustomerName || ' ' || STRING_AGG(mi.Name, ' ')), 
 				websearch_to_tsquery($1)
@@ -63,7 +433,7 @@ func (repo *ReportRespository) SearchOrders(searchQuery string) ([]models.Search
 		FROM orders ord
 		JOIN order_items oi ON ord.ID = oi.OrderID
 		JOIN menu_items mi ON oi.ProductID = mi.ID
-		GROUP BY ord.ID, ord.CustomerName
+		GROUP BY ord.ID, ord.CustomerName, ord.Total
 		HAVING to_tsvector(ord.CustomerName || ' ' || STRING_AGG(mi.Name, ' ')) @@ websearch_to_tsquery($1)
 		ORDER BY relevance DESC;
 	`
@@ -86,7 +456,8 @@ func (repo *ReportRespository) SearchOrders(searchQuery string) ([]models.Search
 	return result, nil
 }
 
-func (repo *ReportRespository) SearchMenuItems(searchQuery string, minPrice, maxPrice int) ([]models.SearchMenuItem, error) {
+// SearchMenuItems finds menu items by full text, items containing any of excludeAllergens are left out
+func (repo *ReportRespository) SearchMenuItems(searchQuery string, minPrice, maxPrice int, excludeAllergens []string) ([]models.SearchMenuItem, error) {
 	query := `
 		SELECT 
 			id, name, description, price,
@@ -132,5 +503,26 @@ func (repo *ReportRespository) SearchMenuItems(searchQuery string, minPrice, max
 		return nil, err
 	}
 
-	return result, nil
+	ingredientTags, err := getIngredientDietary(repo.db)
+	if err != nil {
+		return nil, err
+	}
+	menuTags, err := getMenuDietary(repo.db, ingredientTags)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := result[:0]
+	for _, item := range result {
+		info, ok := menuTags[item.ID]
+		if !ok {
+			info = models.CombineDietary(nil)
+		}
+		if info.ContainsAny(excludeAllergens) {
+			continue
+		}
+		item.DietaryInfo = info
+		filtered = append(filtered, item)
+	}
+	return filtered, nil
 }
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type StocktakeRepositoryInterface interface {
+	Open(notes string) (int, error)
+	GetAll() ([]models.Stocktake, error)
+	GetByID(id int) (models.Stocktake, error)
+	SaveCounts(id int, counts []models.StocktakeCount) error
+	GetVariance(id int) (models.StocktakeVariance, error)
+	Commit(id int) error
+}
+
+type StocktakeRepository struct {
+	db *sql.DB
+}
+
+func NewStocktakeRepository(db *sql.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+func (repo *StocktakeRepository) Open(notes string) (int, error) {
+	var id int
+	err := repo.db.QueryRow(`insert into stocktakes (Notes) values (NULLIF($1, '')) returning ID`, notes).Scan(&id)
+	return id, err
+}
+
+func (repo *StocktakeRepository) GetAll() ([]models.Stocktake, error) {
+	rows, err := repo.db.Query(`
+	select ID, Status, COALESCE(Notes, ''), CreatedAt, CommittedAt from stocktakes
+	order by CreatedAt desc, ID desc
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := []models.Stocktake{}
+	for rows.Next() {
+		stocktake, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, stocktake)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range stocktakes {
+		if stocktakes[i].Counts, err = repo.getCounts(stocktakes[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return stocktakes, nil
+}
+
+func (repo *StocktakeRepository) GetByID(id int) (models.Stocktake, error) {
+	row := repo.db.QueryRow(`
+	select ID, Status, COALESCE(Notes, ''), CreatedAt, CommittedAt from stocktakes where ID = $1
+	`, id)
+	stocktake, err := scanStocktake(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Stocktake{}, models.ErrStocktakeNotFound
+		}
+		return models.Stocktake{}, err
+	}
+
+	if stocktake.Counts, err = repo.getCounts(id); err != nil {
+		return models.Stocktake{}, err
+	}
+	return stocktake, nil
+}
+
+func scanStocktake(row interface{ Scan(dest ...any) error }) (models.Stocktake, error) {
+	var stocktake models.Stocktake
+	var committedAt sql.NullTime
+	if err := row.Scan(&stocktake.ID, &stocktake.Status, &stocktake.Notes, &stocktake.CreatedAt, &committedAt); err != nil {
+		return models.Stocktake{}, err
+	}
+	if committedAt.Valid {
+		stocktake.CommittedAt = &committedAt.Time
+	}
+	return stocktake, nil
+}
+
+func (repo *StocktakeRepository) getCounts(id int) ([]models.StocktakeCount, error) {
+	rows, err := repo.db.Query(`
+	select IngredientID, CountedQuantity from stocktake_counts
+	where StocktakeID = $1
+	order by IngredientID
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.StocktakeCount{}
+	for rows.Next() {
+		var count models.StocktakeCount
+		if err := rows.Scan(&count.IngredientID, &count.CountedQuantity); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// SaveCounts records counted quantities of an open stocktake, replacing earlier counts of the same ingredients
+func (repo *StocktakeRepository) SaveCounts(id int, counts []models.StocktakeCount) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(tx, id); err != nil {
+		return err
+	}
+
+	query := `
+	insert into stocktake_counts (StocktakeID, IngredientID, CountedQuantity) values
+	($1, $2, $3)
+	on conflict (StocktakeID, IngredientID) do update set CountedQuantity = excluded.CountedQuantity
+	`
+	for _, count := range counts {
+		if _, err := tx.Exec(query, id, count.IngredientID, count.CountedQuantity); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (repo *StocktakeRepository) GetVariance(id int) (models.StocktakeVariance, error) {
+	variance := models.StocktakeVariance{StocktakeID: id, Lines: []models.VarianceLine{}}
+	err := repo.db.QueryRow(`select Status from stocktakes where ID = $1`, id).Scan(&variance.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.StocktakeVariance{}, models.ErrStocktakeNotFound
+		}
+		return models.StocktakeVariance{}, err
+	}
+
+	query := `
+	select c.IngredientID, i.Name, i.Unit, COALESCE(c.SystemQuantity, i.Quantity), c.CountedQuantity, i.UnitCost::float8
+	from stocktake_counts c
+	join inventory i on i.IngredientID = c.IngredientID
+	where c.StocktakeID = $1
+	order by c.IngredientID
+	`
+	rows, err := repo.db.Query(query, id)
+	if err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.VarianceLine
+		var unitCost float64
+		if err := rows.Scan(&line.IngredientID, &line.Name, &line.Unit, &line.SystemQuantity, &line.CountedQuantity, &unitCost); err != nil {
+			return models.StocktakeVariance{}, err
+		}
+		line.Variance = line.CountedQuantity - line.SystemQuantity
+		line.VarianceCost = math.Round(float64(line.Variance)*unitCost*100) / 100
+		variance.TotalVarianceCost += line.VarianceCost
+		variance.Lines = append(variance.Lines, line)
+	}
+	variance.TotalVarianceCost = math.Round(variance.TotalVarianceCost*100) / 100
+	return variance, rows.Err()
+}
+
+// Commit replaces the stock of every counted ingredient with the counted quantity in one transaction.
+// Differences are logged as count_correction referencing the stocktake.
+func (repo *StocktakeRepository) Commit(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(tx, id); err != nil {
+		return err
+	}
+	if err := setInventoryReason(tx, models.InventoryReasonCountCorrection, models.StocktakeReference(id)); err != nil {
+		return fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	// Remembering system quantities, inventory rows are locked in ingredient order like in orders
+	querySnapshot := `
+	update stocktake_counts c
+	set SystemQuantity = i.Quantity
+	from (
+		select IngredientID, Quantity from inventory
+		where IngredientID in (select IngredientID from stocktake_counts where StocktakeID = $1)
+		order by IngredientID
+		for update
+	) i
+	where c.StocktakeID = $1 and c.IngredientID = i.IngredientID
+	`
+	if _, err := tx.Exec(querySnapshot, id); err != nil {
+		return fmt.Errorf("failed to save system quantities: %w", err)
+	}
+
+	queryApply := `
+	update inventory i
+	set Quantity = c.CountedQuantity
+	from stocktake_counts c
+	where c.StocktakeID = $1 and c.IngredientID = i.IngredientID and c.CountedQuantity <> i.Quantity
+	`
+	if _, err := tx.Exec(queryApply, id); err != nil {
+		return fmt.Errorf("failed to apply counts: %w", err)
+	}
+
+	// Shortages found by the count are taken from the lots
+	rows, err := tx.Query(`select IngredientID from stocktake_counts where StocktakeID = $1 and CountedQuantity < SystemQuantity`, id)
+	if err != nil {
+		return err
+	}
+	var short []int
+	for rows.Next() {
+		var ingredientID int
+		if err := rows.Scan(&ingredientID); err != nil {
+			rows.Close()
+			return err
+		}
+		short = append(short, ingredientID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, ingredientID := range short {
+		if err := trimLots(tx, ingredientID); err != nil {
+			return fmt.Errorf("failed to update lots: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`update stocktakes set Status = 'committed', CommittedAt = CURRENT_TIMESTAMP where ID = $1`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockOpenStocktake locks the stocktake until the transaction ends and checks it is still open
+func lockOpenStocktake(tx *sql.Tx, id int) error {
+	var status string
+	err := tx.QueryRow(`select Status from stocktakes where ID = $1 for update`, id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrStocktakeNotFound
+		}
+		return err
+	}
+	if status != models.StocktakeStatusOpen {
+		return models.ErrStocktakeCommitted
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type SupplierRepositoryInterface interface {
+	GetAll() ([]models.Supplier, error)
+	GetByID(id int) (models.Supplier, error)
+	Add(supplier models.Supplier) (int, error)
+	Update(supplier models.Supplier) error
+	Delete(id int) error
+	HasPurchaseOrders(id int) (bool, error)
+}
+
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+func (repo *SupplierRepository) GetAll() ([]models.Supplier, error) {
+	query := `
+	select ID, Name, COALESCE(ContactName, ''), COALESCE(Phone, ''), COALESCE(Email, '') from suppliers
+	order by Name
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := []models.Supplier{}
+	for rows.Next() {
+		var supplier models.Supplier
+		if err := rows.Scan(&supplier.ID, &supplier.Name, &supplier.ContactName, &supplier.Phone, &supplier.Email); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, rows.Err()
+}
+
+func (repo *SupplierRepository) GetByID(id int) (models.Supplier, error) {
+	query := `
+	select ID, Name, COALESCE(ContactName, ''), COALESCE(Phone, ''), COALESCE(Email, '') from suppliers where ID = $1
+	`
+	var supplier models.Supplier
+	err := repo.db.QueryRow(query, id).Scan(&supplier.ID, &supplier.Name, &supplier.ContactName, &supplier.Phone, &supplier.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Supplier{}, models.ErrSupplierNotFound
+		}
+		return models.Supplier{}, err
+	}
+	return supplier, nil
+}
+
+func (repo *SupplierRepository) Add(supplier models.Supplier) (int, error) {
+	query := `
+	insert into suppliers (Name, ContactName, Phone, Email) values
+	($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''))
+	returning ID
+	`
+	var id int
+	err := repo.db.QueryRow(query, supplier.Name, supplier.ContactName, supplier.Phone, supplier.Email).Scan(&id)
+	return id, err
+}
+
+func (repo *SupplierRepository) Update(supplier models.Supplier) error {
+	query := `
+	update suppliers
+	set Name = $1, ContactName = NULLIF($2, ''), Phone = NULLIF($3, ''), Email = NULLIF($4, '')
+	where ID = $5
+	`
+	_, err := repo.db.Exec(query, supplier.Name, supplier.ContactName, supplier.Phone, supplier.Email, supplier.ID)
+	return err
+}
+
+func (repo *SupplierRepository) Delete(id int) error {
+	_, err := repo.db.Exec(`delete from suppliers where ID = $1`, id)
+	return err
+}
+
+func (repo *SupplierRepository) HasPurchaseOrders(id int) (bool, error) {
+	var exists bool
+	err := repo.db.QueryRow(`select exists(select 1 from purchase_orders where SupplierID = $1)`, id).Scan(&exists)
+	return exists, err
+}
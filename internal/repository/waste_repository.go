@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+)
+
+type WasteRepositoryInterface interface {
+	Add(request models.WasteRequest) (int, error)
+	GetByID(id int) (models.WasteLog, error)
+	GetReport(filter models.WasteFilter) (models.WasteReport, error)
+	WriteOffExpired() (int, error)
+}
+
+type WasteRepository struct {
+	db *sql.DB
+}
+
+func NewWasteRepository(db *sql.DB) *WasteRepository {
+	return &WasteRepository{db: db}
+}
+
+// Add writes off the requested stock in one transaction. Menu items are expanded through
+// their base recipe. Every deduction is logged as waste referencing the write-off.
+func (repo *WasteRepository) Add(request models.WasteRequest) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO waste_logs (Reason, Notes) VALUES ($1, NULLIF($2, '')) RETURNING ID`, request.Reason, request.Notes).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := setInventoryReason(tx, models.InventoryReasonWaste, models.WasteReference(id)); err != nil {
+		return 0, fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	var lines []models.WasteLogItem
+	for _, item := range request.Items {
+		if item.IngredientID != 0 {
+			lines = append(lines, models.WasteLogItem{IngredientID: item.IngredientID, Quantity: item.Quantity})
+			continue
+		}
+
+		recipe, err := getOrderItemRecipe(tx, item.ProductID, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		for _, ingredient := range recipe {
+			lines = append(lines, models.WasteLogItem{
+				IngredientID: ingredient.IngredientID,
+				ProductID:    item.ProductID,
+				Quantity:     ingredient.Quantity * item.Quantity,
+			})
+		}
+	}
+	// Same locking order as orders to avoid deadlocks
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].IngredientID < lines[j].IngredientID
+	})
+
+	queryDeduct := `
+		UPDATE inventory SET Quantity = Quantity - $1
+		WHERE IngredientID = $2 AND Quantity >= $1
+		RETURNING UnitCost
+	`
+	queryItem := `
+		INSERT INTO waste_log_items (WasteID, IngredientID, MenuItemID, Quantity, Cost)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for _, line := range lines {
+		var unitCost float64
+		err := tx.QueryRow(queryDeduct, line.Quantity, line.IngredientID).Scan(&unitCost)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("%w: ingredient %d", models.ErrInsufficientInventory, line.IngredientID)
+			}
+			return 0, fmt.Errorf("failed to update inventory: %w", err)
+		}
+		if _, _, err := depleteLots(tx, line.IngredientID, float64(line.Quantity), true); err != nil {
+			return 0, err
+		}
+
+		cost := float64(line.Quantity) * unitCost
+		if _, err := tx.Exec(queryItem, id, line.IngredientID, nullableID(line.ProductID), line.Quantity, cost); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// WriteOffExpired writes off the remaining stock of all expired lots in one waste log with
+// reason expired. Returns 0 when there is nothing to write off.
+func (repo *WasteRepository) WriteOffExpired() (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lots change under the lock of their inventory row, taken in ingredient order like in orders
+	queryLock := `
+		SELECT IngredientID FROM inventory
+		WHERE IngredientID IN (SELECT IngredientID FROM inventory_lots WHERE Quantity > 0 AND ExpiresAt <= NOW())
+		ORDER BY IngredientID
+		FOR UPDATE
+	`
+	if _, err := tx.Exec(queryLock); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT ID, IngredientID, Quantity FROM inventory_lots
+		WHERE Quantity > 0 AND ExpiresAt <= NOW()
+		ORDER BY IngredientID, ID
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var lots []models.InventoryLot
+	for rows.Next() {
+		var lot models.InventoryLot
+		if err := rows.Scan(&lot.ID, &lot.IngredientID, &lot.Quantity); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(lots) == 0 {
+		return 0, nil
+	}
+
+	lotIDs := make([]string, len(lots))
+	for i, lot := range lots {
+		lotIDs[i] = strconv.Itoa(lot.ID)
+	}
+	notes := "Expired lots " + strings.Join(lotIDs, ", ")
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO waste_logs (Reason, Notes) VALUES ($1, $2) RETURNING ID`, models.WasteReasonExpired, notes).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := setInventoryReason(tx, models.InventoryReasonWaste, models.WasteReference(id)); err != nil {
+		return 0, fmt.Errorf("failed to set inventory reason: %w", err)
+	}
+
+	queryDeduct := `
+		UPDATE inventory SET Quantity = Quantity - $1
+		WHERE IngredientID = $2 AND Quantity >= $1
+		RETURNING UnitCost
+	`
+	queryItem := `
+		INSERT INTO waste_log_items (WasteID, IngredientID, Quantity, Cost)
+		VALUES ($1, $2, $3, $4)
+	`
+	for _, lot := range lots {
+		var unitCost float64
+		if err := tx.QueryRow(queryDeduct, lot.Quantity, lot.IngredientID).Scan(&unitCost); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("%w: ingredient %d", models.ErrInsufficientInventory, lot.IngredientID)
+			}
+			return 0, fmt.Errorf("failed to update inventory: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE inventory_lots SET Quantity = 0 WHERE ID = $1`, lot.ID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(queryItem, id, lot.IngredientID, lot.Quantity, lot.Quantity*unitCost); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+func (repo *WasteRepository) GetByID(id int) (models.WasteLog, error) {
+	var waste models.WasteLog
+	err := repo.db.QueryRow(`SELECT ID, Reason, COALESCE(Notes, ''), CreatedAt FROM waste_logs WHERE ID = $1`, id).Scan(
+		&waste.ID, &waste.Reason, &waste.Notes, &waste.CreatedAt,
+	)
+	if err != nil {
+		return models.WasteLog{}, err
+	}
+
+	query := `
+		SELECT IngredientID, COALESCE(MenuItemID, 0), Quantity, Cost::float8
+		FROM waste_log_items
+		WHERE WasteID = $1
+		ORDER BY ID
+	`
+	rows, err := repo.db.Query(query, id)
+	if err != nil {
+		return models.WasteLog{}, err
+	}
+	defer rows.Close()
+
+	waste.Items = []models.WasteLogItem{}
+	for rows.Next() {
+		var item models.WasteLogItem
+		if err := rows.Scan(&item.IngredientID, &item.ProductID, &item.Quantity, &item.Cost); err != nil {
+			return models.WasteLog{}, err
+		}
+		waste.TotalCost += item.Cost
+		item.Cost = math.Round(item.Cost*100) / 100
+		waste.Items = append(waste.Items, item)
+	}
+	waste.TotalCost = math.Round(waste.TotalCost*100) / 100
+	return waste, rows.Err()
+}
+
+// GetReport sums written off cost by reason, by ingredient and optionally by period
+func (repo *WasteRepository) GetReport(filter models.WasteFilter) (models.WasteReport, error) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+	argIndex := 1
+
+	if !filter.From.IsZero() {
+		where += fmt.Sprintf(" AND w.CreatedAt >= $%d", argIndex)
+		args = append(args, filter.From)
+		argIndex++
+	}
+	if !filter.To.IsZero() {
+		where += fmt.Sprintf(" AND w.CreatedAt < $%d", argIndex)
+		args = append(args, filter.To)
+		argIndex++
+	}
+	joins := `
+		FROM waste_logs w
+		JOIN waste_log_items wi ON wi.WasteID = w.ID
+	`
+	from := joins + where
+
+	report := models.WasteReport{ByReason: []models.WasteByReason{}, ByIngredient: []models.WasteByIngredient{}}
+	if err := repo.db.QueryRow("SELECT COALESCE(SUM(wi.Cost), 0)::float8"+from, args...).Scan(&report.TotalCost); err != nil {
+		return models.WasteReport{}, fmt.Errorf("error getting waste total: %v", err)
+	}
+	report.TotalCost = math.Round(report.TotalCost*100) / 100
+
+	rows, err := repo.db.Query(`
+		SELECT w.Reason, COUNT(DISTINCT w.ID), SUM(wi.Cost)::float8 AS cost`+from+`
+		GROUP BY w.Reason
+		ORDER BY cost DESC
+	`, args...)
+	if err != nil {
+		return models.WasteReport{}, fmt.Errorf("error getting waste by reason: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.WasteByReason
+		if err := rows.Scan(&item.Reason, &item.Entries, &item.Cost); err != nil {
+			return models.WasteReport{}, err
+		}
+		item.Cost = math.Round(item.Cost*100) / 100
+		report.ByReason = append(report.ByReason, item)
+	}
+	if err := rows.Err(); err != nil {
+		return models.WasteReport{}, err
+	}
+
+	ingredientRows, err := repo.db.Query(`
+		SELECT i.IngredientID, i.Name, i.Unit, SUM(wi.Quantity), SUM(wi.Cost)::float8 AS cost`+joins+`
+		JOIN inventory i ON i.IngredientID = wi.IngredientID`+where+`
+		GROUP BY i.IngredientID, i.Name, i.Unit
+		ORDER BY cost DESC, i.IngredientID
+	`, args...)
+	if err != nil {
+		return models.WasteReport{}, fmt.Errorf("error getting waste by ingredient: %v", err)
+	}
+	defer ingredientRows.Close()
+	for ingredientRows.Next() {
+		var item models.WasteByIngredient
+		if err := ingredientRows.Scan(&item.IngredientID, &item.Name, &item.Unit, &item.Quantity, &item.Cost); err != nil {
+			return models.WasteReport{}, err
+		}
+		item.Cost = math.Round(item.Cost*100) / 100
+		report.ByIngredient = append(report.ByIngredient, item)
+	}
+	if err := ingredientRows.Err(); err != nil {
+		return models.WasteReport{}, err
+	}
+
+	if filter.GroupBy == "" {
+		return report, nil
+	}
+	report.GroupBy = filter.GroupBy
+
+	periodRows, err := repo.db.Query(fmt.Sprintf(`
+		SELECT TO_CHAR(DATE_TRUNC('%s', w.CreatedAt), 'YYYY-MM-DD') AS period, SUM(wi.Cost)::float8`, filter.GroupBy)+from+`
+		GROUP BY period
+		ORDER BY period
+	`, args...)
+	if err != nil {
+		return models.WasteReport{}, fmt.Errorf("error getting waste by %s: %v", filter.GroupBy, err)
+	}
+	defer periodRows.Close()
+
+	report.Periods = []models.WastePeriod{}
+	for periodRows.Next() {
+		var period models.WastePeriod
+		if err := periodRows.Scan(&period.Period, &period.Cost); err != nil {
+			return models.WasteReport{}, err
+		}
+		period.Cost = math.Round(period.Cost*100) / 100
+		report.Periods = append(report.Periods, period)
+	}
+	return report, periodRows.Err()
+}
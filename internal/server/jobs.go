@@ -0,0 +1,44 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/sunzhqr/frappuccino/internal/service"
+)
+
+// writeOffExpiredLots writes off expired lots every interval for the lifetime of the process
+func writeOffExpiredLots(wasteService service.WasteServiceInterface, interval time.Duration, logger *slog.Logger) {
+	const op = "server.writeOffExpiredLots"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		waste, err := wasteService.WriteOffExpired()
+		if err != nil {
+			logger.Error(op+": Could not write off expired lots", "error", err)
+			continue
+		}
+		if waste.ID != 0 {
+			logger.Info(op+": Expired lots written off", "waste_id", waste.ID, "total_cost", waste.TotalCost)
+		}
+	}
+}
+
+// applyScheduledPrices applies due scheduled prices every interval for the lifetime of the process
+func applyScheduledPrices(pricingService service.PricingServiceInterface, interval time.Duration, logger *slog.Logger) {
+	const op = "server.applyScheduledPrices"
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		applied, err := pricingService.ApplyScheduledPrices()
+		if err != nil {
+			logger.Error(op+": Could not apply scheduled prices", "error", err)
+			continue
+		}
+		for _, price := range applied {
+			logger.Info(op+": Scheduled price applied", "scheduled_price_id", price.ID, "product_id", price.ProductID, "price", price.Price)
+		}
+	}
+}
@@ -5,27 +5,95 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/sunzhqr/frappuccino/config"
 	"github.com/sunzhqr/frappuccino/internal/handler"
+	"github.com/sunzhqr/frappuccino/internal/notifier"
 	"github.com/sunzhqr/frappuccino/internal/repository"
 	"github.com/sunzhqr/frappuccino/internal/service"
 )
 
 func setupRoutes(router *http.ServeMux, db *sql.DB, logger *slog.Logger) {
+	// Low stock alerts
+	lowStockNotifier := notifier.Multi{notifier.NewLogNotifier(logger)}
+	if url := config.GetLowStockWebhookURL(); url != "" {
+		lowStockNotifier = append(lowStockNotifier, notifier.NewWebhookNotifier(url, logger))
+	}
+
 	// Inventory
 	inventoryRepo := repository.NewInventoryRepository(db)
-	inventoryService := service.NewInventoryService(inventoryRepo)
+	inventoryService := service.NewInventoryService(inventoryRepo, lowStockNotifier)
 	inventoryHandler := handler.NewInventoryHandler(inventoryService, logger)
 
+	// Prepared ingredients
+	productionRepo := repository.NewProductionRepository(db)
+	productionService := service.NewProductionService(productionRepo, inventoryRepo, lowStockNotifier)
+	productionHandler := handler.NewProductionHandler(productionService, logger)
+
+	// Pricing rule time windows are matched in the time zone of the cafe
+	cafeLocation, err := config.GetCafeLocation()
+	if err != nil {
+		logger.Error("Invalid cafe time zone, the server time zone is used", "error", err)
+	}
+
+	// Category
+	categoryRepo := repository.NewCategoryRepository(db)
+	categoryService := service.NewCategoryService(categoryRepo)
+	categoryHandler := handler.NewCategoryHandler(categoryService, logger)
+
 	// Menu
-	menuRepo := repository.NewMenuRepository(db)
-	menuService := service.NewMenuService(menuRepo, inventoryRepo)
+	menuRepo := repository.NewMenuRepository(db, cafeLocation)
+	menuService := service.NewMenuService(menuRepo, inventoryRepo, categoryRepo)
 	menuHandler := handler.NewMenuHandler(menuService, logger)
 
+	// Pricing
+	pricingRepo := repository.NewPricingRepository(db)
+	pricingService := service.NewPricingService(pricingRepo, categoryRepo)
+	pricingHandler := handler.NewPricingHandler(pricingService, logger)
+
+	// Scheduled prices are applied in the background unless the scheduler is disabled
+	if interval, err := config.GetPriceSchedulerInterval(); err != nil {
+		logger.Error("Invalid price scheduler interval, scheduled prices are not applied", "error", err)
+	} else if interval > 0 {
+		go applyScheduledPrices(pricingService, interval, logger)
+	}
+
+	// Promo codes
+	promoRepo := repository.NewPromoRepository(db)
+	promoService := service.NewPromoService(promoRepo, menuRepo, categoryRepo)
+	promoHandler := handler.NewPromoHandler(promoService, logger)
+
 	// Order
-	orderRepo := repository.NewOrderRepository(db)
-	orderService := service.NewOrderService(orderRepo, menuRepo, inventoryRepo)
+	orderRepo := repository.NewOrderRepository(db, cafeLocation)
+	orderService := service.NewOrderService(orderRepo, menuRepo, inventoryRepo, lowStockNotifier)
 	orderHandler := handler.NewOrderHandler(orderService, menuService, logger)
 
+	// Waste
+	wasteRepo := repository.NewWasteRepository(db)
+	wasteService := service.NewWasteService(wasteRepo, menuRepo, inventoryRepo)
+	wasteHandler := handler.NewWasteHandler(wasteService, logger)
+
+	// Expired lots are written off in the background when an interval is configured
+	if interval, err := config.GetExpiredWriteOffInterval(); err != nil {
+		logger.Error("Invalid expired write-off interval, automatic write-off is disabled", "error", err)
+	} else if interval > 0 {
+		go writeOffExpiredLots(wasteService, interval, logger)
+	}
+
+	// Stocktake
+	stocktakeRepo := repository.NewStocktakeRepository(db)
+	stocktakeService := service.NewStocktakeService(stocktakeRepo, inventoryRepo)
+	stocktakeHandler := handler.NewStocktakeHandler(stocktakeService, logger)
+
+	// Supplier
+	supplierRepo := repository.NewSupplierRepository(db)
+	supplierService := service.NewSupplierService(supplierRepo)
+	supplierHandler := handler.NewSupplierHandler(supplierService, logger)
+
+	// Purchase order
+	purchaseOrderRepo := repository.NewPurchaseOrderRepository(db)
+	purchaseOrderService := service.NewPurchaseOrderService(purchaseOrderRepo, supplierRepo, inventoryRepo)
+	purchaseOrderHandler := handler.NewPurchaseOrderHandler(purchaseOrderService, logger)
+
 	// Aggregation
 	aggregationRepo := repository.NewReportRespository(db)
 	aggregationService := service.NewAggregationService(aggregationRepo)
@@ -38,14 +106,56 @@ func setupRoutes(router *http.ServeMux, db *sql.DB, logger *slog.Logger) {
 	router.HandleFunc("PUT /inventory/{id}", inventoryHandler.PutInventoryItem)
 	router.HandleFunc("DELETE /inventory/{id}", inventoryHandler.DeleteInventoryItem)
 	router.HandleFunc("GET /inventory/getLeftOvers", inventoryHandler.GetLeftOvers)
+	router.HandleFunc("GET /inventory/low-stock", inventoryHandler.GetLowStock)
+	router.HandleFunc("GET /inventory/valuation", inventoryHandler.GetStockValuation)
+	router.HandleFunc("GET /inventory/transactions", inventoryHandler.GetTransactions)
+	router.HandleFunc("POST /inventory/waste", wasteHandler.PostWaste)
+	router.HandleFunc("GET /inventory/expiring", inventoryHandler.GetExpiringLots)
+	router.HandleFunc("POST /inventory/expiring/write-off", wasteHandler.PostExpiredWriteOff)
+	router.HandleFunc("GET /inventory/{id}/lots", inventoryHandler.GetLots)
+	router.HandleFunc("GET /inventory/{id}/recipe", productionHandler.GetRecipe)
+	router.HandleFunc("PUT /inventory/{id}/recipe", productionHandler.PutRecipe)
+	router.HandleFunc("DELETE /inventory/{id}/recipe", productionHandler.DeleteRecipe)
+	router.HandleFunc("POST /inventory/{id}/produce", productionHandler.PostProduce)
+	router.HandleFunc("GET /inventory/{id}/transactions", inventoryHandler.GetIngredientTransactions)
+	router.HandleFunc("GET /inventory/{id}/conversions", inventoryHandler.GetConversions)
+	router.HandleFunc("POST /inventory/{id}/conversions", inventoryHandler.PostConversion)
+	router.HandleFunc("DELETE /inventory/{id}/conversions/{unit}", inventoryHandler.DeleteConversion)
 
 	// Menu Routes
 	router.HandleFunc("POST /menu", menuHandler.PostMenuItem)
 	router.HandleFunc("GET /menu", menuHandler.GetMenuItems)
+	router.HandleFunc("GET /menu/grouped", menuHandler.GetGroupedMenu)
 	router.HandleFunc("GET /menu/{id}", menuHandler.GetMenuItem)
+	router.HandleFunc("GET /menu/{id}/cost", menuHandler.GetMenuItemCost)
+	router.HandleFunc("GET /menu/{id}/nutrition", menuHandler.GetMenuItemNutrition)
+	router.HandleFunc("GET /menu/{id}/price-history", pricingHandler.GetPriceHistory)
+	router.HandleFunc("POST /menu/{id}/scheduled-prices", pricingHandler.PostScheduledPrice)
+	router.HandleFunc("GET /menu/scheduled-prices", pricingHandler.GetScheduledPrices)
+	router.HandleFunc("DELETE /menu/scheduled-prices/{id}", pricingHandler.DeleteScheduledPrice)
 	router.HandleFunc("PUT /menu/{id}", menuHandler.PutMenuItem)
 	router.HandleFunc("DELETE /menu/{id}", menuHandler.DeleteMenuItem)
 
+	// Pricing rules Routes
+	router.HandleFunc("GET /pricing-rules", pricingHandler.GetRules)
+	router.HandleFunc("POST /pricing-rules", pricingHandler.PostRule)
+	router.HandleFunc("PUT /pricing-rules/{id}", pricingHandler.PutRule)
+	router.HandleFunc("DELETE /pricing-rules/{id}", pricingHandler.DeleteRule)
+
+	// Promo code Routes
+	router.HandleFunc("GET /promo-codes", promoHandler.GetPromoCodes)
+	router.HandleFunc("POST /promo-codes", promoHandler.PostPromoCode)
+	router.HandleFunc("GET /promo-codes/{id}", promoHandler.GetPromoCode)
+	router.HandleFunc("PUT /promo-codes/{id}", promoHandler.PutPromoCode)
+	router.HandleFunc("DELETE /promo-codes/{id}", promoHandler.DeletePromoCode)
+
+	// Category Routes
+	router.HandleFunc("POST /categories", categoryHandler.PostCategory)
+	router.HandleFunc("GET /categories", categoryHandler.GetCategories)
+	router.HandleFunc("GET /categories/{id}", categoryHandler.GetCategory)
+	router.HandleFunc("PUT /categories/{id}", categoryHandler.PutCategory)
+	router.HandleFunc("DELETE /categories/{id}", categoryHandler.DeleteCategory)
+
 	// Order routes
 	router.HandleFunc("POST /orders", orderHandler.PostOrder)
 	router.HandleFunc("GET /orders", orderHandler.GetOrders)
@@ -53,12 +163,43 @@ func setupRoutes(router *http.ServeMux, db *sql.DB, logger *slog.Logger) {
 	router.HandleFunc("PUT /orders/{id}", orderHandler.PutOrder)
 	router.HandleFunc("DELETE /orders/{id}", orderHandler.DeleteOrder)
 	router.HandleFunc("POST /orders/{id}/close", orderHandler.CloseOrder)
+	router.HandleFunc("POST /orders/{id}/transition", orderHandler.TransitionOrder)
+	router.HandleFunc("POST /orders/{id}/cancel", orderHandler.CancelOrder)
+	router.HandleFunc("GET /orders/{id}/history", orderHandler.GetOrderStatusHistory)
 	router.HandleFunc("GET /orders/numberOfOrderedItems", orderHandler.GetNumberOfOrdered)
 	router.HandleFunc("POST /orders/batch-process", orderHandler.BatchOrders)
 
+	// Stocktake routes
+	router.HandleFunc("POST /stocktakes", stocktakeHandler.PostStocktake)
+	router.HandleFunc("GET /stocktakes", stocktakeHandler.GetStocktakes)
+	router.HandleFunc("GET /stocktakes/{id}", stocktakeHandler.GetStocktake)
+	router.HandleFunc("PUT /stocktakes/{id}/counts", stocktakeHandler.PutCounts)
+	router.HandleFunc("GET /stocktakes/{id}/variance", stocktakeHandler.GetVariance)
+	router.HandleFunc("POST /stocktakes/{id}/commit", stocktakeHandler.CommitStocktake)
+
+	// Supplier routes
+	router.HandleFunc("POST /suppliers", supplierHandler.PostSupplier)
+	router.HandleFunc("GET /suppliers", supplierHandler.GetSuppliers)
+	router.HandleFunc("GET /suppliers/{id}", supplierHandler.GetSupplier)
+	router.HandleFunc("PUT /suppliers/{id}", supplierHandler.PutSupplier)
+	router.HandleFunc("DELETE /suppliers/{id}", supplierHandler.DeleteSupplier)
+
+	// Purchase order routes
+	router.HandleFunc("POST /purchase-orders", purchaseOrderHandler.PostPurchaseOrder)
+	router.HandleFunc("GET /purchase-orders", purchaseOrderHandler.GetPurchaseOrders)
+	router.HandleFunc("GET /purchase-orders/{id}", purchaseOrderHandler.GetPurchaseOrder)
+	router.HandleFunc("PUT /purchase-orders/{id}", purchaseOrderHandler.PutPurchaseOrder)
+	router.HandleFunc("POST /purchase-orders/{id}/send", purchaseOrderHandler.SendPurchaseOrder)
+	router.HandleFunc("POST /purchase-orders/{id}/receive", purchaseOrderHandler.ReceivePurchaseOrder)
+
 	// Report routes
 	router.HandleFunc("GET /reports/total-sales", aggregationHandler.TotalSalesHandler)
 	router.HandleFunc("GET /reports/popular-items", aggregationHandler.PopularItemsHandler)
+	router.HandleFunc("GET /reports/sales-by-category", aggregationHandler.SalesByCategoryHandler)
+	router.HandleFunc("GET /reports/margins", aggregationHandler.MarginsHandler)
+	router.HandleFunc("GET /reports/waste", wasteHandler.WasteReportHandler)
+	router.HandleFunc("GET /reports/usage-variance", aggregationHandler.UsageVarianceHandler)
+	router.HandleFunc("GET /reports/discounts", aggregationHandler.DiscountsHandler)
 	router.HandleFunc("GET /reports/orderedItemsByPeriod", aggregationHandler.OrderByPeriod)
 	router.HandleFunc("GET /reports/search", aggregationHandler.SearchHandler)
 }
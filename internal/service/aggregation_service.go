@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/sunzhqr/frappuccino/internal/models"
 	"github.com/sunzhqr/frappuccino/internal/repository"
@@ -12,11 +13,18 @@ var (
 	ErrWrongFilterOptions = errors.New("no such filter. Available filters: orders, menu, all")
 	ErrSearchRequired     = errors.New("search query string is required")
 	ErrPriceNotPositive   = errors.New("minPrice and maxPrice must be postive")
+	ErrInvalidDateRange   = errors.New("from and to must be dates in format YYYY-MM-DD, from not after to")
+	ErrInvalidGroupBy     = errors.New("no such grouping. Available groupings: day, week, month")
 )
 
 type AggregationServiceInterface interface {
+	GetTotalSales(from, to, status, groupBy string) (models.TotalSales, error)
 	GetPopularMenuItems() (models.PopularItems, error)
-	Search(searchQuery string, minPrice, maxPrice int, filter string) (models.SearchResult, error)
+	GetSalesByCategory(from, to string) ([]models.CategorySales, error)
+	GetMargins(from, to string) ([]models.ItemMargin, error)
+	GetUsageVariance(from, to string) ([]models.UsageVariance, error)
+	GetDiscounts(from, to string) (models.DiscountReport, error)
+	Search(searchQuery string, minPrice, maxPrice int, filter, excludeAllergens string) (models.SearchResult, error)
 }
 
 type AggregationService struct {
@@ -27,6 +35,37 @@ func NewAggregationService(searchRepo repository.ReportRespositoryInterface) *Ag
 	return &AggregationService{searchRepo: searchRepo}
 }
 
+// GetTotalSales returns revenue for orders created between from and to (both inclusive).
+// status is a comma separated list of order statuses, groupBy is one of day, week, month.
+func (s *AggregationService) GetTotalSales(from, to, status, groupBy string) (models.TotalSales, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return models.TotalSales{}, err
+	}
+
+	if groupBy != "" && groupBy != "day" && groupBy != "week" && groupBy != "month" {
+		return models.TotalSales{}, ErrInvalidGroupBy
+	}
+
+	var statuses []string
+	if status != "" {
+		for _, v := range strings.Split(status, ",") {
+			v = strings.TrimSpace(v)
+			if !models.IsOrderStatus(v) {
+				return models.TotalSales{}, ErrUnknownOrderStatus
+			}
+			statuses = append(statuses, v)
+		}
+	}
+
+	return s.searchRepo.GetSales(models.SalesFilter{
+		From:     start,
+		To:       end,
+		Statuses: statuses,
+		GroupBy:  groupBy,
+	})
+}
+
 func (s *AggregationService) GetPopularMenuItems() (models.PopularItems, error) {
 	popItms, err := s.searchRepo.GetPopularMenuItems()
 	res := models.PopularItems{
@@ -35,7 +74,45 @@ func (s *AggregationService) GetPopularMenuItems() (models.PopularItems, error)
 	return res, err
 }
 
-func (s *AggregationService) Search(searchQuery string, minPrice, maxPrice int, filter string) (models.SearchResult, error) {
+// GetSalesByCategory returns sales per category for orders created between from and to (both inclusive)
+func (s *AggregationService) GetSalesByCategory(from, to string) ([]models.CategorySales, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchRepo.GetSalesByCategory(start, end)
+}
+
+// GetMargins ranks menu items by contribution margin for orders created between from and to (both inclusive)
+func (s *AggregationService) GetMargins(from, to string) ([]models.ItemMargin, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchRepo.GetMargins(start, end)
+}
+
+// GetUsageVariance compares theoretical and actual ingredient usage between from and to (both inclusive)
+func (s *AggregationService) GetUsageVariance(from, to string) ([]models.UsageVariance, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchRepo.GetUsageVariance(start, end)
+}
+
+// GetDiscounts reports promo code discounts for orders created between from and to (both inclusive)
+func (s *AggregationService) GetDiscounts(from, to string) (models.DiscountReport, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return models.DiscountReport{}, err
+	}
+	return s.searchRepo.GetDiscounts(start, end)
+}
+
+// Search looks for menu items and orders. excludeAllergens is a comma separated list of allergens
+// the found menu items must not contain.
+func (s *AggregationService) Search(searchQuery string, minPrice, maxPrice int, filter, excludeAllergens string) (models.SearchResult, error) {
 	var err error
 
 	if searchQuery == "" {
@@ -50,9 +127,16 @@ func (s *AggregationService) Search(searchQuery string, minPrice, maxPrice int,
 		return models.SearchResult{}, err
 	}
 
+	var allergens []string
+	if excludeAllergens != "" {
+		if allergens, err = normalizeAllergens(strings.Split(excludeAllergens, ",")); err != nil {
+			return models.SearchResult{}, err
+		}
+	}
+
 	var menuItems []models.SearchMenuItem
 	if isMenu {
-		menuItems, err = s.searchRepo.SearchMenuItems(searchQuery, minPrice, maxPrice)
+		menuItems, err = s.searchRepo.SearchMenuItems(searchQuery, minPrice, maxPrice, allergens)
 		if err != nil {
 			return models.SearchResult{}, err
 		}
@@ -99,3 +183,27 @@ func validateSearchFilters(filter string) (bool, bool, error) {
 	}
 	return isOrders, isMenu, nil
 }
+
+// parseDateRange parses optional YYYY-MM-DD bounds. The returned end is exclusive:
+// it points to the start of the day after to.
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		start, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDateRange
+		}
+	}
+	if to != "" {
+		end, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDateRange
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return time.Time{}, time.Time{}, ErrInvalidDateRange
+	}
+	return start, end, nil
+}
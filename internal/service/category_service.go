@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrCategoryNameRequired = errors.New("category name is required")
+	ErrCategoryCycle        = errors.New("category can not be nested into itself or its subcategory")
+)
+
+type CategoryServiceInterface interface {
+	GetCategories() ([]models.Category, error)
+	GetCategory(id int) (models.Category, error)
+	AddCategory(category models.Category) (models.Category, error)
+	UpdateCategory(category models.Category) error
+	DeleteCategory(id int) error
+}
+
+type CategoryService struct {
+	categoryRepo repository.CategoryRepositoryInterface
+}
+
+func NewCategoryService(categoryRepo repository.CategoryRepositoryInterface) *CategoryService {
+	return &CategoryService{categoryRepo: categoryRepo}
+}
+
+func (s *CategoryService) GetCategories() ([]models.Category, error) {
+	return s.categoryRepo.GetAll()
+}
+
+func (s *CategoryService) GetCategory(id int) (models.Category, error) {
+	return s.categoryRepo.GetByID(id)
+}
+
+func (s *CategoryService) AddCategory(category models.Category) (models.Category, error) {
+	if err := s.validateCategory(category); err != nil {
+		return models.Category{}, err
+	}
+	category.Name = strings.TrimSpace(category.Name)
+
+	id, err := s.categoryRepo.Add(category)
+	if err != nil {
+		return models.Category{}, err
+	}
+	category.ID = id
+	return category, nil
+}
+
+func (s *CategoryService) UpdateCategory(category models.Category) error {
+	if _, err := s.categoryRepo.GetByID(category.ID); err != nil {
+		return err
+	}
+	if err := s.validateCategory(category); err != nil {
+		return err
+	}
+
+	// Parent must not be the category itself or one of its subcategories
+	if category.ParentID != 0 {
+		subtree, err := s.categoryRepo.GetDescendantIDs(category.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range subtree {
+			if id == category.ParentID {
+				return ErrCategoryCycle
+			}
+		}
+	}
+	category.Name = strings.TrimSpace(category.Name)
+	return s.categoryRepo.Update(category)
+}
+
+func (s *CategoryService) DeleteCategory(id int) error {
+	if _, err := s.categoryRepo.GetByID(id); err != nil {
+		return err
+	}
+	return s.categoryRepo.Delete(id)
+}
+
+func (s *CategoryService) validateCategory(category models.Category) error {
+	if strings.TrimSpace(category.Name) == "" {
+		return ErrCategoryNameRequired
+	}
+	if category.ParentID != 0 {
+		if _, err := s.categoryRepo.GetByID(category.ParentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,61 +2,121 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/notifier"
 	"github.com/sunzhqr/frappuccino/internal/repository"
 )
 
+var (
+	ErrUnknownInventoryReason = errors.New("unknown inventory reason")
+	ErrInvalidPagination      = errors.New("page and pageSize must be positive integers")
+	ErrInvalidWithin          = errors.New("within must be a non negative duration like 48h or 90m")
+)
+
 type InventoryServiceInterface interface {
 	AddInventoryItem(item models.InventoryItem) error
-	GetAllInventoryItems() ([]models.InventoryItem, error)
-	GetItem(id int) (models.InventoryItem, error)
+	GetAllInventoryItems(unit string) ([]models.InventoryItem, error)
+	GetItem(id int, unit string) (models.InventoryItem, error)
 	UpdateItem(id int, newItem models.InventoryItem) error
 	DeleteItem(id int) error
 	Exists(id int) bool
 	GetLeftOvers(sortBy, page, pageSize string) (map[string]any, error)
+	GetLowStock() ([]models.LowStockAlert, error)
+	GetStockValuation() (models.StockValuation, error)
+	GetTransactions(ingredientID int, from, to, reason, page, pageSize string) (models.TransactionPage, error)
+	GetConversions(ingredientID int) ([]models.UnitConversion, error)
+	SaveConversion(conversion models.UnitConversion) error
+	DeleteConversion(ingredientID int, unit string) error
+	GetLots(ingredientID int) ([]models.InventoryLot, error)
+	GetExpiringLots(within string) ([]models.InventoryLot, error)
 }
 
 type InventoryService struct {
 	inventoryRepository repository.InventoryRepositoryInterface
+	notifier            notifier.Notifier
 }
 
-func NewInventoryService(inventoryRepository repository.InventoryRepositoryInterface) *InventoryService {
-	return &InventoryService{inventoryRepository: inventoryRepository}
+func NewInventoryService(inventoryRepository repository.InventoryRepositoryInterface, notifier notifier.Notifier) *InventoryService {
+	return &InventoryService{inventoryRepository: inventoryRepository, notifier: notifier}
 }
 
 func (s *InventoryService) AddInventoryItem(item models.InventoryItem) error {
 	return s.inventoryRepository.AddInventoryItemRepo(item)
 }
 
-func (s *InventoryService) GetAllInventoryItems() ([]models.InventoryItem, error) {
+// GetAllInventoryItems returns the inventory. A non empty unit shows the items that can be
+// expressed in it in that unit, the others stay in their stock unit.
+func (s *InventoryService) GetAllInventoryItems(unit string) ([]models.InventoryItem, error) {
 	items, err := s.inventoryRepository.GetAll()
 	if err != nil {
 		return nil, err
 	}
+	if unit == "" {
+		return items, nil
+	}
+	if !models.IsStandardUnit(unit) {
+		return nil, fmt.Errorf("%w: %q", models.ErrUnknownUnit, unit)
+	}
+
+	for i := range items {
+		if converted, err := displayInUnit(items[i], unit, nil); err == nil {
+			items[i] = converted
+		}
+	}
 	return items, nil
 }
 
-func (s *InventoryService) GetItem(id int) (models.InventoryItem, error) {
-	inventoryItems, err := s.inventoryRepository.GetAll()
+// GetItem returns the inventory item, in unit when given
+func (s *InventoryService) GetItem(id int, unit string) (models.InventoryItem, error) {
+	inventoryItems, err := s.inventoryRepository.GetByIDs([]int{id})
 	if err != nil {
 		return models.InventoryItem{}, err
 	}
+	if len(inventoryItems) == 0 {
+		return models.InventoryItem{}, errors.New("inventory item does not exist")
+	}
+	if unit == "" {
+		return inventoryItems[0], nil
+	}
 
-	for _, inventoryItem := range inventoryItems {
-		if inventoryItem.IngredientID == id {
-			return inventoryItem, nil
-		}
+	conversions, err := s.inventoryRepository.GetConversions(id)
+	if err != nil {
+		return models.InventoryItem{}, err
 	}
-	return models.InventoryItem{}, errors.New("inventory item does not exist")
+	return displayInUnit(inventoryItems[0], unit, conversions)
 }
 
 func (s *InventoryService) UpdateItem(id int, newItem models.InventoryItem) error {
-	if !s.inventoryRepository.Exists(id) {
+	previous, err := s.inventoryRepository.GetByIDs([]int{id})
+	if err != nil {
+		return err
+	}
+	if len(previous) == 0 {
 		return errors.New("inventory item does not exist")
 	}
-	return s.inventoryRepository.UpdateItemRepo(id, newItem)
+	// Unit cost is maintained by purchase receipts, keep it unless set explicitly
+	if newItem.UnitCost == 0 {
+		newItem.UnitCost = previous[0].UnitCost
+	}
+	// Same for nutrition, which is usually set once per ingredient
+	if newItem.Nutrition == (models.Nutrition{}) {
+		newItem.Nutrition = previous[0].Nutrition
+	}
+	if err := s.inventoryRepository.UpdateItemRepo(id, newItem); err != nil {
+		return err
+	}
+
+	newItem.IngredientID = id
+	if previous[0].Quantity > newItem.ReorderLevel && newItem.IsLowStock() {
+		s.notifier.NotifyLowStock([]models.LowStockAlert{models.NewLowStockAlert(newItem)})
+	}
+	return nil
 }
 
 func (s *InventoryService) DeleteItem(id int) error {
@@ -96,3 +156,170 @@ func (s *InventoryService) GetLeftOvers(sortBy, page, pageSize string) (map[stri
 
 	return s.inventoryRepository.GetLeftOvers(sortBy, page, pageSize)
 }
+
+func (s *InventoryService) GetLowStock() ([]models.LowStockAlert, error) {
+	items, err := s.inventoryRepository.GetLowStock()
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []models.LowStockAlert{}
+	for _, item := range items {
+		alerts = append(alerts, models.NewLowStockAlert(item))
+	}
+	return alerts, nil
+}
+
+// GetStockValuation values every ingredient on hand at its weighted average unit cost
+func (s *InventoryService) GetStockValuation() (models.StockValuation, error) {
+	items, err := s.inventoryRepository.GetAll()
+	if err != nil {
+		return models.StockValuation{}, err
+	}
+
+	valuation := models.StockValuation{Items: []models.StockValue{}}
+	for _, item := range items {
+		value := math.Round(item.Quantity*item.UnitCost*100) / 100
+		valuation.Items = append(valuation.Items, models.StockValue{
+			IngredientID: item.IngredientID,
+			Name:         item.Name,
+			Quantity:     item.Quantity,
+			Unit:         item.Unit,
+			UnitCost:     item.UnitCost,
+			Value:        value,
+		})
+		valuation.TotalValue += value
+	}
+	valuation.TotalValue = math.Round(valuation.TotalValue*100) / 100
+	return valuation, nil
+}
+
+// GetTransactions returns a page of the inventory ledger. ingredientID 0 means every ingredient,
+// from and to are inclusive YYYY-MM-DD dates and reason is a comma separated list of reasons.
+func (s *InventoryService) GetTransactions(ingredientID int, from, to, reason, page, pageSize string) (models.TransactionPage, error) {
+	if ingredientID != 0 && !s.inventoryRepository.Exists(ingredientID) {
+		return models.TransactionPage{}, errors.New("inventory item does not exist")
+	}
+
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return models.TransactionPage{}, err
+	}
+	filter := models.TransactionFilter{
+		IngredientID: ingredientID,
+		From:         start,
+		To:           end,
+		Page:         1,
+		PageSize:     20,
+	}
+
+	if reason != "" {
+		for _, v := range strings.Split(reason, ",") {
+			v = strings.TrimSpace(v)
+			if !models.IsInventoryReason(v) {
+				return models.TransactionPage{}, fmt.Errorf("%w: %q", ErrUnknownInventoryReason, v)
+			}
+			filter.Reasons = append(filter.Reasons, v)
+		}
+	}
+
+	if page != "" {
+		if filter.Page, err = strconv.Atoi(page); err != nil || filter.Page <= 0 {
+			return models.TransactionPage{}, ErrInvalidPagination
+		}
+	}
+	if pageSize != "" {
+		if filter.PageSize, err = strconv.Atoi(pageSize); err != nil || filter.PageSize <= 0 {
+			return models.TransactionPage{}, ErrInvalidPagination
+		}
+	}
+
+	return s.inventoryRepository.GetTransactions(filter)
+}
+
+func (s *InventoryService) GetConversions(ingredientID int) ([]models.UnitConversion, error) {
+	return s.inventoryRepository.GetConversions(ingredientID)
+}
+
+// SaveConversion defines a custom unit of the ingredient, e.g. 1 bag = 1000 g.
+// The target unit must be a standard unit compatible with the ingredient stock unit.
+func (s *InventoryService) SaveConversion(conversion models.UnitConversion) error {
+	items, err := s.inventoryRepository.GetByIDs([]int{conversion.IngredientID})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errors.New("inventory item does not exist")
+	}
+
+	conversion.Unit = strings.TrimSpace(conversion.Unit)
+	if conversion.Unit == "" || len(conversion.Unit) > 20 {
+		return fmt.Errorf("%w: unit name is required and must be at most 20 characters", ErrInvalidConversion)
+	}
+	if models.IsStandardUnit(conversion.Unit) {
+		return fmt.Errorf("%w: %q is a standard unit", ErrInvalidConversion, conversion.Unit)
+	}
+	if conversion.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidConversion)
+	}
+	if _, err := models.ConvertUnits(conversion.Quantity, conversion.ToUnit, items[0].Unit); err != nil {
+		return err
+	}
+	return s.inventoryRepository.SaveConversion(conversion)
+}
+
+func (s *InventoryService) DeleteConversion(ingredientID int, unit string) error {
+	deleted, err := s.inventoryRepository.DeleteConversion(ingredientID, unit)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrConversionNotFound
+	}
+	return nil
+}
+
+func (s *InventoryService) GetLots(ingredientID int) ([]models.InventoryLot, error) {
+	return s.inventoryRepository.GetLots(ingredientID)
+}
+
+// GetExpiringLots returns the lots with stock expiring within the given duration (48h by default),
+// lots that are already expired are included
+func (s *InventoryService) GetExpiringLots(within string) ([]models.InventoryLot, error) {
+	if within == "" {
+		within = "48h"
+	}
+	duration, err := time.ParseDuration(within)
+	if err != nil || duration < 0 {
+		return nil, ErrInvalidWithin
+	}
+	return s.inventoryRepository.GetExpiringLots(time.Now().Add(duration))
+}
+
+// notifyLowStock alerts about ingredients which were above their reorder level
+// before a stock change and are at or below it now. previous maps ingredient ID to the old quantity.
+func notifyLowStock(n notifier.Notifier, inventoryRepo repository.InventoryRepositoryInterface, previous map[int]float64) error {
+	if len(previous) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(previous))
+	for id := range previous {
+		ids = append(ids, id)
+	}
+
+	current, err := inventoryRepo.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+
+	var alerts []models.LowStockAlert
+	for _, item := range current {
+		if previous[item.IngredientID] > item.ReorderLevel && item.IsLowStock() {
+			alerts = append(alerts, models.NewLowStockAlert(item))
+		}
+	}
+	if len(alerts) > 0 {
+		n.NotifyLowStock(alerts)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/sunzhqr/frappuccino/internal/models"
@@ -11,7 +12,8 @@ import (
 type MenuServiceInterface interface {
 	AddMenuItem(menuItem models.MenuItem) error
 	GetMenuItem(MenuItemID int) (models.MenuItem, error)
-	GetMenuItems() ([]models.MenuItem, error)
+	GetMenuItems(filter models.MenuFilter) ([]models.MenuItem, error)
+	GetGroupedMenu() (models.GroupedMenu, error)
 	CheckNewMenu(MenuItem models.MenuItem) error
 	DeleteMenuItem(MenuItemID int) error
 	UpdateMenuItem(menuItem models.MenuItem) error
@@ -19,15 +21,19 @@ type MenuServiceInterface interface {
 	IngredientsCheckByID(menuItemID int, quantity int) error
 	IngredientsCheckForNewItem(menuItem models.MenuItem) error
 	SubtractIngredientsByID(OrderID int, quantity int) error
+	GetMenuItemCost(MenuItemID int) (models.MenuItemCost, error)
+	GetMenuItemNutrition(MenuItemID int) (models.MenuItemNutrition, error)
+	ConvertRecipeUnits(menuItem models.MenuItem) (models.MenuItem, error)
 }
 
 type MenuService struct {
 	menuRepo      repository.MenuRepositoryInterface
 	inventoryRepo repository.InventoryRepositoryInterface
+	categoryRepo  repository.CategoryRepositoryInterface
 }
 
-func NewMenuService(menuRepo repository.MenuRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface) *MenuService {
-	return &MenuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo}
+func NewMenuService(menuRepo repository.MenuRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface, categoryRepo repository.CategoryRepositoryInterface) *MenuService {
+	return &MenuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo, categoryRepo: categoryRepo}
 }
 
 func (s *MenuService) DeleteMenuItem(MenuItemID int) error {
@@ -35,9 +41,82 @@ func (s *MenuService) DeleteMenuItem(MenuItemID int) error {
 }
 
 func (s *MenuService) UpdateMenuItem(menuItem models.MenuItem) error {
+	setDefaultMultipliers(menuItem.Variants)
+	setDefaultSlotQuantities(menuItem.BundleSlots)
 	return s.menuRepo.UpdateMenuItemRepo(menuItem)
 }
 
+// ConvertRecipeUnits converts recipe quantities given with a unit to the stock units of the ingredients,
+// for the base recipe, the variants and the modifiers. Collections left out stay nil, so an update keeps them.
+func (s *MenuService) ConvertRecipeUnits(menuItem models.MenuItem) (models.MenuItem, error) {
+	converter := newStockConverter(s.inventoryRepo)
+	convert := func(ingredients []models.MenuItemIngredient) ([]models.MenuItemIngredient, error) {
+		if ingredients == nil {
+			return nil, nil
+		}
+		converted := make([]models.MenuItemIngredient, len(ingredients))
+		for i, ingredient := range ingredients {
+			quantity, err := converter.toStock(ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = models.MenuItemIngredient{IngredientID: ingredient.IngredientID, Quantity: quantity}
+		}
+		return converted, nil
+	}
+
+	var err error
+	if menuItem.Ingredients, err = convert(menuItem.Ingredients); err != nil {
+		return models.MenuItem{}, err
+	}
+	if menuItem.Variants != nil {
+		variants := make([]models.MenuItemVariant, len(menuItem.Variants))
+		for i, variant := range menuItem.Variants {
+			if variant.Ingredients, err = convert(variant.Ingredients); err != nil {
+				return models.MenuItem{}, err
+			}
+			variants[i] = variant
+		}
+		menuItem.Variants = variants
+	}
+
+	if menuItem.ModifierGroups == nil {
+		return menuItem, nil
+	}
+	groups := make([]models.ModifierGroup, len(menuItem.ModifierGroups))
+	for i, group := range menuItem.ModifierGroups {
+		modifiers := make([]models.Modifier, len(group.Modifiers))
+		for j, modifier := range group.Modifiers {
+			if modifier.Ingredients, err = convert(modifier.Ingredients); err != nil {
+				return models.MenuItem{}, err
+			}
+			modifiers[j] = modifier
+		}
+		group.Modifiers = modifiers
+		groups[i] = group
+	}
+	menuItem.ModifierGroups = groups
+	return menuItem, nil
+}
+
+// setDefaultMultipliers makes variants without a recipe multiplier use the base recipe as is
+func setDefaultMultipliers(variants []models.MenuItemVariant) {
+	for i := range variants {
+		if variants[i].RecipeMultiplier == 0 {
+			variants[i].RecipeMultiplier = 1
+		}
+	}
+}
+
+// setDefaultSlotQuantities makes bundle slots without a quantity take one component
+func setDefaultSlotQuantities(slots []models.BundleSlot) {
+	for i := range slots {
+		if slots[i].Quantity == 0 {
+			slots[i].Quantity = 1
+		}
+	}
+}
+
 func (s *MenuService) MenuCheckByID(MenuItemID int, isDelete bool) error {
 	if isDelete {
 		flag := false
@@ -57,31 +136,17 @@ func (s *MenuService) MenuCheckByID(MenuItemID int, isDelete bool) error {
 }
 
 func (s *MenuService) IngredientsCheckByID(menuItemID int, quantity int) error {
-	menuItems, _ := s.menuRepo.GetAll()
-	ingredientsNeeded := make(map[int]float64)
-	flag := false
-	for _, item := range menuItems {
-		if item.ID == menuItemID {
-			for _, ingr := range item.Ingredients {
-				ingredientsNeeded[ingr.IngredientID] += float64(ingr.Quantity) * float64(quantity)
-			}
-		}
+	servings, err := s.menuRepo.GetAvailableServings(menuItemID)
+	if err != nil {
+		return err
 	}
-
-	inventoryItems, _ := s.inventoryRepo.GetAll()
-
-	for _, inventoryItem := range inventoryItems {
-		if value, exists := ingredientsNeeded[inventoryItem.IngredientID]; exists {
-			flag = true
-			if value > inventoryItem.Quantity {
-				return errors.New("not enough ingredients for item")
-			}
-		}
+	if servings == nil {
+		return errors.New("no ingredients for item in inventory")
 	}
-	if flag {
-		return nil
+	if *servings < quantity {
+		return errors.New("not enough ingredients for item")
 	}
-	return errors.New("no ingredients for item in inventory")
+	return nil
 }
 
 func (s *MenuService) IngredientsCheckForNewItem(menuItem models.MenuItem) error {
@@ -100,6 +165,22 @@ func (s *MenuService) IngredientsCheckForNewItem(menuItem models.MenuItem) error
 	if count != len(menuItem.Ingredients) {
 		return errors.New("no ingredients for item in inventory")
 	}
+	for _, variant := range menuItem.Variants {
+		for _, ingredient := range variant.Ingredients {
+			if !s.inventoryRepo.Exists(ingredient.IngredientID) {
+				return errors.New("no ingredients for variant in inventory")
+			}
+		}
+	}
+	for _, group := range menuItem.ModifierGroups {
+		for _, modifier := range group.Modifiers {
+			for _, ingredient := range modifier.Ingredients {
+				if !s.inventoryRepo.Exists(ingredient.IngredientID) {
+					return errors.New("no ingredients for modifier in inventory")
+				}
+			}
+		}
+	}
 	return nil
 }
 
@@ -123,6 +204,8 @@ func (s *MenuService) SubtractIngredientsByID(OrderID int, quantity int) error {
 }
 
 func (s *MenuService) AddMenuItem(menuItem models.MenuItem) error {
+	setDefaultMultipliers(menuItem.Variants)
+	setDefaultSlotQuantities(menuItem.BundleSlots)
 	return s.menuRepo.AddMenuItemRepo(menuItem)
 }
 
@@ -139,14 +222,144 @@ func (s *MenuService) GetMenuItem(MenuItemID int) (models.MenuItem, error) {
 	return models.MenuItem{}, errors.New("could not find menu item by the given id")
 }
 
-func (s *MenuService) GetMenuItems() ([]models.MenuItem, error) {
-	MenuItems, err := s.menuRepo.GetAll()
+// GetMenuItemCost returns the food cost of the menu item and its variants with the base recipe priced per ingredient
+func (s *MenuService) GetMenuItemCost(MenuItemID int) (models.MenuItemCost, error) {
+	menuItem, err := s.GetMenuItem(MenuItemID)
+	if err != nil {
+		return models.MenuItemCost{}, err
+	}
+
+	ingredients, err := s.menuRepo.GetIngredientCosts(MenuItemID)
+	if err != nil {
+		return models.MenuItemCost{}, err
+	}
+
+	cost := models.MenuItemCost{
+		ProductID:   menuItem.ID,
+		Name:        menuItem.Name,
+		Price:       menuItem.Price,
+		Costing:     menuItem.Costing,
+		Ingredients: ingredients,
+	}
+	for _, variant := range menuItem.Variants {
+		cost.Variants = append(cost.Variants, models.VariantCost{
+			VariantID: variant.ID,
+			Name:      variant.Name,
+			Price:     variant.Price,
+			Costing:   variant.Costing,
+		})
+	}
+	return cost, nil
+}
+
+// GetMenuItemNutrition returns the per serving nutrition of the menu item with the base recipe broken down
+// per ingredient, the nutrition of each variant and the change each modifier makes to a serving
+func (s *MenuService) GetMenuItemNutrition(MenuItemID int) (models.MenuItemNutrition, error) {
+	menuItem, err := s.GetMenuItem(MenuItemID)
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+
+	ingredients, err := s.menuRepo.GetIngredientNutrition(MenuItemID)
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+
+	nutrition := models.MenuItemNutrition{
+		ProductID:   menuItem.ID,
+		Name:        menuItem.Name,
+		Nutrition:   menuItem.Nutrition,
+		Ingredients: ingredients,
+	}
+	for _, variant := range menuItem.Variants {
+		nutrition.Variants = append(nutrition.Variants, models.VariantNutrition{
+			VariantID: variant.ID,
+			Name:      variant.Name,
+			Nutrition: variant.Nutrition,
+		})
+	}
+	for _, group := range menuItem.ModifierGroups {
+		for _, modifier := range group.Modifiers {
+			nutrition.Modifiers = append(nutrition.Modifiers, models.ModifierNutrition{
+				ModifierID: modifier.ID,
+				Name:       modifier.Name,
+				Delta:      modifier.Nutrition,
+			})
+		}
+	}
+	return nutrition, nil
+}
+
+func (s *MenuService) GetMenuItems(filter models.MenuFilter) ([]models.MenuItem, error) {
+	if filter.CategoryID != 0 {
+		if _, err := s.categoryRepo.GetByID(filter.CategoryID); err != nil {
+			return []models.MenuItem{}, err
+		}
+	}
+	var err error
+	if filter.ExcludeAllergens, err = normalizeAllergens(filter.ExcludeAllergens); err != nil {
+		return []models.MenuItem{}, err
+	}
+	MenuItems, err := s.menuRepo.GetFiltered(filter)
 	if err != nil {
 		return []models.MenuItem{}, err
 	}
 	return MenuItems, err
 }
 
+// GetGroupedMenu returns the menu as a category tree ordered for display
+func (s *MenuService) GetGroupedMenu() (models.GroupedMenu, error) {
+	categories, err := s.categoryRepo.GetAll()
+	if err != nil {
+		return models.GroupedMenu{}, err
+	}
+	menuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return models.GroupedMenu{}, err
+	}
+
+	known := make(map[int]bool)
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+
+	grouped := models.GroupedMenu{}
+	itemsByCategory := make(map[int][]models.MenuItem)
+	for _, item := range menuItems {
+		if known[item.CategoryID] {
+			itemsByCategory[item.CategoryID] = append(itemsByCategory[item.CategoryID], item)
+		} else {
+			grouped.Uncategorized = append(grouped.Uncategorized, item)
+		}
+	}
+
+	// categories are already sorted by display order, so children keep that order too
+	children := make(map[int][]models.Category)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+
+	var build func(parentID int) []models.MenuCategoryGroup
+	build = func(parentID int) []models.MenuCategoryGroup {
+		groups := []models.MenuCategoryGroup{}
+		for _, category := range children[parentID] {
+			items := itemsByCategory[category.ID]
+			if items == nil {
+				items = []models.MenuItem{}
+			}
+			groups = append(groups, models.MenuCategoryGroup{
+				Category:      category,
+				Items:         items,
+				Subcategories: build(category.ID),
+			})
+		}
+		return groups
+	}
+	grouped.Categories = build(0)
+
+	return grouped, nil
+}
+
 func (s *MenuService) CheckNewMenu(MenuItem models.MenuItem) error {
 	// if strings.TrimSpace(MenuItem.ID) == "" {
 	// 	return errors.New("new menu item's ID is empty")
@@ -160,6 +373,11 @@ func (s *MenuService) CheckNewMenu(MenuItem models.MenuItem) error {
 	if MenuItem.Price < 0 {
 		return errors.New("new menu item's Price is awkward")
 	}
+	if MenuItem.CategoryID != 0 {
+		if _, err := s.categoryRepo.GetByID(MenuItem.CategoryID); err != nil {
+			return errors.New("new menu item's category does not exist")
+		}
+	}
 	for _, ingredient := range MenuItem.Ingredients {
 		// if strings.TrimSpace(ingredient.IngredientID) == "" {
 		// 	return errors.New("new menu item's ingredient is empty")
@@ -168,5 +386,103 @@ func (s *MenuService) CheckNewMenu(MenuItem models.MenuItem) error {
 			return errors.New("new menu item's quantity is awkward")
 		}
 	}
+	variantNames := make(map[string]bool)
+	for _, variant := range MenuItem.Variants {
+		if strings.TrimSpace(variant.Name) == "" {
+			return errors.New("variant's Name is empty")
+		}
+		if variantNames[variant.Name] {
+			return errors.New("variant names must be unique")
+		}
+		variantNames[variant.Name] = true
+		if variant.Price <= 0 {
+			return errors.New("variant's Price is awkward")
+		}
+		if variant.RecipeMultiplier < 0 {
+			return errors.New("variant's recipe_multiplier is awkward")
+		}
+		for _, ingredient := range variant.Ingredients {
+			if ingredient.Quantity <= 0 {
+				return errors.New("variant's ingredient quantity is awkward")
+			}
+		}
+	}
+	for _, group := range MenuItem.ModifierGroups {
+		if strings.TrimSpace(group.Name) == "" {
+			return errors.New("modifier group's Name is empty")
+		}
+		if group.MinSelect < 0 || group.MaxSelect < 1 || group.MinSelect > group.MaxSelect {
+			return errors.New("modifier group's min_select and max_select are awkward")
+		}
+		if len(group.Modifiers) < group.MinSelect {
+			return errors.New("modifier group has fewer modifiers than min_select")
+		}
+		for _, modifier := range group.Modifiers {
+			if strings.TrimSpace(modifier.Name) == "" {
+				return errors.New("modifier's Name is empty")
+			}
+			for _, ingredient := range modifier.Ingredients {
+				if ingredient.Quantity == 0 {
+					return errors.New("modifier's ingredient quantity must not be zero")
+				}
+			}
+		}
+	}
+	return s.checkBundleSlots(MenuItem)
+}
+
+// checkBundleSlots checks the slots of a bundle. A bundle takes its recipe from its components,
+// so it has no ingredients or variants of its own, and its components are not bundles themselves.
+func (s *MenuService) checkBundleSlots(MenuItem models.MenuItem) error {
+	if len(MenuItem.BundleSlots) == 0 {
+		return nil
+	}
+	if len(MenuItem.Ingredients) > 0 || len(MenuItem.Variants) > 0 {
+		return errors.New("bundle can not have ingredients or variants of its own")
+	}
+	for _, slot := range MenuItem.BundleSlots {
+		if strings.TrimSpace(slot.Name) == "" {
+			return errors.New("bundle slot's Name is empty")
+		}
+		if slot.Quantity < 0 {
+			return errors.New("bundle slot's quantity is awkward")
+		}
+		if len(slot.Options) == 0 {
+			return errors.New("bundle slot has no options")
+		}
+		options := make(map[int]bool)
+		for _, option := range slot.Options {
+			if options[option.ProductID] {
+				return errors.New("bundle slot options must be unique")
+			}
+			options[option.ProductID] = true
+			if option.PriceDelta < 0 {
+				return errors.New("bundle option's price_delta is awkward")
+			}
+			if option.ProductID == MenuItem.ID || !s.menuRepo.MenuCheckByIDRepo(option.ProductID) {
+				return fmt.Errorf("bundle option's product %d does not exist", option.ProductID)
+			}
+			slots, err := s.menuRepo.GetBundleSlots(option.ProductID)
+			if err != nil {
+				return err
+			}
+			if len(slots) > 0 {
+				return fmt.Errorf("bundle option's product %d is a bundle itself", option.ProductID)
+			}
+		}
+	}
 	return nil
 }
+
+// normalizeAllergens trims and lowercases the allergens given by a client and checks they are known
+func normalizeAllergens(list []string) ([]string, error) {
+	var allergens []string
+	for _, allergen := range list {
+		allergen = strings.ToLower(strings.TrimSpace(allergen))
+		if !models.IsAllergen(allergen) {
+			return nil, fmt.Errorf("%w: %q", models.ErrUnknownAllergen, allergen)
+		}
+		allergens = append(allergens, allergen)
+	}
+	return allergens, nil
+}
@@ -9,18 +9,26 @@ import (
 	"time"
 
 	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/notifier"
 	"github.com/sunzhqr/frappuccino/internal/repository"
 )
 
+var (
+	ErrUnknownOrderStatus = errors.New("unknown order status. Available statuses: pending, accepted, preparing, ready, picked_up, cancelled")
+	ErrActorRequired      = errors.New("actor is required")
+)
+
 type OrderServiceInterface interface {
 	AddOrder(order models.Order) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error)
 	BulkOrders(orders []models.Order) (models.BatchOrdersResponce, error)
 	GetAllOrders() ([]models.Order, error)
 	GetOrder(OrderID int) (models.Order, error)
 	UpdateOrder(updatedOrder models.Order, OrderID string) error
-	GetTotalSales() (models.TotalSales, error)
 	DeleteOrderByID(OrderID int) error
 	CloseOrder(OrderID int) error
+	TransitionOrder(OrderID int, request models.OrderTransitionRequest) (models.OrderStatusChange, error)
+	GetOrderStatusHistory(OrderID int) ([]models.OrderStatusChange, error)
+	CancelOrder(OrderID int, actor string) (models.OrderStatusChange, error)
 	GetNumberOfItems(startDate, endDate string) (map[string]int, error)
 	GetOrderedItemsByPeriod(period, month, year string) (map[string]interface{}, error)
 }
@@ -29,13 +37,15 @@ type OrderService struct {
 	orderRepo     repository.OrderRepositoryInterface
 	menuRepo      repository.MenuRepositoryInterface
 	inventoryRepo repository.InventoryRepositoryInterface
+	notifier      notifier.Notifier
 }
 
-func NewOrderService(orderRepo repository.OrderRepositoryInterface, menuRepo repository.MenuRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface) *OrderService {
+func NewOrderService(orderRepo repository.OrderRepositoryInterface, menuRepo repository.MenuRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface, notifier notifier.Notifier) *OrderService {
 	return &OrderService{
 		orderRepo:     orderRepo,
 		menuRepo:      menuRepo,
 		inventoryRepo: inventoryRepo,
+		notifier:      notifier,
 	}
 }
 
@@ -51,7 +61,23 @@ func (s *OrderService) AddOrder(order models.Order) (models.BatchOrderInfo, []mo
 		}, []models.BatchOrderInventoryUpdate{}, err
 	}
 
-	return s.orderRepo.Add(order)
+	processInfo, inventoryInfo, err := s.orderRepo.Add(order)
+	if err != nil {
+		return processInfo, inventoryInfo, err
+	}
+
+	// Stock before the order is what remained after the first deduction plus what it used
+	previous := make(map[int]float64)
+	for _, v := range inventoryInfo {
+		before := float64(v.Remaining + v.Quantity_used)
+		if before > previous[v.IngredientID] {
+			previous[v.IngredientID] = before
+		}
+	}
+	if err := notifyLowStock(s.notifier, s.inventoryRepo, previous); err != nil {
+		log.Printf("Error checking low stock: %v", err)
+	}
+	return processInfo, inventoryInfo, nil
 }
 
 func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResponce, error) {
@@ -123,29 +149,35 @@ func (s *OrderService) UpdateOrder(updatedOrder models.Order, OrderID string) er
 	return s.orderRepo.SaveUpdatedOrder(updatedOrder, OrderID)
 }
 
-func (s *OrderService) GetTotalSales() (models.TotalSales, error) {
-	existingOrders, err := s.orderRepo.GetAll()
-	if err != nil {
-		return models.TotalSales{}, err
-	}
+func (s *OrderService) DeleteOrderByID(OrderID int) error {
+	return s.orderRepo.DeleteOrder(OrderID)
+}
 
-	// Counting sales amount
-	totalSales := models.TotalSales{}
+func (s *OrderService) CloseOrder(OrderID int) error {
+	return s.orderRepo.CloseOrderRepo(OrderID)
+}
 
-	for _, order := range existingOrders {
-		for _, item := range order.Items {
-			totalSales.TotalSales += item.Quantity
-		}
+// TransitionOrder moves an order along its lifecycle on behalf of the given actor
+func (s *OrderService) TransitionOrder(OrderID int, request models.OrderTransitionRequest) (models.OrderStatusChange, error) {
+	if !models.IsOrderStatus(request.Status) {
+		return models.OrderStatusChange{}, ErrUnknownOrderStatus
 	}
-	return totalSales, nil
+	if strings.TrimSpace(request.Actor) == "" {
+		return models.OrderStatusChange{}, ErrActorRequired
+	}
+	return s.orderRepo.TransitionOrder(OrderID, request.Status, strings.TrimSpace(request.Actor))
 }
 
-func (s *OrderService) DeleteOrderByID(OrderID int) error {
-	return s.orderRepo.DeleteOrder(OrderID)
+// CancelOrder cancels the order and returns the consumed ingredients to the inventory
+func (s *OrderService) CancelOrder(OrderID int, actor string) (models.OrderStatusChange, error) {
+	return s.TransitionOrder(OrderID, models.OrderTransitionRequest{
+		Status: models.OrderStatusCancelled,
+		Actor:  actor,
+	})
 }
 
-func (s *OrderService) CloseOrder(OrderID int) error {
-	return s.orderRepo.CloseOrderRepo(OrderID)
+func (s *OrderService) GetOrderStatusHistory(OrderID int) ([]models.OrderStatusChange, error) {
+	return s.orderRepo.GetStatusHistory(OrderID)
 }
 
 func (s *OrderService) GetNumberOfItems(startDate, endDate string) (map[string]int, error) {
@@ -244,6 +276,24 @@ func validateOrder(order models.Order) error {
 		if order.Quantity < 1 {
 			return errors.New("quantity a product must be greater than zero")
 		}
+		if order.VariantID < 0 {
+			return errors.New("variant id must be positive integer")
+		}
+		for _, modifierID := range order.Modifiers {
+			if modifierID < 1 {
+				return errors.New("modifier id must be positive integer")
+			}
+		}
+		for _, choice := range order.BundleChoices {
+			if choice.SlotID < 1 || choice.ProductID < 1 {
+				return errors.New("bundle choice slot id and product id must be positive integers")
+			}
+		}
+	}
+	for _, code := range order.PromoCodes {
+		if strings.TrimSpace(code) == "" {
+			return errors.New("promo code must not be empty")
+		}
 	}
 
 	return nil
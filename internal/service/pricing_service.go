@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrInvalidScheduledPrice = errors.New("invalid scheduled price")
+	ErrInvalidPricingRule    = errors.New("invalid pricing rule")
+)
+
+type PricingServiceInterface interface {
+	GetPriceHistory(menuItemID int) (models.PriceHistory, error)
+	GetScheduledPrices() ([]models.ScheduledPrice, error)
+	SchedulePrice(menuItemID int, price models.ScheduledPrice) (models.ScheduledPrice, error)
+	CancelScheduledPrice(id int) error
+	ApplyScheduledPrices() ([]models.ScheduledPrice, error)
+	GetRules() ([]models.PricingRule, error)
+	AddRule(rule models.PricingRule) (models.PricingRule, error)
+	UpdateRule(rule models.PricingRule) (models.PricingRule, error)
+	DeleteRule(id int) error
+}
+
+type PricingService struct {
+	pricingRepo  repository.PricingRepositoryInterface
+	categoryRepo repository.CategoryRepositoryInterface
+}
+
+func NewPricingService(pricingRepo repository.PricingRepositoryInterface, categoryRepo repository.CategoryRepositoryInterface) *PricingService {
+	return &PricingService{pricingRepo: pricingRepo, categoryRepo: categoryRepo}
+}
+
+// GetPriceHistory returns the current price of the menu item, its past changes and the pending scheduled prices
+func (s *PricingService) GetPriceHistory(menuItemID int) (models.PriceHistory, error) {
+	name, price, err := s.pricingRepo.GetMenuItemPrice(menuItemID)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	changes, err := s.pricingRepo.GetPriceChanges(menuItemID)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	scheduled, err := s.pricingRepo.GetScheduledPrices(menuItemID)
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	return models.PriceHistory{
+		ProductID:    menuItemID,
+		Name:         name,
+		CurrentPrice: price,
+		Changes:      changes,
+		Scheduled:    scheduled,
+	}, nil
+}
+
+// GetScheduledPrices returns the pending scheduled prices of the whole menu
+func (s *PricingService) GetScheduledPrices() ([]models.ScheduledPrice, error) {
+	return s.pricingRepo.GetScheduledPrices(0)
+}
+
+// SchedulePrice schedules a new price of the menu item for a moment in the future
+func (s *PricingService) SchedulePrice(menuItemID int, price models.ScheduledPrice) (models.ScheduledPrice, error) {
+	if price.Price <= 0 || price.Price != math.Round(price.Price*100)/100 {
+		return models.ScheduledPrice{}, fmt.Errorf("%w: price must be positive with at most two decimals", ErrInvalidScheduledPrice)
+	}
+	if !price.EffectiveAt.After(time.Now()) {
+		return models.ScheduledPrice{}, fmt.Errorf("%w: effective_at must be in the future", ErrInvalidScheduledPrice)
+	}
+	if _, _, err := s.pricingRepo.GetMenuItemPrice(menuItemID); err != nil {
+		return models.ScheduledPrice{}, err
+	}
+
+	price.ProductID = menuItemID
+	id, err := s.pricingRepo.AddScheduledPrice(price)
+	if err != nil {
+		return models.ScheduledPrice{}, err
+	}
+	return s.pricingRepo.GetScheduledPrice(id)
+}
+
+func (s *PricingService) CancelScheduledPrice(id int) error {
+	return s.pricingRepo.DeleteScheduledPrice(id)
+}
+
+// ApplyScheduledPrices applies the scheduled prices that are due, returning the applied ones
+func (s *PricingService) ApplyScheduledPrices() ([]models.ScheduledPrice, error) {
+	return s.pricingRepo.ApplyDuePrices()
+}
+
+// GetRules returns the pricing rules, the ones tried first come first
+func (s *PricingService) GetRules() ([]models.PricingRule, error) {
+	return s.pricingRepo.GetRules()
+}
+
+func (s *PricingService) AddRule(rule models.PricingRule) (models.PricingRule, error) {
+	if err := s.validateRule(rule); err != nil {
+		return models.PricingRule{}, err
+	}
+	id, err := s.pricingRepo.AddRule(rule)
+	if err != nil {
+		return models.PricingRule{}, err
+	}
+	return s.pricingRepo.GetRule(id)
+}
+
+func (s *PricingService) UpdateRule(rule models.PricingRule) (models.PricingRule, error) {
+	if err := s.validateRule(rule); err != nil {
+		return models.PricingRule{}, err
+	}
+	if err := s.pricingRepo.UpdateRule(rule); err != nil {
+		return models.PricingRule{}, err
+	}
+	return s.pricingRepo.GetRule(rule.ID)
+}
+
+func (s *PricingService) DeleteRule(id int) error {
+	return s.pricingRepo.DeleteRule(id)
+}
+
+func (s *PricingService) validateRule(rule models.PricingRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPricingRule)
+	}
+	for _, day := range rule.Weekdays {
+		if day < 1 || day > 7 {
+			return fmt.Errorf("%w: weekdays must be 1 (Monday) to 7 (Sunday)", ErrInvalidPricingRule)
+		}
+	}
+	if _, err := models.ParseClock(rule.StartTime); err != nil {
+		return fmt.Errorf("%w: start_time must be HH:MM", ErrInvalidPricingRule)
+	}
+	if _, err := models.ParseClock(rule.EndTime); err != nil {
+		return fmt.Errorf("%w: end_time must be HH:MM", ErrInvalidPricingRule)
+	}
+	switch rule.AdjustmentType {
+	case models.AdjustmentPercentage:
+		if rule.Value < -100 {
+			return fmt.Errorf("%w: percentage can not take more than 100%% off", ErrInvalidPricingRule)
+		}
+	case models.AdjustmentFixed:
+	default:
+		return fmt.Errorf("%w: adjustment_type must be percentage or fixed", ErrInvalidPricingRule)
+	}
+	if rule.Value == 0 || rule.Value != math.Round(rule.Value*100)/100 {
+		return fmt.Errorf("%w: value must be non zero with at most two decimals", ErrInvalidPricingRule)
+	}
+	if rule.CategoryID != 0 {
+		if _, err := s.categoryRepo.GetByID(rule.CategoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
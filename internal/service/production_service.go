@@ -0,0 +1,251 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/notifier"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrInvalidRecipe     = errors.New("invalid prepared ingredient recipe")
+	ErrInvalidProduction = errors.New("invalid production request")
+)
+
+type ProductionServiceInterface interface {
+	GetRecipe(ingredientID int) (models.PreparedRecipe, error)
+	SaveRecipe(recipe models.PreparedRecipe) (models.PreparedRecipe, error)
+	DeleteRecipe(ingredientID int) error
+	Produce(ingredientID int, request models.ProductionRequest) (models.ProductionBatch, error)
+}
+
+type ProductionService struct {
+	productionRepo repository.ProductionRepositoryInterface
+	inventoryRepo  repository.InventoryRepositoryInterface
+	notifier       notifier.Notifier
+}
+
+func NewProductionService(productionRepo repository.ProductionRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface, notifier notifier.Notifier) *ProductionService {
+	return &ProductionService{
+		productionRepo: productionRepo,
+		inventoryRepo:  inventoryRepo,
+		notifier:       notifier,
+	}
+}
+
+// GetRecipe returns the recipe of the prepared ingredient priced through nested recipes
+func (s *ProductionService) GetRecipe(ingredientID int) (models.PreparedRecipe, error) {
+	recipes, items, err := s.loadRecipes()
+	if err != nil {
+		return models.PreparedRecipe{}, err
+	}
+	if _, ok := recipes[ingredientID]; !ok {
+		return models.PreparedRecipe{}, models.ErrRecipeNotFound
+	}
+	return priceRecipe(ingredientID, recipes, items), nil
+}
+
+// SaveRecipe defines the recipe of a prepared ingredient. Component quantities may be given in
+// any unit compatible with the component stock unit, the yield is in the prepared ingredient stock unit.
+// A prepared ingredient that has no unit cost yet gets the rolled up recipe cost.
+func (s *ProductionService) SaveRecipe(recipe models.PreparedRecipe) (models.PreparedRecipe, error) {
+	recipes, items, err := s.loadRecipes()
+	if err != nil {
+		return models.PreparedRecipe{}, err
+	}
+	item, ok := items[recipe.IngredientID]
+	if !ok {
+		return models.PreparedRecipe{}, fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidRecipe, recipe.IngredientID)
+	}
+	if recipe.Yield <= 0 || recipe.Yield != math.Trunc(recipe.Yield) {
+		return models.PreparedRecipe{}, fmt.Errorf("%w: yield must be a positive whole number", ErrInvalidRecipe)
+	}
+	if len(recipe.Components) == 0 {
+		return models.PreparedRecipe{}, fmt.Errorf("%w: at least one component is required", ErrInvalidRecipe)
+	}
+
+	converter := newStockConverter(s.inventoryRepo)
+	seen := make(map[int]bool)
+	components := make([]models.RecipeComponent, len(recipe.Components))
+	for i, component := range recipe.Components {
+		if component.IngredientID == recipe.IngredientID {
+			return models.PreparedRecipe{}, fmt.Errorf("%w: ingredient can not be a component of itself", ErrInvalidRecipe)
+		}
+		if _, ok := items[component.IngredientID]; !ok {
+			return models.PreparedRecipe{}, fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidRecipe, component.IngredientID)
+		}
+		if seen[component.IngredientID] {
+			return models.PreparedRecipe{}, fmt.Errorf("%w: ingredient %d is listed twice", ErrInvalidRecipe, component.IngredientID)
+		}
+		seen[component.IngredientID] = true
+
+		quantity, err := converter.toStock(component.IngredientID, component.Quantity, component.Unit)
+		if err != nil {
+			return models.PreparedRecipe{}, err
+		}
+		if quantity <= 0 || quantity != math.Trunc(quantity) {
+			return models.PreparedRecipe{}, fmt.Errorf("%w: component quantity must be a positive whole number", ErrInvalidRecipe)
+		}
+		components[i] = models.RecipeComponent{IngredientID: component.IngredientID, Quantity: quantity}
+	}
+	recipe.Components = components
+
+	recipes[recipe.IngredientID] = recipe
+	if usesIngredient(recipe.IngredientID, recipe.IngredientID, recipes, map[int]bool{}) {
+		return models.PreparedRecipe{}, fmt.Errorf("%w: recipe of ingredient %d would use itself through nested recipes", ErrInvalidRecipe, recipe.IngredientID)
+	}
+
+	if err := s.productionRepo.SaveRecipe(recipe); err != nil {
+		return models.PreparedRecipe{}, err
+	}
+
+	priced := priceRecipe(recipe.IngredientID, recipes, items)
+	if item.UnitCost == 0 && priced.UnitCost > 0 {
+		item.UnitCost = priced.UnitCost
+		if err := s.inventoryRepo.UpdateItemRepo(item.IngredientID, item); err != nil {
+			return models.PreparedRecipe{}, err
+		}
+	}
+	return priced, nil
+}
+
+func (s *ProductionService) DeleteRecipe(ingredientID int) error {
+	deleted, err := s.productionRepo.DeleteRecipe(ingredientID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return models.ErrRecipeNotFound
+	}
+	return nil
+}
+
+// Produce makes batches of the prepared ingredient from stock
+func (s *ProductionService) Produce(ingredientID int, request models.ProductionRequest) (models.ProductionBatch, error) {
+	if request.Batches <= 0 {
+		return models.ProductionBatch{}, fmt.Errorf("%w: batches must be positive", ErrInvalidProduction)
+	}
+	if request.Quantity < 0 || request.Quantity != math.Trunc(request.Quantity) {
+		return models.ProductionBatch{}, fmt.Errorf("%w: quantity must be a positive whole number", ErrInvalidProduction)
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return models.ProductionBatch{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidProduction)
+	}
+
+	recipes, items, err := s.loadRecipes()
+	if err != nil {
+		return models.ProductionBatch{}, err
+	}
+	recipe, ok := recipes[ingredientID]
+	if !ok {
+		return models.ProductionBatch{}, models.ErrRecipeNotFound
+	}
+	if request.Quantity == 0 {
+		request.Quantity = recipe.Yield * float64(request.Batches)
+	}
+
+	previous := make(map[int]float64)
+	for _, component := range recipe.Components {
+		previous[component.IngredientID] = items[component.IngredientID].Quantity
+	}
+
+	id, err := s.productionRepo.Produce(ingredientID, request)
+	if err != nil {
+		return models.ProductionBatch{}, err
+	}
+	if err := notifyLowStock(s.notifier, s.inventoryRepo, previous); err != nil {
+		log.Printf("Error checking low stock: %v", err)
+	}
+	return s.productionRepo.GetBatch(id)
+}
+
+// loadRecipes returns all prepared recipes and all inventory items by ingredient ID
+func (s *ProductionService) loadRecipes() (map[int]models.PreparedRecipe, map[int]models.InventoryItem, error) {
+	list, err := s.productionRepo.GetRecipes()
+	if err != nil {
+		return nil, nil, err
+	}
+	recipes := make(map[int]models.PreparedRecipe, len(list))
+	for _, recipe := range list {
+		recipes[recipe.IngredientID] = recipe
+	}
+
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make(map[int]models.InventoryItem, len(inventory))
+	for _, item := range inventory {
+		items[item.IngredientID] = item
+	}
+	return recipes, items, nil
+}
+
+// usesIngredient reports whether the recipe of preparedID needs ingredientID, directly or through nested recipes
+func usesIngredient(preparedID, ingredientID int, recipes map[int]models.PreparedRecipe, visited map[int]bool) bool {
+	if visited[preparedID] {
+		return false
+	}
+	visited[preparedID] = true
+
+	for _, component := range recipes[preparedID].Components {
+		if component.IngredientID == ingredientID {
+			return true
+		}
+		if _, ok := recipes[component.IngredientID]; ok && usesIngredient(component.IngredientID, ingredientID, recipes, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// priceRecipe fills names and costs of the recipe. Raw components cost their current unit cost,
+// prepared components cost their own recipe per unit of yield.
+func priceRecipe(ingredientID int, recipes map[int]models.PreparedRecipe, items map[int]models.InventoryItem) models.PreparedRecipe {
+	recipe := recipes[ingredientID]
+	recipe.Name = items[ingredientID].Name
+	recipe.Unit = items[ingredientID].Unit
+
+	components := make([]models.RecipeComponent, len(recipe.Components))
+	var batchCost float64
+	for i, component := range recipe.Components {
+		_, prepared := recipes[component.IngredientID]
+		unitCost := rolledUpUnitCost(component.IngredientID, recipes, items, map[int]bool{ingredientID: true})
+		components[i] = models.RecipeComponent{
+			IngredientID: component.IngredientID,
+			Name:         items[component.IngredientID].Name,
+			Quantity:     component.Quantity,
+			Unit:         items[component.IngredientID].Unit,
+			Prepared:     prepared,
+			UnitCost:     math.Round(unitCost*10000) / 10000,
+			Cost:         math.Round(component.Quantity*unitCost*100) / 100,
+		}
+		batchCost += component.Quantity * unitCost
+	}
+	recipe.Components = components
+	recipe.BatchCost = math.Round(batchCost*100) / 100
+	recipe.UnitCost = math.Round(batchCost/recipe.Yield*10000) / 10000
+	return recipe
+}
+
+// rolledUpUnitCost is the cost of one stock unit of the ingredient made from its recipe,
+// or its current unit cost when it is raw. path guards against cycles.
+func rolledUpUnitCost(ingredientID int, recipes map[int]models.PreparedRecipe, items map[int]models.InventoryItem, path map[int]bool) float64 {
+	recipe, ok := recipes[ingredientID]
+	if !ok || path[ingredientID] {
+		return items[ingredientID].UnitCost
+	}
+	path[ingredientID] = true
+	defer delete(path, ingredientID)
+
+	var cost float64
+	for _, component := range recipe.Components {
+		cost += component.Quantity * rolledUpUnitCost(component.IngredientID, recipes, items, path)
+	}
+	return cost / recipe.Yield
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var ErrInvalidPromoCode = errors.New("invalid promo code")
+
+type PromoServiceInterface interface {
+	GetPromoCodes() ([]models.PromoCode, error)
+	GetPromoCode(id int) (models.PromoCode, error)
+	AddPromoCode(promo models.PromoCode) (models.PromoCode, error)
+	UpdatePromoCode(promo models.PromoCode) (models.PromoCode, error)
+	DeletePromoCode(id int) error
+}
+
+type PromoService struct {
+	promoRepo    repository.PromoRepositoryInterface
+	menuRepo     repository.MenuRepositoryInterface
+	categoryRepo repository.CategoryRepositoryInterface
+}
+
+func NewPromoService(promoRepo repository.PromoRepositoryInterface, menuRepo repository.MenuRepositoryInterface, categoryRepo repository.CategoryRepositoryInterface) *PromoService {
+	return &PromoService{promoRepo: promoRepo, menuRepo: menuRepo, categoryRepo: categoryRepo}
+}
+
+func (s *PromoService) GetPromoCodes() ([]models.PromoCode, error) {
+	return s.promoRepo.GetAll()
+}
+
+func (s *PromoService) GetPromoCode(id int) (models.PromoCode, error) {
+	return s.promoRepo.GetByID(id)
+}
+
+func (s *PromoService) AddPromoCode(promo models.PromoCode) (models.PromoCode, error) {
+	promo.Code = models.NormalizePromoCode(promo.Code)
+	if err := s.validatePromoCode(promo); err != nil {
+		return models.PromoCode{}, err
+	}
+	id, err := s.promoRepo.Add(promo)
+	if err != nil {
+		return models.PromoCode{}, err
+	}
+	return s.promoRepo.GetByID(id)
+}
+
+func (s *PromoService) UpdatePromoCode(promo models.PromoCode) (models.PromoCode, error) {
+	promo.Code = models.NormalizePromoCode(promo.Code)
+	if err := s.validatePromoCode(promo); err != nil {
+		return models.PromoCode{}, err
+	}
+	if err := s.promoRepo.Update(promo); err != nil {
+		return models.PromoCode{}, err
+	}
+	return s.promoRepo.GetByID(promo.ID)
+}
+
+func (s *PromoService) DeletePromoCode(id int) error {
+	return s.promoRepo.Delete(id)
+}
+
+func (s *PromoService) validatePromoCode(promo models.PromoCode) error {
+	if promo.Code == "" || len(promo.Code) > 30 {
+		return fmt.Errorf("%w: code is required, at most 30 characters", ErrInvalidPromoCode)
+	}
+	if promo.Value <= 0 || promo.Value != math.Round(promo.Value*100)/100 {
+		return fmt.Errorf("%w: value must be positive with at most two decimals", ErrInvalidPromoCode)
+	}
+	switch promo.DiscountType {
+	case models.PromoPercentage:
+		if promo.Value > 100 {
+			return fmt.Errorf("%w: percentage can not be more than 100", ErrInvalidPromoCode)
+		}
+	case models.PromoFixed:
+	case models.PromoBuyXGetY:
+		if promo.BuyQuantity <= 0 || promo.GetQuantity <= 0 {
+			return fmt.Errorf("%w: buy_quantity and get_quantity must be positive", ErrInvalidPromoCode)
+		}
+		if promo.Value > 100 {
+			return fmt.Errorf("%w: percentage off the free items can not be more than 100", ErrInvalidPromoCode)
+		}
+	default:
+		return fmt.Errorf("%w: discount_type must be percentage, fixed or buy_x_get_y", ErrInvalidPromoCode)
+	}
+	if promo.DiscountType != models.PromoBuyXGetY && (promo.BuyQuantity != 0 || promo.GetQuantity != 0) {
+		return fmt.Errorf("%w: buy_quantity and get_quantity are only for buy_x_get_y", ErrInvalidPromoCode)
+	}
+	if promo.MinSpend < 0 || promo.MaxUses < 0 || promo.MaxUsesPerCustomer < 0 {
+		return fmt.Errorf("%w: min_spend and usage limits must not be negative", ErrInvalidPromoCode)
+	}
+	if promo.ValidFrom != nil && promo.ValidTo != nil && !promo.ValidTo.After(*promo.ValidFrom) {
+		return fmt.Errorf("%w: valid_to must be after valid_from", ErrInvalidPromoCode)
+	}
+	if promo.ProductID != 0 && promo.CategoryID != 0 {
+		return fmt.Errorf("%w: scope is either a product or a category", ErrInvalidPromoCode)
+	}
+	if promo.ProductID != 0 && !s.menuRepo.Exists(promo.ProductID) {
+		return fmt.Errorf("%w: menu item %d does not exist", ErrInvalidPromoCode, promo.ProductID)
+	}
+	if promo.CategoryID != 0 {
+		if _, err := s.categoryRepo.GetByID(promo.CategoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrInvalidPurchaseOrder       = errors.New("invalid purchase order")
+	ErrUnknownPurchaseOrderStatus = errors.New("unknown purchase order status")
+)
+
+type PurchaseOrderServiceInterface interface {
+	GetPurchaseOrders(status string) ([]models.PurchaseOrder, error)
+	GetPurchaseOrder(id int) (models.PurchaseOrder, error)
+	AddPurchaseOrder(order models.PurchaseOrder) (models.PurchaseOrder, error)
+	UpdatePurchaseOrder(order models.PurchaseOrder) error
+	SendPurchaseOrder(id int) (models.PurchaseOrder, error)
+	ReceivePurchaseOrder(id int, receipt models.PurchaseReceipt) (models.PurchaseOrder, error)
+}
+
+type PurchaseOrderService struct {
+	purchaseOrderRepo repository.PurchaseOrderRepositoryInterface
+	supplierRepo      repository.SupplierRepositoryInterface
+	inventoryRepo     repository.InventoryRepositoryInterface
+}
+
+func NewPurchaseOrderService(purchaseOrderRepo repository.PurchaseOrderRepositoryInterface, supplierRepo repository.SupplierRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface) *PurchaseOrderService {
+	return &PurchaseOrderService{
+		purchaseOrderRepo: purchaseOrderRepo,
+		supplierRepo:      supplierRepo,
+		inventoryRepo:     inventoryRepo,
+	}
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrders(status string) ([]models.PurchaseOrder, error) {
+	if status != "" && !models.IsPurchaseOrderStatus(status) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPurchaseOrderStatus, status)
+	}
+	return s.purchaseOrderRepo.GetAll(status)
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrder(id int) (models.PurchaseOrder, error) {
+	return s.purchaseOrderRepo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) AddPurchaseOrder(order models.PurchaseOrder) (models.PurchaseOrder, error) {
+	order, err := s.convertOrderUnits(order)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	if err := s.validatePurchaseOrder(order); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	id, err := s.purchaseOrderRepo.Add(order)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.purchaseOrderRepo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) UpdatePurchaseOrder(order models.PurchaseOrder) error {
+	if _, err := s.purchaseOrderRepo.GetByID(order.ID); err != nil {
+		return err
+	}
+	order, err := s.convertOrderUnits(order)
+	if err != nil {
+		return err
+	}
+	if err := s.validatePurchaseOrder(order); err != nil {
+		return err
+	}
+	return s.purchaseOrderRepo.Update(order)
+}
+
+func (s *PurchaseOrderService) SendPurchaseOrder(id int) (models.PurchaseOrder, error) {
+	if err := s.purchaseOrderRepo.Send(id); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.purchaseOrderRepo.GetByID(id)
+}
+
+// ReceivePurchaseOrder books a delivery into inventory and returns the purchase order with its new status
+func (s *PurchaseOrderService) ReceivePurchaseOrder(id int, receipt models.PurchaseReceipt) (models.PurchaseOrder, error) {
+	converter := newStockConverter(s.inventoryRepo)
+	seen := make(map[int]bool)
+	for i, item := range receipt.Items {
+		quantity, err := converter.toStock(item.IngredientID, item.Quantity, item.Unit)
+		if err != nil {
+			return models.PurchaseOrder{}, err
+		}
+		item.Quantity, item.Unit = quantity, ""
+		receipt.Items[i] = item
+
+		if seen[item.IngredientID] {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: ingredient %d is listed twice", models.ErrInvalidReceipt, item.IngredientID)
+		}
+		seen[item.IngredientID] = true
+
+		if item.Quantity != math.Trunc(item.Quantity) {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: quantity must be a whole number", models.ErrInvalidReceipt)
+		}
+		if item.ExpiresAt != nil && !item.ExpiresAt.After(time.Now()) {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: ingredient %d is already expired", models.ErrInvalidReceipt, item.IngredientID)
+		}
+	}
+
+	if err := s.purchaseOrderRepo.Receive(id, receipt); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.purchaseOrderRepo.GetByID(id)
+}
+
+// convertOrderUnits converts item quantities given in a unit to the ingredient stock unit.
+// Unit cost is given per ordered unit and is recalculated per stock unit.
+func (s *PurchaseOrderService) convertOrderUnits(order models.PurchaseOrder) (models.PurchaseOrder, error) {
+	converter := newStockConverter(s.inventoryRepo)
+	items := make([]models.PurchaseOrderItem, len(order.Items))
+	for i, item := range order.Items {
+		if item.Unit != "" && item.Quantity > 0 {
+			quantity, err := converter.toStock(item.IngredientID, item.Quantity, item.Unit)
+			if err != nil {
+				return models.PurchaseOrder{}, err
+			}
+			item.UnitCost = math.Round(item.UnitCost*item.Quantity/quantity*10000) / 10000
+			item.Quantity = quantity
+			item.Unit = ""
+		}
+		items[i] = item
+	}
+	order.Items = items
+	return order, nil
+}
+
+func (s *PurchaseOrderService) validatePurchaseOrder(order models.PurchaseOrder) error {
+	if _, err := s.supplierRepo.GetByID(order.SupplierID); err != nil {
+		return err
+	}
+	if len(order.Items) == 0 {
+		return fmt.Errorf("%w: purchase order must have at least one item", ErrInvalidPurchaseOrder)
+	}
+
+	seen := make(map[int]bool)
+	for _, item := range order.Items {
+		if seen[item.IngredientID] {
+			return fmt.Errorf("%w: ingredient %d is listed twice", ErrInvalidPurchaseOrder, item.IngredientID)
+		}
+		seen[item.IngredientID] = true
+
+		if item.Quantity <= 0 || item.Quantity != math.Trunc(item.Quantity) || item.UnitCost < 0 {
+			return fmt.Errorf("%w: quantity must be a positive whole number and unit cost not negative", ErrInvalidPurchaseOrder)
+		}
+		if !s.inventoryRepo.Exists(item.IngredientID) {
+			return fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidPurchaseOrder, item.IngredientID)
+		}
+	}
+	return nil
+}
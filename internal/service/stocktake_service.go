@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var ErrInvalidCounts = errors.New("invalid stocktake counts")
+
+type StocktakeServiceInterface interface {
+	OpenStocktake(notes string) (models.Stocktake, error)
+	GetStocktakes() ([]models.Stocktake, error)
+	GetStocktake(id int) (models.Stocktake, error)
+	SubmitCounts(id int, counts []models.StocktakeCount) (models.Stocktake, error)
+	GetVariance(id int) (models.StocktakeVariance, error)
+	CommitStocktake(id int) (models.StocktakeVariance, error)
+}
+
+type StocktakeService struct {
+	stocktakeRepo repository.StocktakeRepositoryInterface
+	inventoryRepo repository.InventoryRepositoryInterface
+}
+
+func NewStocktakeService(stocktakeRepo repository.StocktakeRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface) *StocktakeService {
+	return &StocktakeService{stocktakeRepo: stocktakeRepo, inventoryRepo: inventoryRepo}
+}
+
+func (s *StocktakeService) OpenStocktake(notes string) (models.Stocktake, error) {
+	id, err := s.stocktakeRepo.Open(notes)
+	if err != nil {
+		return models.Stocktake{}, err
+	}
+	return s.stocktakeRepo.GetByID(id)
+}
+
+func (s *StocktakeService) GetStocktakes() ([]models.Stocktake, error) {
+	return s.stocktakeRepo.GetAll()
+}
+
+func (s *StocktakeService) GetStocktake(id int) (models.Stocktake, error) {
+	return s.stocktakeRepo.GetByID(id)
+}
+
+func (s *StocktakeService) SubmitCounts(id int, counts []models.StocktakeCount) (models.Stocktake, error) {
+	if len(counts) == 0 {
+		return models.Stocktake{}, fmt.Errorf("%w: at least one count is required", ErrInvalidCounts)
+	}
+
+	seen := make(map[int]bool)
+	for _, count := range counts {
+		if seen[count.IngredientID] {
+			return models.Stocktake{}, fmt.Errorf("%w: ingredient %d is counted twice", ErrInvalidCounts, count.IngredientID)
+		}
+		seen[count.IngredientID] = true
+
+		if count.CountedQuantity < 0 {
+			return models.Stocktake{}, fmt.Errorf("%w: counted quantity must not be negative", ErrInvalidCounts)
+		}
+		if !s.inventoryRepo.Exists(count.IngredientID) {
+			return models.Stocktake{}, fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidCounts, count.IngredientID)
+		}
+	}
+
+	if err := s.stocktakeRepo.SaveCounts(id, counts); err != nil {
+		return models.Stocktake{}, err
+	}
+	return s.stocktakeRepo.GetByID(id)
+}
+
+func (s *StocktakeService) GetVariance(id int) (models.StocktakeVariance, error) {
+	return s.stocktakeRepo.GetVariance(id)
+}
+
+// CommitStocktake applies the counts to the inventory and returns the final variance report
+func (s *StocktakeService) CommitStocktake(id int) (models.StocktakeVariance, error) {
+	stocktake, err := s.stocktakeRepo.GetByID(id)
+	if err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	if len(stocktake.Counts) == 0 {
+		return models.StocktakeVariance{}, fmt.Errorf("%w: nothing was counted", ErrInvalidCounts)
+	}
+
+	if err := s.stocktakeRepo.Commit(id); err != nil {
+		return models.StocktakeVariance{}, err
+	}
+	return s.stocktakeRepo.GetVariance(id)
+}
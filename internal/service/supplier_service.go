@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrSupplierNameRequired = errors.New("supplier name is required")
+	ErrSupplierInUse        = errors.New("supplier has purchase orders and can not be deleted")
+)
+
+type SupplierServiceInterface interface {
+	GetSuppliers() ([]models.Supplier, error)
+	GetSupplier(id int) (models.Supplier, error)
+	AddSupplier(supplier models.Supplier) (models.Supplier, error)
+	UpdateSupplier(supplier models.Supplier) error
+	DeleteSupplier(id int) error
+}
+
+type SupplierService struct {
+	supplierRepo repository.SupplierRepositoryInterface
+}
+
+func NewSupplierService(supplierRepo repository.SupplierRepositoryInterface) *SupplierService {
+	return &SupplierService{supplierRepo: supplierRepo}
+}
+
+func (s *SupplierService) GetSuppliers() ([]models.Supplier, error) {
+	return s.supplierRepo.GetAll()
+}
+
+func (s *SupplierService) GetSupplier(id int) (models.Supplier, error) {
+	return s.supplierRepo.GetByID(id)
+}
+
+func (s *SupplierService) AddSupplier(supplier models.Supplier) (models.Supplier, error) {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	if supplier.Name == "" {
+		return models.Supplier{}, ErrSupplierNameRequired
+	}
+
+	id, err := s.supplierRepo.Add(supplier)
+	if err != nil {
+		return models.Supplier{}, err
+	}
+	supplier.ID = id
+	return supplier, nil
+}
+
+func (s *SupplierService) UpdateSupplier(supplier models.Supplier) error {
+	if _, err := s.supplierRepo.GetByID(supplier.ID); err != nil {
+		return err
+	}
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	if supplier.Name == "" {
+		return ErrSupplierNameRequired
+	}
+	return s.supplierRepo.Update(supplier)
+}
+
+func (s *SupplierService) DeleteSupplier(id int) error {
+	if _, err := s.supplierRepo.GetByID(id); err != nil {
+		return err
+	}
+	used, err := s.supplierRepo.HasPurchaseOrders(id)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrSupplierInUse
+	}
+	return s.supplierRepo.Delete(id)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var (
+	ErrInvalidConversion  = errors.New("invalid unit conversion")
+	ErrConversionNotFound = errors.New("unit conversion not found")
+)
+
+// stockConverter converts ingredient quantities given in any compatible unit to whole stock units.
+// Ingredients and their custom conversions are loaded once per converter.
+type stockConverter struct {
+	inventoryRepo repository.InventoryRepositoryInterface
+	stockUnits    map[int]string
+	conversions   map[int][]models.UnitConversion
+}
+
+func newStockConverter(inventoryRepo repository.InventoryRepositoryInterface) *stockConverter {
+	return &stockConverter{
+		inventoryRepo: inventoryRepo,
+		stockUnits:    make(map[int]string),
+		conversions:   make(map[int][]models.UnitConversion),
+	}
+}
+
+// toStock returns the quantity in the ingredient stock unit rounded to a whole number.
+// An empty unit means the quantity is already in the stock unit.
+func (c *stockConverter) toStock(ingredientID int, quantity float64, unit string) (float64, error) {
+	if unit == "" {
+		return quantity, nil
+	}
+
+	stockUnit, ok := c.stockUnits[ingredientID]
+	if !ok {
+		items, err := c.inventoryRepo.GetByIDs([]int{ingredientID})
+		if err != nil {
+			return 0, err
+		}
+		if len(items) == 0 {
+			return 0, fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidConversion, ingredientID)
+		}
+		conversions, err := c.inventoryRepo.GetConversions(ingredientID)
+		if err != nil {
+			return 0, err
+		}
+		stockUnit = items[0].Unit
+		c.stockUnits[ingredientID] = stockUnit
+		c.conversions[ingredientID] = conversions
+	}
+
+	converted, err := models.ConvertToStockUnit(quantity, unit, stockUnit, c.conversions[ingredientID])
+	if err != nil {
+		return 0, err
+	}
+	converted = math.Round(converted)
+	if converted == 0 && quantity != 0 {
+		return 0, fmt.Errorf("%w: %v %s of ingredient %d is less than one %s", ErrInvalidConversion, quantity, unit, ingredientID, stockUnit)
+	}
+	return converted, nil
+}
+
+// IsUnitError reports whether err is caused by units given by the client
+func IsUnitError(err error) bool {
+	return errors.Is(err, models.ErrUnknownUnit) || errors.Is(err, models.ErrIncompatibleUnits) || errors.Is(err, ErrInvalidConversion)
+}
+
+// displayInUnit expresses the stock of the item in another unit compatible with its stock unit
+func displayInUnit(item models.InventoryItem, unit string, conversions []models.UnitConversion) (models.InventoryItem, error) {
+	if unit == "" || unit == item.Unit {
+		return item, nil
+	}
+	perUnit, err := models.ConvertToStockUnit(1, unit, item.Unit, conversions)
+	if err != nil {
+		return models.InventoryItem{}, err
+	}
+
+	round := func(v float64) float64 { return math.Round(v*10000) / 10000 }
+	item.Quantity = round(item.Quantity / perUnit)
+	item.ReorderLevel = round(item.ReorderLevel / perUnit)
+	item.ParLevel = round(item.ParLevel / perUnit)
+	item.UnitCost = round(item.UnitCost * perUnit)
+	item.Nutrition = item.Nutrition.Scale(perUnit)
+	item.Unit = unit
+	return item, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sunzhqr/frappuccino/internal/models"
+	"github.com/sunzhqr/frappuccino/internal/repository"
+)
+
+var ErrInvalidWaste = errors.New("invalid waste record")
+
+type WasteServiceInterface interface {
+	RecordWaste(request models.WasteRequest) (models.WasteLog, error)
+	GetWasteReport(from, to, groupBy string) (models.WasteReport, error)
+	WriteOffExpired() (models.WasteLog, error)
+}
+
+type WasteService struct {
+	wasteRepo     repository.WasteRepositoryInterface
+	menuRepo      repository.MenuRepositoryInterface
+	inventoryRepo repository.InventoryRepositoryInterface
+}
+
+func NewWasteService(wasteRepo repository.WasteRepositoryInterface, menuRepo repository.MenuRepositoryInterface, inventoryRepo repository.InventoryRepositoryInterface) *WasteService {
+	return &WasteService{
+		wasteRepo:     wasteRepo,
+		menuRepo:      menuRepo,
+		inventoryRepo: inventoryRepo,
+	}
+}
+
+// RecordWaste deducts written off ingredients and menu items from the inventory
+func (s *WasteService) RecordWaste(request models.WasteRequest) (models.WasteLog, error) {
+	if err := s.validateWaste(request); err != nil {
+		return models.WasteLog{}, err
+	}
+
+	id, err := s.wasteRepo.Add(request)
+	if err != nil {
+		return models.WasteLog{}, err
+	}
+	return s.wasteRepo.GetByID(id)
+}
+
+// GetWasteReport summarizes waste recorded between from and to (both inclusive), groupBy is one of day, week, month
+func (s *WasteService) GetWasteReport(from, to, groupBy string) (models.WasteReport, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return models.WasteReport{}, err
+	}
+	if groupBy != "" && groupBy != "day" && groupBy != "week" && groupBy != "month" {
+		return models.WasteReport{}, ErrInvalidGroupBy
+	}
+	return s.wasteRepo.GetReport(models.WasteFilter{From: start, To: end, GroupBy: groupBy})
+}
+
+// WriteOffExpired writes off what is left of every expired lot as expired waste.
+// Returns an empty waste log with ID 0 when nothing has expired.
+func (s *WasteService) WriteOffExpired() (models.WasteLog, error) {
+	id, err := s.wasteRepo.WriteOffExpired()
+	if err != nil || id == 0 {
+		return models.WasteLog{}, err
+	}
+	return s.wasteRepo.GetByID(id)
+}
+
+func (s *WasteService) validateWaste(request models.WasteRequest) error {
+	if !models.IsWasteReason(request.Reason) {
+		return fmt.Errorf("%w: reason must be one of spoiled, expired, spilled, damaged, other", ErrInvalidWaste)
+	}
+	if len(request.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidWaste)
+	}
+
+	for _, item := range request.Items {
+		if (item.IngredientID == 0) == (item.ProductID == 0) {
+			return fmt.Errorf("%w: every item needs either ingredient_id or product_id", ErrInvalidWaste)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidWaste)
+		}
+
+		if item.IngredientID != 0 {
+			if !s.inventoryRepo.Exists(item.IngredientID) {
+				return fmt.Errorf("%w: ingredient %d does not exist", ErrInvalidWaste, item.IngredientID)
+			}
+			continue
+		}
+		recipe, err := s.menuRepo.GetIngredientCosts(item.ProductID)
+		if err != nil {
+			return err
+		}
+		if len(recipe) == 0 {
+			return fmt.Errorf("%w: menu item %d does not exist or has no recipe", ErrInvalidWaste, item.ProductID)
+		}
+	}
+	return nil
+}